@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"bulk/utils"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const HistoryTable = "audit_log"
+
+type HistoryModel struct {
+	ID        *int       `db:"id,pk,autoincrement"`
+	TableName *string    `db:"table_name,type=VARCHAR(64),notnull,index=idx_audit_log_row"`
+	RowID     *string    `db:"row_id,type=VARCHAR(64),notnull,index=idx_audit_log_row"`
+	Column    *string    `db:"column_name,type=VARCHAR(64),notnull,index=idx_audit_log_row"`
+	Before    *string    `db:"old_value,type=TEXT"`
+	After     *string    `db:"new_value,type=TEXT"`
+	Actor     *string    `db:"actor,type=VARCHAR(255)"`
+	ChangedAt *time.Time `db:"changed_at,notnull"`
+}
+
+type HistoryPayload struct {
+	TableName *string    `db:"table_name"`
+	RowID     *string    `db:"row_id"`
+	Column    *string    `db:"column_name"`
+	Before    *string    `db:"old_value"`
+	After     *string    `db:"new_value"`
+	Actor     *string    `db:"actor"`
+	ChangedAt *time.Time `db:"changed_at"`
+}
+
+type HistoryCondition struct {
+	TableName *string `db:"table_name"`
+	RowID     *string `db:"row_id"`
+	Column    *string `db:"column_name"`
+}
+
+// Log is a sql.Auditor keeping changes in the audit_log table.
+type Log struct {
+	repo *sql.Repository[HistoryModel, HistoryPayload, HistoryCondition]
+}
+
+func NewLog(db *sqlx.DB) *Log {
+	return &Log{repo: sql.NewRepository[HistoryModel, HistoryPayload, HistoryCondition](db, HistoryTable)}
+}
+
+func (l *Log) Record(ctx context.Context, db sql.Executor, changes []sql.Change) error {
+	if tx, ok := db.(*sqlx.Tx); ok {
+		ctx = sql.WithTx(ctx, tx)
+	}
+	rows := make([]HistoryPayload, 0, len(changes))
+	for _, change := range changes {
+		change := change
+		row := HistoryPayload{
+			TableName: &change.Table,
+			RowID:     &change.RowID,
+			Column:    &change.Column,
+			Before:    change.Before,
+			After:     change.After,
+			ChangedAt: &change.ChangedAt,
+		}
+		if change.Actor != nil {
+			actor := fmt.Sprint(change.Actor)
+			row.Actor = &actor
+		}
+		rows = append(rows, row)
+	}
+	if _, err := l.repo.CreateBulk(ctx, rows); err != nil {
+		return fmt.Errorf("failed insert history: %w", err)
+	}
+	return nil
+}
+
+// Timeline returns the changes of one column of one row, oldest first.
+func (l *Log) Timeline(ctx context.Context, table string, rowID string, column string) ([]HistoryModel, error) {
+	result, err := l.repo.Select(ctx, l.repo.Columns(), &HistoryCondition{TableName: &table, RowID: &rowID, Column: &column},
+		&utils.Paginate{Sort: []utils.Sort{{Field: "changed_at"}, {Field: "id"}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed select history: %w", err)
+	}
+	return result.Data, nil
+}
+
+type PriceChange struct {
+	Before    *float64  `json:"before" db:"before"`
+	After     *float64  `json:"after" db:"after"`
+	Actor     *string   `json:"actor" db:"actor"`
+	ChangedAt time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// PriceTimeline returns the price changes of the product with sku, oldest
+// first.
+func (l *Log) PriceTimeline(ctx context.Context, products repo.ProductRepo, sku string) ([]PriceChange, error) {
+	product, err := products.FindOne(sql.WithDeleted(ctx), repo.ProductCondition{SKU: &sku})
+	if err != nil {
+		return nil, fmt.Errorf("failed find product %s: %w", sku, err)
+	}
+	history, err := l.Timeline(ctx, products.Table(), strconv.Itoa(*product.ID), "price")
+	if err != nil {
+		return nil, err
+	}
+	timeline := make([]PriceChange, 0, len(history))
+	for _, entry := range history {
+		change := PriceChange{Actor: entry.Actor, ChangedAt: *entry.ChangedAt}
+		if change.Before, err = parsePrice(entry.Before); err != nil {
+			return nil, err
+		}
+		if change.After, err = parsePrice(entry.After); err != nil {
+			return nil, err
+		}
+		timeline = append(timeline, change)
+	}
+	return timeline, nil
+}
+
+func parsePrice(value *string) (*float64, error) {
+	if value == nil {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(*value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed parse price %q: %w", *value, err)
+	}
+	return &price, nil
+}
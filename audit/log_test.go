@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+var models = map[string]any{repo.ProductTable: repo.ProductModel{}, HistoryTable: HistoryModel{}}
+
+func TestPriceTimeline(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for table, model := range models {
+		statements, err := sql.BuildCreateTable(sql.SQLite, table, model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, statement := range statements {
+			db.MustExec(statement)
+		}
+	}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	log := NewLog(db)
+	products := repo.NewProductSQLRepo(db, sql.WithAuditor(log), sql.WithClock(func() time.Time { return now }))
+
+	ctx := sql.WithActor(context.Background(), "alice")
+	a, b := "A", "B"
+	price, qty := 10.0, 5
+	_, err = products.CreateBulk(ctx, []repo.ProductPayload{{SKU: &a, Price: &price, Qty: &qty}, {SKU: &b, Price: &price, Qty: &qty}})
+	assert.Nil(t, err)
+
+	now = now.Add(time.Hour)
+	raised := 12.5
+	assert.Nil(t, products.Update(ctx, repo.ProductPayload{Price: &raised, Qty: &qty}, repo.ProductCondition{SKU: &a}))
+
+	now = now.Add(time.Hour)
+	cut := 9.0
+	fails, err := products.UpdateBulk(sql.WithActor(context.Background(), "bob"), []sql.Update[repo.ProductPayload, repo.ProductCondition]{
+		{Payload: repo.ProductPayload{Price: &cut}, Condition: repo.ProductCondition{SKU: &a}},
+		{Payload: repo.ProductPayload{Name: &b}, Condition: repo.ProductCondition{SKU: &b}, Options: []sql.QueryOption{sql.Set("qty", sql.Decr(1))}},
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, fails)
+
+	t.Run("price timeline", func(t *testing.T) {
+		timeline, err := log.PriceTimeline(context.Background(), products, "a")
+		assert.Nil(t, err)
+		assert.Len(t, timeline, 2)
+		assert.Equal(t, 10.0, *timeline[0].Before)
+		assert.Equal(t, 12.5, *timeline[0].After)
+		assert.Equal(t, "alice", *timeline[0].Actor)
+		assert.Equal(t, 12.5, *timeline[1].Before)
+		assert.Equal(t, 9.0, *timeline[1].After)
+		assert.Equal(t, "bob", *timeline[1].Actor)
+		assert.True(t, timeline[1].ChangedAt.After(timeline[0].ChangedAt))
+	})
+
+	t.Run("changed columns only", func(t *testing.T) {
+		history, err := log.Timeline(context.Background(), "products", "2", "qty")
+		assert.Nil(t, err)
+		assert.Len(t, history, 1)
+		assert.Equal(t, "5", *history[0].Before)
+		assert.Equal(t, "4", *history[0].After)
+
+		history, err = log.Timeline(context.Background(), "products", "2", "name")
+		assert.Nil(t, err)
+		assert.Len(t, history, 1)
+		assert.Nil(t, history[0].Before)
+		assert.Equal(t, "B", *history[0].After)
+
+		history, err = log.Timeline(context.Background(), "products", "1", "qty")
+		assert.Nil(t, err)
+		assert.Empty(t, history)
+	})
+
+	t.Run("rolled back with the update", func(t *testing.T) {
+		total := 0
+		assert.Nil(t, db.Get(&total, "SELECT COUNT(*) FROM audit_log"))
+		_, err := products.UpdateBulk(ctx, []sql.Update[repo.ProductPayload, repo.ProductCondition]{
+			{Payload: repo.ProductPayload{Price: &price}, Condition: repo.ProductCondition{SKU: &a}},
+			{Payload: repo.ProductPayload{SKU: &a}, Condition: repo.ProductCondition{SKU: &b}},
+		})
+		assert.ErrorIs(t, err, sql.ErrDuplicateKey)
+		after := 0
+		assert.Nil(t, db.Get(&after, "SELECT COUNT(*) FROM audit_log"))
+		assert.Equal(t, total, after)
+	})
+}
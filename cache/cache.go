@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Cache reads through a Store and collapses concurrent misses of one key
+// into a single load. Entries belong to a table: Invalidate drops its query
+// results and the rows of the given primary keys. A load that overlaps an
+// invalidation of its table is returned but not stored.
+type Cache struct {
+	store Store
+
+	mu     sync.Mutex
+	tables map[string]*generation
+	calls  map[string]*call
+}
+
+// generation versions the keys of a table, bumping it makes the old entries
+// unreachable until the store evicts them.
+type generation struct {
+	queries uint64
+	rows    uint64
+}
+
+type call struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+func New(store Store) *Cache {
+	return &Cache{store: store, tables: map[string]*generation{}, calls: map[string]*call{}}
+}
+
+// QueryKey derives the key of a query from its SQL and bound args.
+func QueryKey(query string, args []any) (string, error) {
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed encode args: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(query+"\x00"), encoded...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Query returns the cached result of the query with key on table, calling
+// load on a miss.
+func (c *Cache) Query(ctx context.Context, table string, key string, load func() (any, error)) (any, error) {
+	c.mu.Lock()
+	gen := c.generation(table)
+	storeKey, epoch := fmt.Sprintf("%s:q%d:%s", table, gen.queries, key), gen.queries
+	c.mu.Unlock()
+	return c.load(ctx, table, storeKey, epoch, load)
+}
+
+// Row returns the cached row of table with primary key id, calling load on a
+// miss.
+func (c *Cache) Row(ctx context.Context, table string, id any, load func() (any, error)) (any, error) {
+	c.mu.Lock()
+	gen := c.generation(table)
+	storeKey, epoch := rowKey(table, gen.rows, id), gen.queries
+	c.mu.Unlock()
+	return c.load(ctx, table, storeKey, epoch, load)
+}
+
+// Invalidate drops the cached queries of table and its rows with the given
+// primary keys, every row when no key is given.
+func (c *Cache) Invalidate(table string, ids ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gen := c.generation(table)
+	gen.queries++
+	if len(ids) == 0 {
+		gen.rows++
+		return
+	}
+	for _, id := range ids {
+		c.store.Delete(rowKey(table, gen.rows, id))
+	}
+}
+
+func (c *Cache) load(ctx context.Context, table string, storeKey string, epoch uint64, load func() (any, error)) (any, error) {
+	if value, ok := c.store.Get(storeKey); ok {
+		return value, nil
+	}
+	c.mu.Lock()
+	if shared, ok := c.calls[storeKey]; ok {
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-shared.done:
+		}
+		// The leading caller gave up, load for this one
+		if isContextError(shared.err) && ctx.Err() == nil {
+			return c.load(ctx, table, storeKey, epoch, load)
+		}
+		return shared.value, shared.err
+	}
+	current := &call{done: make(chan struct{})}
+	c.calls[storeKey] = current
+	c.mu.Unlock()
+
+	current.value, current.err = load()
+
+	c.mu.Lock()
+	delete(c.calls, storeKey)
+	if current.err == nil && c.generation(table).queries == epoch {
+		c.store.Set(storeKey, current.value)
+	}
+	c.mu.Unlock()
+	close(current.done)
+	return current.value, current.err
+}
+
+func (c *Cache) generation(table string) *generation {
+	gen, ok := c.tables[table]
+	if !ok {
+		gen = &generation{}
+		c.tables[table] = gen
+	}
+	return gen
+}
+
+func rowKey(table string, gen uint64, id any) string {
+	return fmt.Sprintf("%s:r%d:%v", table, gen, id)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryKey(t *testing.T) {
+	testCases := []struct {
+		QueryA string
+		ArgsA  []any
+		QueryB string
+		ArgsB  []any
+		Equal  bool
+	}{
+		{QueryA: "SELECT id FROM t WHERE id=?", ArgsA: []any{1}, QueryB: "SELECT id FROM t WHERE id=?", ArgsB: []any{1}, Equal: true},
+		{QueryA: "SELECT id FROM t WHERE id=?", ArgsA: []any{1}, QueryB: "SELECT id FROM t WHERE id=?", ArgsB: []any{2}, Equal: false},
+		{QueryA: "SELECT id FROM t WHERE id=?", ArgsA: []any{1}, QueryB: "SELECT sku FROM t WHERE id=?", ArgsB: []any{1}, Equal: false},
+		{QueryA: "SELECT id FROM t", ArgsA: nil, QueryB: "SELECT id FROM t", ArgsB: []any{}, Equal: false},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			a, err := QueryKey(tc.QueryA, tc.ArgsA)
+			assert.Nil(t, err)
+			b, err := QueryKey(tc.QueryB, tc.ArgsB)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.Equal, a == b)
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads through", func(t *testing.T) {
+		c := New(NewLRU(10, time.Minute))
+		loads := 0
+		load := func() (any, error) {
+			loads++
+			return loads, nil
+		}
+		for i := 0; i < 3; i++ {
+			value, err := c.Query(ctx, "products", "k", load)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, value)
+		}
+		_, err := c.Query(ctx, "products", "other", load)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("does not cache errors", func(t *testing.T) {
+		c := New(NewLRU(10, time.Minute))
+		loads := 0
+		load := func() (any, error) {
+			loads++
+			return nil, errors.New("down")
+		}
+		_, err := c.Row(ctx, "products", 1, load)
+		assert.NotNil(t, err)
+		_, err = c.Row(ctx, "products", 1, load)
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("invalidates by table and key", func(t *testing.T) {
+		c := New(NewLRU(10, time.Minute))
+		loads := map[string]int{}
+		load := func(key string) func() (any, error) {
+			return func() (any, error) {
+				loads[key]++
+				return loads[key], nil
+			}
+		}
+		read := func() {
+			c.Query(ctx, "products", "list", load("list"))
+			c.Row(ctx, "products", 1, load("1"))
+			c.Row(ctx, "products", 2, load("2"))
+			c.Row(ctx, "orders", 1, load("order"))
+		}
+		read()
+		c.Invalidate("products", 1)
+		read()
+		assert.Equal(t, map[string]int{"list": 2, "1": 2, "2": 1, "order": 1}, loads)
+		c.Invalidate("products")
+		read()
+		assert.Equal(t, map[string]int{"list": 3, "1": 3, "2": 2, "order": 1}, loads)
+	})
+
+	t.Run("collapses concurrent misses", func(t *testing.T) {
+		c := New(NewLRU(10, time.Minute))
+		var loads int32
+		release := make(chan struct{})
+		load := func() (any, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return "row", nil
+		}
+		var wg sync.WaitGroup
+		results := make([]any, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = c.Row(ctx, "products", 1, load)
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+		for _, result := range results {
+			assert.Equal(t, "row", result)
+		}
+	})
+
+	t.Run("drops loads overlapping an invalidation", func(t *testing.T) {
+		c := New(NewLRU(10, time.Minute))
+		loads := 0
+		_, err := c.Row(ctx, "products", 1, func() (any, error) {
+			loads++
+			c.Invalidate("products", 1)
+			return "stale", nil
+		})
+		assert.Nil(t, err)
+		value, err := c.Row(ctx, "products", 1, func() (any, error) {
+			loads++
+			return "fresh", nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "fresh", value)
+		assert.Equal(t, 2, loads)
+	})
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Store keeps cached values by key. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	Get(key string) (any, bool)
+	Set(key string, value any)
+	Delete(key string)
+}
+
+type entry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+// LRU is an in-memory Store holding up to capacity values, each for at most
+// ttl. The least recently used value is evicted first.
+type LRU struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewLRU(capacity int, ttl time.Duration) *LRU {
+	return &LRU{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (l *LRU) Get(key string) (any, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*entry)
+	if l.ttl > 0 && !l.now().Before(item.expires) {
+		l.remove(elem)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return item.value, true
+}
+
+func (l *LRU) Set(key string, value any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expires := l.now().Add(l.ttl)
+	if elem, ok := l.entries[key]; ok {
+		item := elem.Value.(*entry)
+		item.value, item.expires = value, expires
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for l.capacity > 0 && l.order.Len() > l.capacity {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *LRU) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.entries[key]; ok {
+		l.remove(elem)
+	}
+}
+
+// Len returns the number of values held, expired ones included until they
+// are read or evicted.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) remove(elem *list.Element) {
+	l.order.Remove(elem)
+	delete(l.entries, elem.Value.(*entry).key)
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	t.Run("evicts least recently used", func(t *testing.T) {
+		lru := NewLRU(2, time.Minute)
+		lru.Set("a", 1)
+		lru.Set("b", 2)
+		_, ok := lru.Get("a")
+		assert.True(t, ok)
+		lru.Set("c", 3)
+
+		testCases := []struct {
+			Key      string
+			Expected any
+			Found    bool
+		}{
+			{Key: "a", Expected: 1, Found: true},
+			{Key: "b", Expected: nil, Found: false},
+			{Key: "c", Expected: 3, Found: true},
+		}
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				value, ok := lru.Get(tc.Key)
+				assert.Equal(t, tc.Found, ok)
+				assert.Equal(t, tc.Expected, value)
+			})
+		}
+		assert.Equal(t, 2, lru.Len())
+	})
+
+	t.Run("expires after ttl", func(t *testing.T) {
+		now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		lru := NewLRU(10, time.Minute)
+		lru.now = func() time.Time { return now }
+		lru.Set("a", 1)
+		now = now.Add(59 * time.Second)
+		_, ok := lru.Get("a")
+		assert.True(t, ok)
+		now = now.Add(time.Second)
+		_, ok = lru.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("set replaces and delete removes", func(t *testing.T) {
+		lru := NewLRU(10, time.Minute)
+		lru.Set("a", 1)
+		lru.Set("a", 2)
+		value, _ := lru.Get("a")
+		assert.Equal(t, 2, value)
+		assert.Equal(t, 1, lru.Len())
+		lru.Delete("a")
+		lru.Delete("missing")
+		_, ok := lru.Get("a")
+		assert.False(t, ok)
+	})
+}
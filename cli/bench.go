@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	StrategyCreate = "create"
+	StrategyUpdate = "update"
+	StrategySingle = "single"
+)
+
+type benchResult struct {
+	Strategy   string  `json:"strategy" db:"strategy"`
+	Rows       int     `json:"rows" db:"rows"`
+	Batch      int     `json:"batch" db:"batch"`
+	Failed     int     `json:"failed" db:"failed"`
+	Seconds    float64 `json:"seconds" db:"seconds"`
+	RowsPerSec float64 `json:"rows_per_sec" db:"rows_per_sec"`
+}
+
+func (a *App) bench(args []string) error {
+	fs := a.flagSet("bench")
+	rows := fs.Int("rows", 15_000, "number of rows")
+	batch := fs.Int("batch", 1000, "rows per bulk call")
+	strategy := fs.String("strategy", StrategyUpdate, "create, update or single")
+	prefix := fs.String("prefix", "sku_", "SKU prefix of generated rows")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rows < 1 || *batch < 1 {
+		return errors.New("-rows and -batch must be positive")
+	}
+
+	payloads := make([]repo.ProductPayload, 0, *rows)
+	for i := 1; i <= *rows; i++ {
+		SKU := fmt.Sprintf("%s%v", *prefix, i)
+		name := fmt.Sprintf("product_%v", i)
+		qty := 5
+		price := 10_000.0
+		payloads = append(payloads, repo.ProductPayload{SKU: &SKU, Name: &name, Qty: &qty, Price: &price})
+	}
+
+	failed := 0
+	start := time.Now()
+	switch *strategy {
+	case StrategyCreate:
+		for from := 0; from < len(payloads); from += *batch {
+			to := batchEnd(from, *batch, len(payloads))
+			if _, err := a.repo.CreateBulk(a.ctx, payloads[from:to]); err != nil {
+				return fmt.Errorf("failed create rows %d-%d: %w", from+1, to, err)
+			}
+		}
+	case StrategyUpdate:
+		for from := 0; from < len(payloads); from += *batch {
+			to := batchEnd(from, *batch, len(payloads))
+			inputs := make([]sql.Update[repo.ProductPayload, repo.ProductCondition], 0, to-from)
+			for _, payload := range payloads[from:to] {
+				inputs = append(inputs, sql.Update[repo.ProductPayload, repo.ProductCondition]{
+					Payload:   payload,
+					Condition: repo.ProductCondition{SKU: payload.SKU},
+				})
+			}
+			// A failing row rolls its batch back and counts it as failed;
+			// other errors stop the run.
+			fails, err := a.repo.UpdateBulk(a.ctx, inputs)
+			var rowErr *sql.RowError
+			if err != nil && !errors.As(err, &rowErr) {
+				return fmt.Errorf("failed update rows %d-%d: %w", from+1, to, err)
+			}
+			failed += len(fails)
+		}
+	case StrategySingle:
+		for _, payload := range payloads {
+			if _, err := a.repo.Create(a.ctx, payload); err != nil {
+				failed++
+			}
+		}
+	default:
+		return fmt.Errorf("unknown strategy %q", *strategy)
+	}
+	elapsed := time.Since(start)
+
+	result := benchResult{
+		Strategy:   *strategy,
+		Rows:       *rows,
+		Batch:      *batch,
+		Failed:     failed,
+		Seconds:    elapsed.Seconds(),
+		RowsPerSec: float64(*rows) / elapsed.Seconds(),
+	}
+	if a.output == FormatJSON {
+		return a.writeJSON(result)
+	}
+	return writeRows(a, []string{"strategy", "rows", "batch", "failed", "seconds", "rows_per_sec"}, []benchResult{result})
+}
+
+func batchEnd(from, batch, length int) int {
+	if from+batch > length {
+		return length
+	}
+	return from + batch
+}
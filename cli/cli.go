@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"bulk/repo"
+	"bulk/schema"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	EnvDSN    = "BULK_DSN"
+	EnvDriver = "BULK_DRIVER"
+
+	DefaultDriver = "mysql"
+)
+
+const usage = `Usage: bulk [flags] <command> [args]
+
+Commands:
+  products list|get|create|update|delete
+  import   load products from a CSV or JSON file
+  export   dump products to a CSV or JSON file
+  bench    measure bulk create/update throughput
+  migrate  up|down|status of the schema migrations
+  schema   check the tables against the models
+
+Flags:
+`
+
+type App struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Getenv func(string) string
+
+	// Connect opens the database; NewRepo builds the product repo on top of it.
+	Connect func(driver, dsn string) (*sqlx.DB, error)
+	NewRepo func(db *sqlx.DB) repo.ProductRepo
+
+	ctx    context.Context
+	output string
+	db     *sqlx.DB
+	repo   repo.ProductRepo
+}
+
+func New() *App {
+	return &App{
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		Getenv:  os.Getenv,
+		Connect: sqlx.Connect,
+		NewRepo: func(db *sqlx.DB) repo.ProductRepo { return repo.NewProductSQLRepo(db) },
+	}
+}
+
+func (a *App) Run(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	a.ctx = ctx
+	if err := a.run(args); err != nil {
+		fmt.Fprintf(a.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (a *App) run(args []string) error {
+	fs := flag.NewFlagSet("bulk", flag.ContinueOnError)
+	fs.SetOutput(a.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(a.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	driver := fs.String("driver", a.env(EnvDriver, DefaultDriver), "database driver, env "+EnvDriver)
+	dsn := fs.String("dsn", a.env(EnvDSN, ""), "database DSN, env "+EnvDSN+", mysql needs parseTime=true")
+	fs.StringVar(&a.output, "output", FormatTable, "output format: table or json")
+	checkSchema := fs.Bool("check-schema", false, "fail before running the command when the tables drift from the models")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if a.output != FormatTable && a.output != FormatJSON {
+		return fmt.Errorf("unknown output format %q", a.output)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("command required")
+	}
+
+	commands := map[string]func([]string) error{
+		"products": a.products,
+		"import":   a.importProducts,
+		"export":   a.exportProducts,
+		"bench":    a.bench,
+		"migrate":  a.migrate,
+		"schema":   a.schema,
+	}
+	command, ok := commands[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+
+	if a.repo == nil {
+		if *dsn == "" {
+			return fmt.Errorf("dsn required, use -dsn or %s", EnvDSN)
+		}
+		db, err := a.Connect(*driver, *dsn)
+		if err != nil {
+			return fmt.Errorf("failed to connect database: %w", err)
+		}
+		defer db.Close()
+		a.db = db
+		a.repo = a.NewRepo(db)
+	}
+	if *checkSchema && a.db != nil && fs.Arg(0) != "migrate" && fs.Arg(0) != "schema" {
+		if err := schema.Verify(a.ctx, a.db, models...); err != nil {
+			return err
+		}
+	}
+	return command(fs.Args()[1:])
+}
+
+func (a *App) env(key, fallback string) string {
+	if v := a.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (a *App) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.Stderr)
+	return fs
+}
+
+func splitList(value string) []string {
+	result := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
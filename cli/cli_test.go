@@ -0,0 +1,297 @@
+package cli
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"bulk/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRepo struct {
+	repo.ProductRepo
+	data    []repo.ProductModel
+	created []repo.ProductPayload
+	updated []sql.Update[repo.ProductPayload, repo.ProductCondition]
+	bulkErr error
+}
+
+func (r *fakeRepo) Select(ctx context.Context, fields []string, condition *repo.ProductCondition, paginate *utils.Paginate) (utils.Result[repo.ProductModel], error) {
+	data := []repo.ProductModel{}
+	for _, item := range r.data {
+		if condition != nil && condition.ID != nil && *condition.ID != *item.ID {
+			continue
+		}
+		data = append(data, item)
+	}
+	total := len(data)
+	if paginate != nil {
+		from := batchEnd(0, paginate.GetOffset(), len(data))
+		data = data[from:batchEnd(from, paginate.Limit, len(data))]
+	}
+	return utils.Pagination(data, total, paginate), nil
+}
+
+func (r *fakeRepo) GetByID(ctx context.Context, id any) (repo.ProductModel, error) {
+	for _, item := range r.data {
+		if *item.ID == id {
+			return item, nil
+		}
+	}
+	return repo.ProductModel{}, &sql.NotFoundError{Table: repo.ProductTable}
+}
+
+func (r *fakeRepo) Stream(ctx context.Context, fields []string, condition *repo.ProductCondition, paginate *utils.Paginate, fn func(repo.ProductModel) error) error {
+	for _, item := range r.data {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) Create(ctx context.Context, payload repo.ProductPayload) (repo.ProductModel, error) {
+	r.created = append(r.created, payload)
+	id := len(r.data) + len(r.created)
+	return repo.ProductModel{ID: &id, SKU: payload.SKU, Name: payload.Name, Price: payload.Price, Qty: payload.Qty}, nil
+}
+
+func (r *fakeRepo) CreateBulk(ctx context.Context, payload []repo.ProductPayload) ([]int64, error) {
+	ids := make([]int64, 0, len(payload))
+	for _, item := range payload {
+		r.created = append(r.created, item)
+		ids = append(ids, int64(len(r.data)+len(r.created)))
+	}
+	return ids, nil
+}
+
+func (r *fakeRepo) Update(ctx context.Context, payload repo.ProductPayload, condition repo.ProductCondition, opts ...sql.QueryOption) error {
+	r.updated = append(r.updated, sql.Update[repo.ProductPayload, repo.ProductCondition]{Payload: payload, Condition: condition, Options: opts})
+	return nil
+}
+
+func (r *fakeRepo) UpdateBulk(ctx context.Context, payload []sql.Update[repo.ProductPayload, repo.ProductCondition]) ([]sql.Update[repo.ProductPayload, repo.ProductCondition], error) {
+	if r.bulkErr != nil {
+		return payload, r.bulkErr
+	}
+	r.updated = append(r.updated, payload...)
+	return nil, nil
+}
+
+func newTestApp(data ...repo.ProductModel) (*App, *fakeRepo, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	fake := &fakeRepo{data: data}
+	app := New()
+	app.Stdout = stdout
+	app.Stderr = stderr
+	app.Getenv = func(string) string { return "" }
+	app.repo = fake
+	return app, fake, stdout, stderr
+}
+
+func product(id int, sku string, price float64) repo.ProductModel {
+	name := "product " + sku
+	qty := 5
+	return repo.ProductModel{ID: &id, SKU: &sku, Name: &name, Price: &price, Qty: &qty}
+}
+
+func TestProducts(t *testing.T) {
+	data := []repo.ProductModel{product(1, "a", 10.5), product(2, "b", 20)}
+
+	t.Run("list table", func(t *testing.T) {
+		app, _, stdout, _ := newTestApp(data...)
+		code := app.Run([]string{"products", "list", "-fields", "id,sku,price"})
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "ID  SKU  PRICE\n1   a    10.5\n2   b    20\npage 1, limit 10, total 2\n", stdout.String())
+	})
+
+	t.Run("get json", func(t *testing.T) {
+		app, _, stdout, _ := newTestApp(data...)
+		code := app.Run([]string{"-output", "json", "products", "get", "-fields", "sku", "2"})
+		assert.Equal(t, 0, code)
+		assert.JSONEq(t, `[{"sku":"b"}]`, stdout.String())
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		app, _, _, stderr := newTestApp(data...)
+		code := app.Run([]string{"products", "get", "3"})
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "product 3 not found")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		app, fake, _, _ := newTestApp(data...)
+		code := app.Run([]string{"products", "update", "-where", "sku=a", "-price", "12"})
+		assert.Equal(t, 0, code)
+		assert.Len(t, fake.updated, 1)
+		assert.Equal(t, "a", *fake.updated[0].Condition.SKU)
+		assert.Equal(t, 12.0, *fake.updated[0].Payload.Price)
+		assert.Nil(t, fake.updated[0].Payload.Name)
+	})
+
+	t.Run("list unknown deleted scope", func(t *testing.T) {
+		app, _, _, stderr := newTestApp(data...)
+		code := app.Run([]string{"products", "list", "-deleted", "all"})
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), `unknown -deleted "all"`)
+	})
+
+	t.Run("update qty delta", func(t *testing.T) {
+		app, fake, _, _ := newTestApp(data...)
+		code := app.Run([]string{"products", "update", "-where", "sku=a", "-qty-delta", "-3"})
+		assert.Equal(t, 0, code)
+		assert.Len(t, fake.updated, 1)
+		assert.Len(t, fake.updated[0].Options, 2)
+		assert.Nil(t, fake.updated[0].Payload.Qty)
+
+		code = app.Run([]string{"products", "update", "-where", "sku=a", "-qty", "1", "-qty-delta", "2"})
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("update without where", func(t *testing.T) {
+		app, _, _, _ := newTestApp(data...)
+		code := app.Run([]string{"products", "update", "-price", "12"})
+		assert.Equal(t, 1, code)
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("import csv", func(t *testing.T) {
+		file := filepath.Join(dir, "in.csv")
+		assert.Nil(t, os.WriteFile(file, []byte("sku,name,price,qty\na,A,1.5,3\nb,B,,4\n"), 0o644))
+		app, fake, _, _ := newTestApp()
+		code := app.Run([]string{"import", "-file", file, "-batch", "1"})
+		assert.Equal(t, 0, code)
+		assert.Len(t, fake.created, 2)
+		assert.Equal(t, 1.5, *fake.created[0].Price)
+		assert.Nil(t, fake.created[1].Price)
+	})
+
+	t.Run("import rejects", func(t *testing.T) {
+		file := filepath.Join(dir, "in.ndjson")
+		assert.Nil(t, os.WriteFile(file, []byte("{\"sku\":\"a\"}\n{\"sku\":\"b\",\"qty\":\"x\"}\n"), 0o644))
+		app, fake, stdout, _ := newTestApp()
+		code := app.Run([]string{"import", "-file", file})
+		assert.Equal(t, 0, code)
+		assert.Len(t, fake.created, 1)
+		assert.Contains(t, stdout.String(), "2     validation failed: qty: must be an integer")
+		assert.Contains(t, stdout.String(), "imported 1 of 2 rows, 0 skipped, 1 rejected")
+	})
+
+	t.Run("export csv", func(t *testing.T) {
+		app, _, stdout, _ := newTestApp(product(1, "a", 10.5), product(2, "b", 20))
+		code := app.Run([]string{"export", "-format", "csv", "-fields", "sku,price"})
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "sku,price\na,10.5\nb,20\n", stdout.String())
+	})
+
+	t.Run("export ndjson file", func(t *testing.T) {
+		file := filepath.Join(dir, "out.ndjson")
+		app, _, stdout, _ := newTestApp(product(1, "a", 10.5))
+		code := app.Run([]string{"export", "-file", file, "-fields", "id,sku"})
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "exported 1 products\n", stdout.String())
+		data, err := os.ReadFile(file)
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"id\":1,\"sku\":\"a\"}\n", string(data))
+	})
+}
+
+func TestBench(t *testing.T) {
+	app, fake, stdout, _ := newTestApp()
+	code := app.Run([]string{"-output", "json", "bench", "-rows", "5", "-batch", "2", "-strategy", "create"})
+	assert.Equal(t, 0, code)
+	assert.Len(t, fake.created, 5)
+	assert.Contains(t, stdout.String(), `"strategy": "create"`)
+
+	app, fake, _, stderr := newTestApp()
+	fake.bulkErr = errors.New("connection refused")
+	code = app.Run([]string{"bench", "-rows", "5", "-batch", "2", "-strategy", "update"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "failed update rows 1-2: connection refused")
+
+	app, fake, stdout, _ = newTestApp()
+	fake.bulkErr = &sql.RowError{Index: 0, Err: errors.New("qty must be at least 0")}
+	code = app.Run([]string{"-output", "json", "bench", "-rows", "5", "-batch", "2", "-strategy", "update"})
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout.String(), `"failed": 5`)
+}
+
+func TestMigrate(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	run := func(args ...string) (int, string, string) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		app := New()
+		app.Stdout, app.Stderr = stdout, stderr
+		app.Getenv = func(string) string { return "" }
+		code := app.Run(append([]string{"-driver", "sqlite3", "-dsn", dsn}, args...))
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, stdout, stderr := run("migrate", "up")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "applied 4 migrations\n", stdout)
+	assert.Contains(t, stderr, "applied 1_create_products")
+
+	code, stdout, _ = run("products", "create", "-sku", "a", "-qty", "1")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "A")
+
+	code, stdout, _ = run("migrate", "down", "-steps", "2")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "rolled back 2 migrations\n", stdout)
+
+	code, stdout, _ = run("-output", "json", "migrate", "status")
+	assert.Equal(t, 0, code)
+	status := []map[string]any{}
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &status))
+	assert.Len(t, status, 4)
+	assert.Equal(t, true, status[1]["applied"])
+	assert.Equal(t, false, status[2]["applied"])
+
+	code, _, stderr = run("migrate", "sideways")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `unknown migrate subcommand "sideways"`)
+}
+
+func TestSchema(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	run := func(args ...string) (int, string, string) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		app := New()
+		app.Stdout, app.Stderr = stdout, stderr
+		app.Getenv = func(string) string { return "" }
+		code := app.Run(append([]string{"-driver", "sqlite3", "-dsn", dsn}, args...))
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, stdout, stderr := run("-output", "json", "schema", "check")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "4 differences found: schema drift")
+	drifts := []map[string]any{}
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &drifts))
+	assert.Len(t, drifts, 4)
+	assert.Equal(t, "missing_table", drifts[0]["kind"])
+
+	code, _, stderr = run("-check-schema", "products", "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "products: missing_table")
+
+	code, _, _ = run("migrate", "up")
+	assert.Equal(t, 0, code)
+	code, stdout, stderr = run("schema", "check")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "schema matches 4 tables\n", stdout)
+	code, _, stderr = run("-check-schema", "products", "list")
+	assert.Equal(t, 0, code, stderr)
+}
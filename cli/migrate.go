@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bulk/db/sql"
+	"bulk/migrate"
+	"errors"
+	"fmt"
+)
+
+var migrationFields = []string{"version", "name", "applied", "applied_at", "modified", "missing"}
+
+func (a *App) migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate subcommand required: up|down|status")
+	}
+	if a.db == nil {
+		return errors.New("migrate needs a database connection")
+	}
+	migrations, err := migrate.Builtin(sql.DialectOf(a.db.DriverName()))
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(a.db, migrations)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		fs := a.flagSet("migrate up")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		done, err := migrator.Up(a.ctx)
+		for _, migration := range done {
+			fmt.Fprintf(a.Stderr, "applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed migrate up: %w", err)
+		}
+		return a.writeMessage("applied %d migrations", len(done))
+	case "down":
+		fs := a.flagSet("migrate down")
+		steps := fs.Int("steps", 1, "number of migrations to roll back")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return errors.New("-steps must be at least 1")
+		}
+		done, err := migrator.Down(a.ctx, *steps)
+		for _, migration := range done {
+			fmt.Fprintf(a.Stderr, "rolled back %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed migrate down: %w", err)
+		}
+		return a.writeMessage("rolled back %d migrations", len(done))
+	case "status":
+		status, err := migrator.Status(a.ctx)
+		if err != nil {
+			return fmt.Errorf("failed migrate status: %w", err)
+		}
+		return writeRows(a, migrationFields, status)
+	}
+	return fmt.Errorf("unknown migrate subcommand %q", args[0])
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bulk/db/sql"
+	"bulk/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+func (a *App) writeJSON(v any) error {
+	encoder := json.NewEncoder(a.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeRows renders tagged structs either as a table of the given columns or
+// as JSON objects keyed by column.
+func writeRows[T any](a *App, columns []string, rows []T) error {
+	records, err := project(columns, rows)
+	if err != nil {
+		return err
+	}
+	if a.output == FormatJSON {
+		return a.writeJSON(records)
+	}
+	return writeTable(a.Stdout, columns, records)
+}
+
+func project[T any](columns []string, rows []T) ([]map[string]any, error) {
+	records := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		values, err := utils.StructToMap(row, sql.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed map row: %w", err)
+		}
+		record := map[string]any{}
+		for _, column := range columns {
+			record[column] = values[column]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func writeTable(w io.Writer, columns []string, records []map[string]any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, strings.ToUpper(column))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, record := range records {
+		cells := make([]string, 0, len(columns))
+		for _, column := range columns {
+			cells = append(cells, formatCell(record[column]))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func formatCell(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		return val
+	}
+	return fmt.Sprint(v)
+}
+
+func (a *App) writeMessage(format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	if a.output == FormatJSON {
+		return a.writeJSON(map[string]string{"message": message})
+	}
+	_, err := fmt.Fprintln(a.Stdout, message)
+	return err
+}
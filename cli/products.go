@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"bulk/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+var productFields = sql.Names(repo.ProductCols.ID, repo.ProductCols.SKU, repo.ProductCols.Name, repo.ProductCols.Price, repo.ProductCols.Qty)
+
+func (a *App) products(args []string) error {
+	if len(args) == 0 {
+		return errors.New("products subcommand required: list|get|create|update|delete|restore")
+	}
+	subcommands := map[string]func([]string) error{
+		"list":    a.productsList,
+		"get":     a.productsGet,
+		"create":  a.productsCreate,
+		"update":  a.productsUpdate,
+		"delete":  a.productsDelete,
+		"restore": a.productsRestore,
+	}
+	subcommand, ok := subcommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown products subcommand %q", args[0])
+	}
+	return subcommand(args[1:])
+}
+
+func (a *App) productsList(args []string) error {
+	fs := a.flagSet("products list")
+	fields := fs.String("fields", "", "comma separated columns, default all")
+	query := fs.String("query", "", `filter and paging as URL query, e.g. "sku=a&sku=b&price_gte=10&sort=-price&page=2"`)
+	deleted := fs.String("deleted", "", "include soft deleted products: with|only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx := a.ctx
+	switch *deleted {
+	case "":
+	case "with":
+		ctx = sql.WithDeleted(ctx)
+	case "only":
+		ctx = sql.OnlyDeleted(ctx)
+	default:
+		return fmt.Errorf("unknown -deleted %q, use with or only", *deleted)
+	}
+	columns := productFields
+	if *fields != "" {
+		columns = splitList(*fields)
+	}
+	values, err := url.ParseQuery(*query)
+	if err != nil {
+		return fmt.Errorf("failed parse query: %w", err)
+	}
+	condition := repo.ProductCondition{}
+	paginate := utils.Paginate{}
+	if err := sql.DecodeQuery(values, &condition, &paginate); err != nil {
+		return err
+	}
+
+	result, err := a.repo.Select(ctx, append([]string{}, columns...), &condition, &paginate)
+	if err != nil {
+		return fmt.Errorf("failed list products: %w", err)
+	}
+	if a.output == FormatJSON {
+		records, err := project(columns, result.Data)
+		if err != nil {
+			return err
+		}
+		return a.writeJSON(utils.Result[map[string]any]{Data: records, Page: result.Page, Limit: result.Limit, Total: result.Total})
+	}
+	if err := writeRows(a, columns, result.Data); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.Stdout, "page %d, limit %d, total %d\n", result.Page, result.Limit, result.Total)
+	return err
+}
+
+func (a *App) productsGet(args []string) error {
+	fs := a.flagSet("products get")
+	fields := fs.String("fields", "", "comma separated columns, default all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: products get [-fields ...] <id>")
+	}
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid id %q", fs.Arg(0))
+	}
+	columns := productFields
+	if *fields != "" {
+		columns = splitList(*fields)
+	}
+	item, err := a.repo.GetByID(a.ctx, id)
+	if errors.Is(err, sql.ErrNotFound) {
+		return fmt.Errorf("product %d not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed get product: %w", err)
+	}
+	return writeRows(a, columns, []repo.ProductModel{item})
+}
+
+func (a *App) productsCreate(args []string) error {
+	fs := a.flagSet("products create")
+	payload := productPayloadFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	p := payload(fs)
+	if p.SKU == nil {
+		return errors.New("-sku is required")
+	}
+	item, err := a.repo.Create(a.ctx, p)
+	if err != nil {
+		return fmt.Errorf("failed create product: %w", err)
+	}
+	return writeRows(a, productFields, []repo.ProductModel{item})
+}
+
+func (a *App) productsUpdate(args []string) error {
+	fs := a.flagSet("products update")
+	where := fs.String("where", "", `condition as URL query, e.g. "sku=a" or "id=1&id=2"`)
+	payload := productPayloadFlags(fs)
+	delta := fs.Int("qty-delta", 0, "change qty atomically, a negative delta fails when stock is short")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	condition, err := productCondition(*where)
+	if err != nil {
+		return err
+	}
+	p := payload(fs)
+	opts := []sql.QueryOption{}
+	if *delta != 0 {
+		if p.Qty != nil {
+			return errors.New("-qty and -qty-delta are exclusive")
+		}
+		opts = append(opts, sql.Set("qty", sql.Incr(*delta)))
+		if *delta < 0 {
+			opts = append(opts, sql.Guard("qty >= ?", -*delta))
+		}
+	}
+	values, _ := utils.StructToMap(p, sql.Tag)
+	delete(values, "version")
+	if len(values) == 0 && len(opts) == 0 {
+		return errors.New("nothing to update, set at least one of -sku, -name, -price, -qty, -qty-delta")
+	}
+	if err := a.repo.Update(a.ctx, p, condition, opts...); err != nil {
+		return fmt.Errorf("failed update product: %w", err)
+	}
+	return a.writeMessage("updated products where %s", *where)
+}
+
+func (a *App) productsDelete(args []string) error {
+	fs := a.flagSet("products delete")
+	where := fs.String("where", "", `condition as URL query, e.g. "sku=a" or "id=1&id=2"`)
+	hard := fs.Bool("hard", false, "remove the rows instead of marking them deleted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	condition, err := productCondition(*where)
+	if err != nil {
+		return err
+	}
+	remove := a.repo.Delete
+	if *hard {
+		remove = a.repo.HardDelete
+	}
+	if err := remove(a.ctx, condition); err != nil {
+		return fmt.Errorf("failed delete product: %w", err)
+	}
+	return a.writeMessage("deleted products where %s", *where)
+}
+
+func (a *App) productsRestore(args []string) error {
+	fs := a.flagSet("products restore")
+	where := fs.String("where", "", `condition as URL query, e.g. "sku=a" or "id=1&id=2"`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	condition, err := productCondition(*where)
+	if err != nil {
+		return err
+	}
+	if err := a.repo.Restore(a.ctx, condition); err != nil {
+		return fmt.Errorf("failed restore product: %w", err)
+	}
+	return a.writeMessage("restored products where %s", *where)
+}
+
+func productCondition(where string) (repo.ProductCondition, error) {
+	condition := repo.ProductCondition{}
+	if where == "" {
+		return condition, errors.New("-where is required")
+	}
+	values, err := url.ParseQuery(where)
+	if err != nil {
+		return condition, fmt.Errorf("failed parse where: %w", err)
+	}
+	if err := sql.DecodeQuery(values, &condition, nil); err != nil {
+		return condition, err
+	}
+	return condition, nil
+}
+
+// productPayloadFlags registers payload flags and returns a function building
+// the payload from only the flags given on the command line.
+func productPayloadFlags(fs *flag.FlagSet) func(*flag.FlagSet) repo.ProductPayload {
+	sku := fs.String("sku", "", "product SKU")
+	name := fs.String("name", "", "product name")
+	price := fs.Float64("price", 0, "product price")
+	qty := fs.Int("qty", 0, "product quantity")
+	version := fs.Int("version", 0, "expected product version, the update fails if it changed")
+	return func(fs *flag.FlagSet) repo.ProductPayload {
+		payload := repo.ProductPayload{}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "sku":
+				payload.SKU = sku
+			case "name":
+				payload.Name = name
+			case "price":
+				payload.Price = price
+			case "qty":
+				payload.Qty = qty
+			case "version":
+				payload.Version = version
+			}
+		})
+		return payload
+	}
+}
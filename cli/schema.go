@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bulk/audit"
+	"bulk/outbox"
+	"bulk/repo"
+	"bulk/schema"
+	"errors"
+	"fmt"
+)
+
+// models are the tables the CLI reads and writes, checked for drift against
+// the database by `schema check` and -check-schema.
+var models = []schema.Model{
+	{Table: repo.ProductTable, Model: repo.ProductModel{}},
+	{Table: repo.ReservationTable, Model: repo.ReservationModel{}},
+	{Table: audit.HistoryTable, Model: audit.HistoryModel{}},
+	{Table: outbox.Table, Model: outbox.EventModel{}},
+}
+
+var driftFields = []string{"table", "kind", "name", "expected", "actual"}
+
+func (a *App) schema(args []string) error {
+	if len(args) == 0 {
+		return errors.New("schema subcommand required: check")
+	}
+	if a.db == nil {
+		return errors.New("schema needs a database connection")
+	}
+	switch args[0] {
+	case "check":
+		drifts, err := schema.Check(a.ctx, a.db, models...)
+		if err != nil {
+			return fmt.Errorf("failed schema check: %w", err)
+		}
+		if len(drifts) == 0 {
+			return a.writeMessage("schema matches %d tables", len(models))
+		}
+		if err := writeRows(a, driftFields, drifts); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d differences found: %w", len(drifts), schema.ErrDrift)
+	}
+	return fmt.Errorf("unknown schema subcommand %q", args[0])
+}
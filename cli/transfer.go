@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"bulk/transfer"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+)
+
+func (a *App) importProducts(args []string) error {
+	fs := a.flagSet("import")
+	file := fs.String("file", "", "input file, - for stdin")
+	format := fs.String("format", "", "csv, ndjson or json, default from file extension")
+	mode := fs.String("mode", string(transfer.ModeInsert), "insert or upsert (matched on sku)")
+	batch := fs.Int("batch", transfer.DefaultBatchSize, "rows per bulk write")
+	checkpoint := fs.String("checkpoint", "", "checkpoint file to resume an interrupted import")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if *batch < 1 {
+		return errors.New("-batch must be positive")
+	}
+	inputFormat, err := transfer.DetectFormat(*format, *file)
+	if err != nil {
+		return err
+	}
+	f, err := openInput(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	options := transfer.ImportOptions[repo.ProductPayload]{
+		Format:    inputFormat,
+		Mode:      transfer.Mode(*mode),
+		BatchSize: *batch,
+	}
+	if *checkpoint != "" {
+		options.Checkpoint = transfer.FileCheckpoint(*checkpoint)
+	}
+	report, err := transfer.NewImporter(transfer.ProductSink(a.repo), options).Import(a.ctx, f)
+	if err != nil {
+		return fmt.Errorf("failed import %s: %w", *file, err)
+	}
+
+	if a.output == FormatJSON {
+		return a.writeJSON(report)
+	}
+	if len(report.Rejected) > 0 {
+		if err := writeRows(a, []string{"line", "reason"}, report.Rejected); err != nil {
+			return err
+		}
+	}
+	return a.writeMessage("imported %d of %d rows, %d skipped, %d rejected", report.Imported, report.Rows, report.Skipped, len(report.Rejected))
+}
+
+func (a *App) exportProducts(args []string) error {
+	fs := a.flagSet("export")
+	file := fs.String("file", "-", "output file, - for stdout")
+	format := fs.String("format", "", "csv, ndjson or json, default from file extension")
+	fields := fs.String("fields", "", "comma separated columns, default all")
+	query := fs.String("query", "", `filter as URL query, e.g. "sku=a&sku=b&price_gte=10"`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "-" && *format == "" {
+		*format = string(transfer.FormatCSV)
+	}
+	outputFormat, err := transfer.DetectFormat(*format, *file)
+	if err != nil {
+		return err
+	}
+	columns := productFields
+	if *fields != "" {
+		columns = splitList(*fields)
+	}
+	values, err := url.ParseQuery(*query)
+	if err != nil {
+		return fmt.Errorf("failed parse query: %w", err)
+	}
+	condition := repo.ProductCondition{}
+	if err := sql.DecodeQuery(values, &condition, nil); err != nil {
+		return err
+	}
+
+	w := a.Stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return fmt.Errorf("failed create %s: %w", *file, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	count, err := transfer.ExportProducts(a.ctx, a.repo, w, outputFormat, columns, condition)
+	if err != nil {
+		return fmt.Errorf("failed export: %w", err)
+	}
+	if *file == "-" {
+		return nil
+	}
+	return a.writeMessage("exported %d products", count)
+}
+
+func openInput(file string) (io.ReadCloser, error) {
+	if file == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed open %s: %w", file, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,89 @@
+// Command gen writes the model, payload, condition and repo of a table, and a
+// conformance test, from a DDL file or a live SQLite database. Meant for go
+// generate:
+//
+//	//go:generate go run bulk/cmd/gen -ddl schema.sql -table categories -package example
+package main
+
+import (
+	"bulk/db/sql"
+	"bulk/gen"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	ddl := fs.String("ddl", "", "schema file of CREATE TABLE and CREATE INDEX statements")
+	sqlite := fs.String("sqlite", "", "sqlite database file to read the schema from instead")
+	table := fs.String("table", "", "table to generate")
+	pkg := fs.String("package", os.Getenv("GOPACKAGE"), "package of the generated files, $GOPACKAGE under go generate")
+	typ := fs.String("type", "", "name prefix of the generated types, the singular of the table by default")
+	out := fs.String("out", "", "generated file, <table>_gen.go by default; the test goes next to it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *table == "" || (*ddl == "") == (*sqlite == "") {
+		return fmt.Errorf("-table and one of -ddl or -sqlite required")
+	}
+
+	tables := []sql.TableDef{}
+	source := ""
+	if *ddl != "" {
+		script, err := os.ReadFile(*ddl)
+		if err != nil {
+			return fmt.Errorf("failed read ddl: %w", err)
+		}
+		if tables, err = gen.ParseDDL(string(script)); err != nil {
+			return err
+		}
+		source = filepath.Base(*ddl)
+	} else {
+		db, err := sqlx.Connect("sqlite3", *sqlite+"?mode=ro")
+		if err != nil {
+			return fmt.Errorf("failed open sqlite: %w", err)
+		}
+		defer db.Close()
+		if tables, err = gen.Inspect(context.Background(), db, *table); err != nil {
+			return err
+		}
+		source = filepath.Base(*sqlite)
+	}
+
+	for _, def := range tables {
+		if def.Name != *table {
+			continue
+		}
+		file, err := gen.Generate(def, gen.Config{Package: *pkg, Type: *typ, Source: source})
+		if err != nil {
+			return err
+		}
+		path := *out
+		if path == "" {
+			path = *table + "_gen.go"
+		}
+		if err := os.WriteFile(path, file.Code, 0o644); err != nil {
+			return fmt.Errorf("failed write %s: %w", path, err)
+		}
+		test := strings.TrimSuffix(path, ".go") + "_test.go"
+		if err := os.WriteFile(test, file.Test, 0o644); err != nil {
+			return fmt.Errorf("failed write %s: %w", test, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("table %s not found in %s", *table, source)
+}
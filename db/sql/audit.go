@@ -0,0 +1,77 @@
+package sql
+
+import (
+	"bulk/utils"
+	"context"
+	"reflect"
+	"time"
+)
+
+const (
+	OptAutoCreateTime = "autoCreateTime"
+	OptAutoUpdateTime = "autoUpdateTime"
+	OptCreatedBy      = "createdBy"
+	OptUpdatedBy      = "updatedBy"
+)
+
+type actorKey struct{}
+
+// WithActor records who performs the writes made with ctx, filled into the
+// createdBy and updatedBy columns.
+func WithActor(ctx context.Context, actor any) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFrom(ctx context.Context) (any, bool) {
+	actor := ctx.Value(actorKey{})
+	return actor, actor != nil
+}
+
+// Stamp sets the time and actor builders fill into the audit columns of a
+// payload. Without it builders use the current time and no actor.
+func Stamp(now time.Time, actor any) QueryOption {
+	return func(o *queryOptions) {
+		o.now = now
+		o.actor = actor
+	}
+}
+
+// stamp fills the audit columns of payload missing from fieldMap. Creates set
+// every audit column, updates only the updated ones.
+func (o queryOptions) stamp(fieldMap map[string]any, payload any, create bool) {
+	values := map[string]any{OptAutoUpdateTime: o.now, OptUpdatedBy: o.actor}
+	if create {
+		values[OptAutoCreateTime] = o.now
+		values[OptCreatedBy] = o.actor
+	}
+	for option, value := range values {
+		if value == nil {
+			continue
+		}
+		for _, column := range taggedColumns(payload, option) {
+			if current, ok := fieldMap[column]; ok && !reflect.ValueOf(current).IsZero() {
+				continue
+			}
+			fieldMap[column] = value
+		}
+	}
+}
+
+// taggedColumns lists the columns of payload whose db tag carries option.
+func taggedColumns(payload any, option string) []string {
+	columns := []string{}
+	rt := reflect.TypeOf(payload)
+	for rt != nil && rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return columns
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		name, options := utils.ParseTag(rt.Field(i).Tag.Get(Tag))
+		if _, ok := options[option]; ok && name != "" && name != "-" {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
@@ -0,0 +1,175 @@
+package sql
+
+import (
+	"bulk/utils"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change is the before and after value of one column of one updated row.
+// Values are rendered as text, nil for NULL.
+type Change struct {
+	Table     string
+	RowID     string
+	Column    string
+	Before    *string
+	After     *string
+	Actor     any
+	ChangedAt time.Time
+}
+
+// Auditor records the changes of an update in the transaction db of the
+// update, so they commit or roll back together.
+type Auditor interface {
+	Record(ctx context.Context, db Executor, changes []Change) error
+}
+
+// WithAuditor records the column changes of Update and UpdateBulk. Only the
+// columns set by the payload and Set options are compared.
+func WithAuditor(auditor Auditor) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.auditor = auditor
+	}
+}
+
+// auditColumns lists the columns an update of payload may change, apart from
+// the version and audit columns the builder maintains itself.
+func auditColumns(payload any, opts []QueryOption) ([]string, error) {
+	fieldMap, err := utils.StructToMap(payload, Tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed make field map: %w", err)
+	}
+	if version, ok := VersionColumn(payload); ok {
+		delete(fieldMap, version)
+	}
+	for key := range newQueryOptions(opts).sets {
+		fieldMap[key] = true
+	}
+	for _, key := range utils.SortMapKeys(fieldMap) {
+		if !IsIdentifier(key) {
+			return nil, &InvalidFieldError{Field: key, Reason: "not a column name"}
+		}
+	}
+	return utils.SortMapKeys(fieldMap), nil
+}
+
+// snapshot reads columns of the rows matching where, keyed by the text of
+// their primary key, along with the primary keys as scanned. With lock the
+// rows are locked FOR UPDATE where the dialect has row locks, so concurrent
+// writers wait until the transaction ends.
+func (r *Repository[Model, Payload, Condition]) snapshot(ctx context.Context, db Executor, columns []string, where string, bind map[string]any, lock bool) (map[string]map[string]*string, map[string]any, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(append([]string{r.pk}, columns...), ", "), r.table, where)
+	if lock && DialectOf(db.DriverName()).SupportsRowLocks() {
+		query += " FOR UPDATE"
+	}
+	query, args, err := BindNamedQuery(query, bind)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed bind named query: %w", err)
+	}
+	rows, err := db.QueryxContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed select db: %w", TranslateError(err))
+	}
+	defer rows.Close()
+	result := map[string]map[string]*string{}
+	keys := map[string]any{}
+	for rows.Next() {
+		values, err := rows.SliceScan()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed scan row: %w", err)
+		}
+		row := map[string]*string{}
+		for idx, column := range columns {
+			row[column] = formatValue(values[idx+1])
+		}
+		id := *formatValue(values[0])
+		result[id] = row
+		keys[id] = values[0]
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed read rows: %w", err)
+	}
+	return result, keys, nil
+}
+
+// audited runs update between snapshots of the rows matching where and
+// records the columns it changed. The first snapshot locks the rows and
+// update gets an option restricting it to them, so rows starting to match
+// in between are neither written nor missed by the log.
+func (r *Repository[Model, Payload, Condition]) audited(ctx context.Context, db Executor, payload Payload, where string, bind map[string]any, opts []QueryOption, update func(opts ...QueryOption) error) error {
+	if r.auditor == nil {
+		return update()
+	}
+	columns, err := auditColumns(payload, opts)
+	if err != nil {
+		return err
+	}
+	before, keys, err := r.snapshot(ctx, db, columns, where, bind, true)
+	if err != nil {
+		return fmt.Errorf("failed read rows before update: %w", err)
+	}
+	ids := make([]string, 0, len(before))
+	values := make([]any, 0, len(before))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		values = append(values, keys[id])
+	}
+	if err := update(keyed(r.pk, values)); err != nil {
+		return err
+	}
+	if len(before) == 0 {
+		return nil
+	}
+	after, _, err := r.snapshot(ctx, db, columns, fmt.Sprintf("%s IN (:audit_ids)", r.pk), map[string]any{"audit_ids": values}, false)
+	if err != nil {
+		return fmt.Errorf("failed read rows after update: %w", err)
+	}
+
+	actor, _ := ActorFrom(ctx)
+	now := r.clock()
+	changes := []Change{}
+	for _, id := range ids {
+		for _, column := range columns {
+			prev, next := before[id][column], after[id][column]
+			if equalValue(prev, next) {
+				continue
+			}
+			changes = append(changes, Change{Table: r.table, RowID: id, Column: column, Before: prev, After: next, Actor: actor, ChangedAt: now})
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := r.auditor.Record(ctx, db, changes); err != nil {
+		return fmt.Errorf("failed record changes: %w", err)
+	}
+	return nil
+}
+
+func formatValue(value any) *string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		text = string(v)
+	case time.Time:
+		text = v.Format(time.RFC3339Nano)
+	default:
+		text = fmt.Sprint(v)
+	}
+	return &text
+}
+
+func equalValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
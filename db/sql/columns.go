@@ -0,0 +1,116 @@
+package sql
+
+import (
+	"bulk/utils"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Column is a typed handle on a model column. Declared as a field of a
+// struct filled by ColumnsOf, a misspelt column fails to compile.
+type Column string
+
+func (c Column) Name() string {
+	return string(c)
+}
+
+func (c Column) Asc() utils.Sort {
+	return utils.Sort{Field: string(c)}
+}
+
+func (c Column) Desc() utils.Sort {
+	return utils.Sort{Field: string(c), Desc: true}
+}
+
+// Set assigns the column in an update, see Set.
+func (c Column) Set(value any) QueryOption {
+	return Set(string(c), value)
+}
+
+// Guard restricts an update to rows where the column compares to value with
+// op, see Guard: ProductCols.Qty.Guard(OpGte, n).
+func (c Column) Guard(op Operator, value any) QueryOption {
+	clause, err := op.Render(string(c), "")
+	if err != nil {
+		return func(o *queryOptions) {
+			o.guards = append(o.guards, guard{err: err})
+		}
+	}
+	// The column is an identifier, the only colon marks the bind.
+	return Guard(strings.Replace(clause, ":", "?", 1), value)
+}
+
+// Names lists the names of columns, for Select fields.
+func Names(columns ...Column) []string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, string(column))
+	}
+	return names
+}
+
+// ColumnSet is embedded in a struct of Column fields to list every column of
+// the model, see ColumnsOf.
+type ColumnSet struct {
+	all []string
+}
+
+// All lists every db column of the model in declaration order.
+func (s ColumnSet) All() []string {
+	return append([]string{}, s.all...)
+}
+
+// ColumnsOf fills the Column fields of T with the db column of the Model
+// field of the same name, and an embedded ColumnSet with every Model column:
+//
+//	type ProductColumns struct {
+//		sql.ColumnSet
+//		ID, SKU sql.Column
+//	}
+//
+// It fails when a Column field has no tagged Model field.
+func ColumnsOf[T any, Model any]() (T, error) {
+	var columns T
+	rv := reflect.ValueOf(&columns).Elem()
+	rm := reflect.TypeOf((*Model)(nil)).Elem()
+	if rv.Kind() != reflect.Struct || rm.Kind() != reflect.Struct {
+		return columns, fmt.Errorf("failed columns of %s: need structs", rm)
+	}
+	set := ColumnSet{}
+	names := map[string]string{}
+	for i := 0; i < rm.NumField(); i++ {
+		name, _ := utils.ParseTag(rm.Field(i).Tag.Get(Tag))
+		if name == "" || name == "-" {
+			continue
+		}
+		set.all = append(set.all, name)
+		names[rm.Field(i).Name] = name
+	}
+
+	columnType, setType := reflect.TypeOf(Column("")), reflect.TypeOf(ColumnSet{})
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		switch field.Type {
+		case setType:
+			rv.Field(i).Set(reflect.ValueOf(set))
+		case columnType:
+			name, ok := names[field.Name]
+			if !ok {
+				return columns, &InvalidFieldError{Field: field.Name, Reason: fmt.Sprintf("no db column in %s", rm)}
+			}
+			rv.Field(i).SetString(name)
+		}
+	}
+	return columns, nil
+}
+
+// MustColumnsOf is ColumnsOf for package variables, panicking when T does
+// not match Model.
+func MustColumnsOf[T any, Model any]() T {
+	columns, err := ColumnsOf[T, Model]()
+	if err != nil {
+		panic(err)
+	}
+	return columns
+}
@@ -0,0 +1,75 @@
+package sql
+
+import (
+	"bulk/utils"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type itemColumns struct {
+	ColumnSet
+	ID, SKU, Qty Column
+}
+
+var itemCols = MustColumnsOf[itemColumns, itemModel]()
+
+func TestColumnsOf(t *testing.T) {
+	assert.Equal(t, Column("item_id"), itemCols.ID)
+	assert.Equal(t, Column("sku"), itemCols.SKU)
+	assert.Equal(t, []string{"item_id", "sku", "name", "qty"}, itemCols.All())
+	assert.Equal(t, []string{"sku", "qty"}, Names(itemCols.SKU, itemCols.Qty))
+	assert.Equal(t, utils.Sort{Field: "qty", Desc: true}, itemCols.Qty.Desc())
+
+	_, err := ColumnsOf[struct{ Missing Column }, itemModel]()
+	assert.True(t, errors.Is(err, ErrInvalidField))
+	_, err = ColumnsOf[int, itemModel]()
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { MustColumnsOf[struct{ Missing Column }, itemModel]() })
+}
+
+func TestColumnQueries(t *testing.T) {
+	ctx := context.Background()
+	r := newItemRepo(t)
+	for i, sku := range []string{"a", "b", "c"} {
+		_, err := r.Create(ctx, itemOf(sku, i+1))
+		assert.Nil(t, err)
+	}
+
+	result, err := r.Select(ctx, Names(itemCols.ID, itemCols.SKU), &itemCondition{}, &utils.Paginate{Sort: []utils.Sort{itemCols.Qty.Desc()}})
+	assert.Nil(t, err)
+	skus := []string{}
+	for _, item := range result.Data {
+		skus = append(skus, *item.SKU)
+		assert.Nil(t, item.Qty)
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, skus)
+
+	all := 0
+	testCases := []struct {
+		Opts     []QueryOption
+		Expected []int
+		HasError bool
+	}{
+		{Opts: []QueryOption{itemCols.Qty.Set(Decr(1)), itemCols.Qty.Guard(OpGte, 2)}, Expected: []int{1, 1, 2}},
+		{Opts: []QueryOption{itemCols.Qty.Set(10), itemCols.SKU.Guard(OpIn, []string{"a", "b"})}, Expected: []int{10, 10, 2}},
+		{Opts: []QueryOption{itemCols.Qty.Set(0), itemCols.Qty.Guard(OpLt, 0)}, Expected: []int{10, 10, 2}, HasError: true},
+		{Opts: []QueryOption{itemCols.Qty.Set(0), itemCols.Qty.Guard(Operator("between"), 0)}, Expected: []int{10, 10, 2}, HasError: true},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			err := r.Update(ctx, itemPayload{}, itemCondition{QtyGte: &all}, tc.Opts...)
+			assert.Equal(t, tc.HasError, err != nil, err)
+			result, err := r.Select(ctx, itemCols.All(), &itemCondition{}, &utils.Paginate{Sort: []utils.Sort{itemCols.ID.Asc()}})
+			assert.Nil(t, err)
+			qty := []int{}
+			for _, item := range result.Data {
+				qty = append(qty, *item.Qty)
+			}
+			assert.Equal(t, tc.Expected, qty)
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package sql
+
+import (
+	"bulk/utils"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TableDef describes a table: its columns in order, the primary key and the
+// secondary indexes. DescribeTable derives it from a model; introspection
+// fills it from a live database.
+type TableDef struct {
+	Name       string
+	Columns    []ColumnDef
+	PrimaryKey []string
+	Indexes    []IndexDef
+}
+
+type ColumnDef struct {
+	Name          string
+	Type          string
+	NotNull       bool
+	PrimaryKey    bool
+	AutoIncrement bool
+	// Unique is an unnamed single column unique constraint.
+	Unique  bool
+	Default *string
+}
+
+type IndexDef struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// Column returns the definition of the named column.
+func (t TableDef) Column(name string) (ColumnDef, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return ColumnDef{}, false
+}
+
+// DescribeTable reads the definition of table from the db tags of model.
+// Column options:
+//
+//	type=T         SQL type as written, inferred from the Go type when absent
+//	pk             primary key, several make a composite key
+//	autoincrement  integer primary key generated by the database
+//	notnull        NOT NULL
+//	unique         UNIQUE, unique=name adds the column to a unique index
+//	index=name     adds the column to an index, in field order; index=name:N
+//	               puts it at position N, ahead of columns without one
+//	default=expr   DEFAULT expr as written
+func DescribeTable(dialect Dialect, table string, model any) (TableDef, error) {
+	if !IsIdentifier(table) {
+		return TableDef{}, &InvalidFieldError{Field: table, Reason: "not a table name"}
+	}
+	rt := reflect.TypeOf(model)
+	for rt != nil && rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return TableDef{}, fmt.Errorf("failed describe table: model need to be struct")
+	}
+
+	type field struct {
+		name    string
+		options map[string]string
+		goType  reflect.Type
+	}
+	fields := []field{}
+	def := TableDef{Name: table}
+	for i := 0; i < rt.NumField(); i++ {
+		name, options := utils.ParseTag(rt.Field(i).Tag.Get(Tag))
+		if name == "" || name == "-" {
+			continue
+		}
+		if !IsIdentifier(name) {
+			return TableDef{}, &InvalidFieldError{Field: name, Reason: "not a column name"}
+		}
+		if _, ok := options["pk"]; ok {
+			def.PrimaryKey = append(def.PrimaryKey, name)
+		}
+		fields = append(fields, field{name: name, options: options, goType: rt.Field(i).Type})
+	}
+	if len(fields) == 0 {
+		return TableDef{}, fmt.Errorf("failed describe table %s: %w", table, ErrEmptyPayload)
+	}
+
+	positions := map[string][]int{}
+	addIndex := func(column string, value string, unique bool) error {
+		name, position := value, 0
+		if i := strings.LastIndex(value, ":"); i >= 0 {
+			n, err := strconv.Atoi(value[i+1:])
+			if err != nil || n < 1 {
+				return &InvalidFieldError{Field: column, Reason: fmt.Sprintf("index position %q is not a positive number", value[i+1:])}
+			}
+			name, position = value[:i], n
+		}
+		if !IsIdentifier(name) {
+			return &InvalidFieldError{Field: column, Reason: fmt.Sprintf("index name %q is not an identifier", name)}
+		}
+		at := -1
+		for i := range def.Indexes {
+			if def.Indexes[i].Name == name {
+				at = i
+			}
+		}
+		if at < 0 {
+			at = len(def.Indexes)
+			def.Indexes = append(def.Indexes, IndexDef{Name: name, Unique: unique})
+		}
+		if def.Indexes[at].Unique != unique {
+			return &InvalidFieldError{Field: column, Reason: fmt.Sprintf("index %s is both unique and not", name)}
+		}
+		def.Indexes[at].Columns = append(def.Indexes[at].Columns, column)
+		positions[name] = append(positions[name], position)
+		return nil
+	}
+	for _, f := range fields {
+		column := ColumnDef{Name: f.name}
+		_, column.PrimaryKey = f.options["pk"]
+		_, column.AutoIncrement = f.options["autoincrement"]
+		_, column.NotNull = f.options["notnull"]
+		if column.AutoIncrement && (!column.PrimaryKey || len(def.PrimaryKey) > 1) {
+			return TableDef{}, &InvalidFieldError{Field: f.name, Reason: "autoincrement needs a single primary key column"}
+		}
+		column.Type = f.options["type"]
+		if column.Type == "" {
+			var err error
+			if column.Type, err = ColumnType(dialect, f.goType); err != nil {
+				return TableDef{}, &InvalidFieldError{Field: f.name, Reason: err.Error()}
+			}
+		}
+		if column.AutoIncrement && dialect == SQLite {
+			column.Type = "INTEGER"
+		}
+		if value, ok := f.options["default"]; ok {
+			column.Default = &value
+		}
+		if name, ok := f.options["unique"]; ok {
+			if name == "" {
+				column.Unique = true
+			} else if err := addIndex(f.name, name, true); err != nil {
+				return TableDef{}, err
+			}
+		}
+		if name, ok := f.options["index"]; ok {
+			if err := addIndex(f.name, name, false); err != nil {
+				return TableDef{}, err
+			}
+		}
+		def.Columns = append(def.Columns, column)
+	}
+	for i := range def.Indexes {
+		columns, order := def.Indexes[i].Columns, positions[def.Indexes[i].Name]
+		rank := make(map[string]int, len(columns))
+		for j, column := range columns {
+			rank[column] = order[j]
+			if order[j] == 0 {
+				rank[column] = len(columns) + j + 1
+			}
+		}
+		sort.SliceStable(columns, func(a, b int) bool { return rank[columns[a]] < rank[columns[b]] })
+	}
+	return def, nil
+}
+
+// BuildCreateTable renders the CREATE TABLE and CREATE INDEX statements of
+// table from the db tags of model, see DescribeTable for the options.
+func BuildCreateTable(dialect Dialect, table string, model any) ([]string, error) {
+	def, err := DescribeTable(dialect, table, model)
+	if err != nil {
+		return nil, err
+	}
+	definitions := []string{}
+	for _, column := range def.Columns {
+		definition := []string{column.Name, column.Type}
+		if column.NotNull || (column.AutoIncrement && dialect == MySQL) {
+			definition = append(definition, "NOT NULL")
+		}
+		if column.Default != nil {
+			definition = append(definition, "DEFAULT", *column.Default)
+		}
+		if column.AutoIncrement && dialect == MySQL {
+			definition = append(definition, "AUTO_INCREMENT")
+		}
+		if column.AutoIncrement && dialect == Postgres {
+			definition = append(definition, "GENERATED BY DEFAULT AS IDENTITY")
+		}
+		if column.PrimaryKey && len(def.PrimaryKey) == 1 {
+			definition = append(definition, "PRIMARY KEY")
+		}
+		if column.AutoIncrement && dialect == SQLite {
+			definition = append(definition, "AUTOINCREMENT")
+		}
+		if column.Unique {
+			definition = append(definition, "UNIQUE")
+		}
+		definitions = append(definitions, strings.Join(definition, " "))
+	}
+	if len(def.PrimaryKey) > 1 {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(def.PrimaryKey, ", ")))
+	}
+
+	statements := []string{fmt.Sprintf("CREATE TABLE %s (\n\t%s\n)", table, strings.Join(definitions, ",\n\t"))}
+	for _, idx := range def.Indexes {
+		create := "CREATE INDEX"
+		if idx.Unique {
+			create = "CREATE UNIQUE INDEX"
+		}
+		statements = append(statements, fmt.Sprintf("%s %s ON %s (%s)", create, idx.Name, table, strings.Join(idx.Columns, ", ")))
+	}
+	return statements, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ColumnType infers the SQL type of a Go field type in dialect.
+func ColumnType(dialect Dialect, rt reflect.Type) (string, error) {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == timeType {
+		switch dialect {
+		case MySQL:
+			return "DATETIME(6)", nil
+		case Postgres:
+			return "TIMESTAMP", nil
+		}
+		return "DATETIME", nil
+	}
+	switch rt.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN", nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "INTEGER", nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		if dialect == SQLite {
+			return "INTEGER", nil
+		}
+		return "BIGINT", nil
+	case reflect.Float32:
+		return "REAL", nil
+	case reflect.Float64:
+		switch dialect {
+		case MySQL:
+			return "DOUBLE", nil
+		case Postgres:
+			return "DOUBLE PRECISION", nil
+		}
+		return "REAL", nil
+	case reflect.String:
+		if dialect == MySQL {
+			return "VARCHAR(255)", nil
+		}
+		return "TEXT", nil
+	case reflect.Slice:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			if dialect == Postgres {
+				return "BYTEA", nil
+			}
+			return "BLOB", nil
+		}
+	}
+	return "", fmt.Errorf("no SQL type for %s, set type=", rt)
+}
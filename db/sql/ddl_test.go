@@ -0,0 +1,158 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ddlModel struct {
+	ID        *int       `db:"id,pk,autoincrement"`
+	SKU       *string    `db:"sku,type=VARCHAR(64),notnull,unique"`
+	Name      *string    `db:"name"`
+	Price     *float64   `db:"price,type=DECIMAL(12,2),default=0"`
+	Active    bool       `db:"active,notnull,default=TRUE"`
+	Shop      int32      `db:"shop,index=idx_shop_created"`
+	CreatedAt *time.Time `db:"created_at,index=idx_shop_created"`
+	Code      string     `db:"code,unique=uq_code"`
+	Data      []byte     `db:"data"`
+	Skipped   string     `db:"-"`
+}
+
+func TestBuildCreateTable(t *testing.T) {
+	indexes := []string{
+		"CREATE INDEX idx_shop_created ON items (shop, created_at)",
+		"CREATE UNIQUE INDEX uq_code ON items (code)",
+	}
+	testCases := []struct {
+		Dialect  Dialect
+		Model    any
+		Expected []string
+		HasError bool
+	}{
+		{
+			Dialect: MySQL,
+			Model:   ddlModel{},
+			Expected: append([]string{"CREATE TABLE items (\n" +
+				"\tid BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,\n" +
+				"\tsku VARCHAR(64) NOT NULL UNIQUE,\n" +
+				"\tname VARCHAR(255),\n" +
+				"\tprice DECIMAL(12,2) DEFAULT 0,\n" +
+				"\tactive BOOLEAN NOT NULL DEFAULT TRUE,\n" +
+				"\tshop INTEGER,\n" +
+				"\tcreated_at DATETIME(6),\n" +
+				"\tcode VARCHAR(255),\n" +
+				"\tdata BLOB\n)"}, indexes...),
+		},
+		{
+			Dialect: Postgres,
+			Model:   &ddlModel{},
+			Expected: append([]string{"CREATE TABLE items (\n" +
+				"\tid BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,\n" +
+				"\tsku VARCHAR(64) NOT NULL UNIQUE,\n" +
+				"\tname TEXT,\n" +
+				"\tprice DECIMAL(12,2) DEFAULT 0,\n" +
+				"\tactive BOOLEAN NOT NULL DEFAULT TRUE,\n" +
+				"\tshop INTEGER,\n" +
+				"\tcreated_at TIMESTAMP,\n" +
+				"\tcode TEXT,\n" +
+				"\tdata BYTEA\n)"}, indexes...),
+		},
+		{
+			Dialect: SQLite,
+			Model:   ddlModel{},
+			Expected: append([]string{"CREATE TABLE items (\n" +
+				"\tid INTEGER PRIMARY KEY AUTOINCREMENT,\n" +
+				"\tsku VARCHAR(64) NOT NULL UNIQUE,\n" +
+				"\tname TEXT,\n" +
+				"\tprice DECIMAL(12,2) DEFAULT 0,\n" +
+				"\tactive BOOLEAN NOT NULL DEFAULT TRUE,\n" +
+				"\tshop INTEGER,\n" +
+				"\tcreated_at DATETIME,\n" +
+				"\tcode TEXT,\n" +
+				"\tdata BLOB\n)"}, indexes...),
+		},
+		{
+			Dialect: SQLite,
+			Model: struct {
+				OrderID int64 `db:"order_id,pk"`
+				LineNo  int   `db:"line_no,pk"`
+			}{},
+			Expected: []string{"CREATE TABLE items (\n\torder_id INTEGER,\n\tline_no INTEGER,\n\tPRIMARY KEY (order_id, line_no)\n)"},
+		},
+		{
+			Dialect: SQLite,
+			Model: struct {
+				ID int64 `db:"id,autoincrement"`
+			}{},
+			HasError: true,
+		},
+		{
+			Dialect: MySQL,
+			Model: struct {
+				Tags map[string]string `db:"tags"`
+			}{},
+			HasError: true,
+		},
+		{
+			Dialect: MySQL,
+			Model: struct {
+				A string `db:"a,index=idx_a"`
+				B string `db:"b,unique=idx_a"`
+			}{},
+			HasError: true,
+		},
+		{
+			Dialect: MySQL,
+			Model: struct {
+				A string `db:"a;drop,type=TEXT"`
+			}{},
+			HasError: true,
+		},
+		{
+			Dialect: SQLite,
+			Model: struct {
+				A string `db:"a,index=idx_abc:2"`
+				B string `db:"b,index=idx_abc:1"`
+				C string `db:"c,index=idx_abc"`
+			}{},
+			Expected: []string{"CREATE TABLE items (\n\ta TEXT,\n\tb TEXT,\n\tc TEXT\n)", "CREATE INDEX idx_abc ON items (b, a, c)"},
+		},
+		{
+			Dialect: SQLite,
+			Model: struct {
+				A string `db:"a,index=idx_a:0"`
+			}{},
+			HasError: true,
+		},
+		{Dialect: MySQL, Model: 1, HasError: true},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			actual, err := BuildCreateTable(tc.Dialect, "items", tc.Model)
+			assert.Equal(t, tc.HasError, err != nil, err)
+			if !tc.HasError {
+				assert.Equal(t, tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestBuildCreateTableSQLite(t *testing.T) {
+	statements, err := BuildCreateTable(SQLite, "items", itemModel{})
+	assert.Nil(t, err)
+	r := NewRepository[itemModel, itemPayload, itemCondition](newTestDB(t, statements...), "items")
+	ctx := context.Background()
+	item, err := r.Create(ctx, itemOf("a", 1))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *item.ID)
+	_, err = r.Create(ctx, itemOf("b", 1))
+	assert.Nil(t, err)
+
+	statements, err = BuildCreateTable(SQLite, "items", ddlModel{})
+	assert.Nil(t, err)
+	newTestDB(t, statements...)
+}
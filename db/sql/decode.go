@@ -0,0 +1,175 @@
+package sql
+
+import (
+	"bulk/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const QueryTag = "query"
+
+type queryField struct {
+	index  int
+	isList bool
+}
+
+// DecodeQuery fills condition, a pointer to a condition struct, from URL query
+// values. Keys come from the query tag or from the db tag name plus operator
+// suffix (e.g. price_gte). When paginate is not nil, page, limit and sort are
+// decoded into it, with sort fields restricted to the condition's columns.
+func DecodeQuery(values url.Values, condition any, paginate *utils.Paginate) error {
+	rv := reflect.ValueOf(condition)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("condition need to be pointer to struct")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	fieldErrs := utils.ValidationErrors{}
+	keys := map[string][]queryField{}
+	columns := map[string]bool{}
+	for i := 0; i < rt.NumField(); i++ {
+		typeField := rt.Field(i)
+		name, options := utils.ParseTag(typeField.Tag.Get(Tag))
+		if name == "" || name == "-" {
+			continue
+		}
+		columns[name] = true
+		fieldType := typeField.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		isList := fieldType.Kind() == reflect.Slice
+		op, err := fieldOperator(options, isList)
+		if err != nil {
+			return fmt.Errorf("failed decode field %s: %w", typeField.Name, err)
+		}
+		key := name + op.Suffix()
+		if queryName, _ := utils.ParseTag(typeField.Tag.Get(QueryTag)); queryName != "" {
+			key = queryName
+		}
+		keys[key] = append(keys[key], queryField{index: i, isList: isList})
+	}
+
+	for _, key := range sortedKeys(keys) {
+		raw, ok := values[key]
+		if !ok || len(raw) == 0 {
+			continue
+		}
+		field, ok := pickQueryField(keys[key], len(raw) > 1)
+		if !ok {
+			fieldErrs = append(fieldErrs, utils.FieldError{Field: key, Message: "expects a single value"})
+			continue
+		}
+		target := rv.Field(field.index)
+		if field.isList {
+			err := setList(target, raw)
+			if err != nil {
+				fieldErrs = append(fieldErrs, utils.FieldError{Field: key, Message: err.Error()})
+			}
+			continue
+		}
+		if err := utils.SetFromString(target, raw[0]); err != nil {
+			fieldErrs = append(fieldErrs, utils.FieldError{Field: key, Message: err.Error()})
+		}
+	}
+
+	if paginate != nil {
+		fieldErrs = append(fieldErrs, decodePaginate(values, columns, paginate)...)
+	}
+
+	if len(fieldErrs) > 0 {
+		return fieldErrs
+	}
+	return nil
+}
+
+func decodePaginate(values url.Values, columns map[string]bool, paginate *utils.Paginate) utils.ValidationErrors {
+	fieldErrs := utils.ValidationErrors{}
+	paginate.Page = utils.DefaultPage
+	paginate.Limit = utils.DefaultLimit
+	for _, key := range []string{"page", "limit"} {
+		raw := values.Get(key)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			fieldErrs = append(fieldErrs, utils.FieldError{Field: key, Message: "must be a positive integer"})
+			continue
+		}
+		if key == "page" {
+			paginate.Page = n
+		} else {
+			paginate.Limit = n
+		}
+	}
+	paginate.Sort = nil
+	for _, raw := range values["sort"] {
+		for _, part := range strings.Split(raw, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			s := utils.ParseSort(part)
+			if !columns[s.Field] {
+				fieldErrs = append(fieldErrs, utils.FieldError{Field: "sort", Message: fmt.Sprintf("unknown field %q", s.Field)})
+				continue
+			}
+			paginate.Sort = append(paginate.Sort, s)
+		}
+	}
+	return fieldErrs
+}
+
+// pickQueryField prefers a scalar field for a single value and a slice field
+// for repeated values, so SKU and SKUs can share the sku key.
+func pickQueryField(fields []queryField, repeated bool) (queryField, bool) {
+	for _, field := range fields {
+		if field.isList == repeated {
+			return field, true
+		}
+	}
+	for _, field := range fields {
+		if field.isList {
+			return field, true
+		}
+	}
+	return queryField{}, false
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func setList(target reflect.Value, raw []string) error {
+	sliceType := target.Type()
+	if sliceType.Kind() == reflect.Ptr {
+		sliceType = sliceType.Elem()
+	}
+	list := reflect.MakeSlice(sliceType, 0, len(raw))
+	for _, item := range raw {
+		elem := reflect.New(sliceType.Elem()).Elem()
+		if err := utils.SetFromString(elem, item); err != nil {
+			return err
+		}
+		list = reflect.Append(list, elem)
+	}
+	if target.Kind() == reflect.Ptr {
+		ptr := reflect.New(sliceType)
+		ptr.Elem().Set(list)
+		target.Set(ptr)
+		return nil
+	}
+	target.Set(list)
+	return nil
+}
@@ -0,0 +1,82 @@
+package sql
+
+import (
+	"bulk/utils"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type decodeCondition struct {
+	ID       *int      `db:"id"`
+	IDs      *[]int    `db:"id"`
+	SKU      *string   `db:"sku"`
+	SKUs     *[]string `db:"sku"`
+	PriceGte *float64  `db:"price,gte"`
+	Name     *string   `db:"name,like" query:"q"`
+}
+
+func TestDecodeQuery(t *testing.T) {
+
+	t.Run("failed", func(t *testing.T) {
+		// Not a pointer
+		err := DecodeQuery(url.Values{}, decodeCondition{}, nil)
+		assert.NotNil(t, err)
+
+		// Conversion
+		values, _ := url.ParseQuery("id=abc&price_gte=x&page=0&sort=-unknown")
+		err = DecodeQuery(values, &decodeCondition{}, &utils.Paginate{})
+		assert.Equal(t, utils.ValidationErrors{
+			{Field: "id", Message: "must be an integer"},
+			{Field: "price_gte", Message: "must be a number"},
+			{Field: "page", Message: "must be a positive integer"},
+			{Field: "sort", Message: `unknown field "unknown"`},
+		}, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		id := 3
+		ids := []int{1, 2}
+		sku := "a"
+		skus := []string{"a", "b"}
+		price := 10.5
+		name := "%x%"
+
+		testCases := []struct {
+			Query     string
+			Condition decodeCondition
+			Paginate  utils.Paginate
+		}{
+			{
+				Query:     "sku=a&sku=b&price_gte=10.5&sort=-price&page=2",
+				Condition: decodeCondition{SKUs: &skus, PriceGte: &price},
+				Paginate:  utils.Paginate{Page: 2, Limit: utils.DefaultLimit, Sort: []utils.Sort{{Field: "price", Desc: true}}},
+			},
+			{
+				Query:     "id=3&sku=a&q=%25x%25&limit=5&sort=sku,-id",
+				Condition: decodeCondition{ID: &id, SKU: &sku, Name: &name},
+				Paginate:  utils.Paginate{Page: utils.DefaultPage, Limit: 5, Sort: []utils.Sort{{Field: "sku"}, {Field: "id", Desc: true}}},
+			},
+			{
+				Query:     "id=1&id=2&unknown=1",
+				Condition: decodeCondition{IDs: &ids},
+				Paginate:  utils.Paginate{Page: utils.DefaultPage, Limit: utils.DefaultLimit},
+			},
+		}
+
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				values, err := url.ParseQuery(tc.Query)
+				assert.Nil(t, err)
+				condition := decodeCondition{}
+				paginate := utils.Paginate{}
+				err = DecodeQuery(values, &condition, &paginate)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.Condition, condition)
+				assert.Equal(t, tc.Paginate, paginate)
+			})
+		}
+	})
+}
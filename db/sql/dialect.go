@@ -0,0 +1,33 @@
+package sql
+
+import "strings"
+
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// DialectOf maps a database/sql driver name to its SQL dialect.
+func DialectOf(driverName string) Dialect {
+	switch strings.ToLower(driverName) {
+	case "postgres", "pgx", "pq", "cloudsqlpostgres":
+		return Postgres
+	case "sqlite3", "sqlite":
+		return SQLite
+	}
+	return MySQL
+}
+
+// SupportsReturning reports whether INSERT ... RETURNING is available.
+func (d Dialect) SupportsReturning() bool {
+	return d == Postgres || d == SQLite
+}
+
+// SupportsRowLocks reports whether SELECT ... FOR UPDATE, and its SKIP LOCKED
+// variant, are available. SQLite locks the whole database on write instead.
+func (d Dialect) SupportsRowLocks() bool {
+	return d == MySQL || d == Postgres
+}
@@ -0,0 +1,31 @@
+package sql
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectOf(t *testing.T) {
+	testCases := []struct {
+		Driver    string
+		Expected  Dialect
+		Returning bool
+		RowLocks  bool
+	}{
+		{Driver: "mysql", Expected: MySQL, Returning: false, RowLocks: true},
+		{Driver: "postgres", Expected: Postgres, Returning: true, RowLocks: true},
+		{Driver: "pgx", Expected: Postgres, Returning: true, RowLocks: true},
+		{Driver: "sqlite3", Expected: SQLite, Returning: true, RowLocks: false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			dialect := DialectOf(tc.Driver)
+			assert.Equal(t, tc.Expected, dialect)
+			assert.Equal(t, tc.Returning, dialect.SupportsReturning())
+			assert.Equal(t, tc.RowLocks, dialect.SupportsRowLocks())
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrEmptyCondition = errors.New("empty condition")
+	ErrEmptyPayload   = errors.New("empty payload")
+	ErrInvalidField   = errors.New("invalid field")
+	ErrUnknownColumn  = errors.New("unknown column")
+	ErrDuplicateKey   = errors.New("duplicate key")
+	ErrNotFound       = errors.New("not found")
+	ErrNotUnique      = errors.New("more than one row matched")
+	ErrConflict       = errors.New("version conflict")
+	ErrGuardFailed    = errors.New("update guard not satisfied")
+	ErrCommitUnknown  = errors.New("commit outcome unknown")
+)
+
+const (
+	MySQLDuplicateEntry = 1062
+	MySQLUnknownColumn  = 1054
+)
+
+// mysqlConstraintErrors are the MySQL errors rejecting the data of a row:
+// null, missing default, too long, out of range, foreign key and check
+// violations.
+var mysqlConstraintErrors = map[uint16]bool{
+	MySQLDuplicateEntry: true, 1048: true, 1364: true, 1406: true, 1264: true,
+	1216: true, 1217: true, 1451: true, 1452: true, 3819: true,
+}
+
+type InvalidFieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *InvalidFieldError) Error() string {
+	return fmt.Sprintf("invalid field %q: %s", e.Field, e.Reason)
+}
+
+func (e *InvalidFieldError) Is(target error) bool {
+	return target == ErrInvalidField
+}
+
+type NotFoundError struct {
+	Table string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Table, ErrNotFound)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+type UnknownColumnError struct {
+	Column string
+	Err    error
+}
+
+func (e *UnknownColumnError) Error() string {
+	return fmt.Sprintf("unknown column %q: %v", e.Column, e.Err)
+}
+
+func (e *UnknownColumnError) Is(target error) bool {
+	return target == ErrUnknownColumn
+}
+
+func (e *UnknownColumnError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateKeyError reports a unique key violation. Key is the index or
+// constraint name as reported by the driver, Value the conflicting entry when
+// known.
+type DuplicateKeyError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	if e.Value != "" {
+		return fmt.Sprintf("duplicate key %q for %q: %v", e.Key, e.Value, e.Err)
+	}
+	return fmt.Sprintf("duplicate key %q: %v", e.Key, e.Err)
+}
+
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError reports an optimistic locking failure: no row matched the
+// update at the expected version, it was changed or removed meanwhile.
+type ConflictError struct {
+	Table   string
+	Version any
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %v, expected version %v", e.Table, ErrConflict, e.Version)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// CommitError reports a commit whose connection failed before the server
+// answered. The transaction may or may not have been applied, so it is not
+// retried; the caller has to check the data before writing again.
+type CommitError struct {
+	Err error
+}
+
+func (e *CommitError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrCommitUnknown, e.Err)
+}
+
+func (e *CommitError) Is(target error) bool {
+	return target == ErrCommitUnknown
+}
+
+func (e *CommitError) Unwrap() error {
+	return e.Err
+}
+
+// sqlState returns the SQLSTATE of a Postgres error, from lib/pq's *pq.Error
+// or from drivers exposing a SQLState method such as pgx.
+func sqlState(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code), true
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState(), true
+	}
+	return "", false
+}
+
+var (
+	mysqlDuplicatePattern    = regexp.MustCompile(`Duplicate entry '(.*)' for key '(.+)'`)
+	mysqlUnknownPattern      = regexp.MustCompile(`Unknown column '([^']+)'`)
+	sqliteDuplicatePattern   = regexp.MustCompile(`UNIQUE constraint failed: ([^\s]+(?:, [^\s]+)*)`)
+	sqliteUnknownPattern     = regexp.MustCompile(`no such column: ([^\s]+)`)
+	postgresDuplicatePattern = regexp.MustCompile(`unique constraint "([^"]+)"`)
+	postgresUnknownPattern   = regexp.MustCompile(`column "([^"]+)"(?: of relation "[^"]+")? does not exist`)
+)
+
+// TranslateError maps driver errors to the typed errors of this package,
+// keeping the driver error reachable through errors.As. Other errors are
+// returned unchanged.
+func TranslateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case MySQLDuplicateEntry:
+			dup := &DuplicateKeyError{Err: err}
+			if match := mysqlDuplicatePattern.FindStringSubmatch(mysqlErr.Message); match != nil {
+				dup.Value, dup.Key = match[1], match[2]
+			}
+			return dup
+		case MySQLUnknownColumn:
+			unknown := &UnknownColumnError{Err: err}
+			if match := mysqlUnknownPattern.FindStringSubmatch(mysqlErr.Message); match != nil {
+				unknown.Column = match[1]
+			}
+			return unknown
+		}
+		return err
+	}
+
+	message := err.Error()
+	if state, ok := sqlState(err); ok {
+		switch state {
+		case "23505":
+			dup := &DuplicateKeyError{Err: err}
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Constraint != "" {
+				dup.Key = pqErr.Constraint
+			} else if match := postgresDuplicatePattern.FindStringSubmatch(message); match != nil {
+				dup.Key = match[1]
+			}
+			return dup
+		case "42703":
+			unknown := &UnknownColumnError{Err: err}
+			if match := postgresUnknownPattern.FindStringSubmatch(message); match != nil {
+				unknown.Column = match[1]
+			}
+			return unknown
+		}
+		return err
+	}
+
+	if match := sqliteDuplicatePattern.FindStringSubmatch(message); match != nil {
+		return &DuplicateKeyError{Key: match[1], Err: err}
+	}
+	if match := sqliteUnknownPattern.FindStringSubmatch(message); match != nil {
+		return &UnknownColumnError{Column: match[1], Err: err}
+	}
+	return err
+}
+
+// IsConstraintError reports whether err rejects the data written rather
+// than the statement or the connection: duplicate keys and the null,
+// foreign key and check constraint violations of the supported drivers.
+func IsConstraintError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrDuplicateKey) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlConstraintErrors[mysqlErr.Number]
+	}
+	if state, ok := sqlState(err); ok {
+		return strings.HasPrefix(state, "23")
+	}
+	return strings.Contains(err.Error(), "constraint failed")
+}
@@ -0,0 +1,122 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+
+	t.Run("driver errors", func(t *testing.T) {
+		mysqlDup := &mysql.MySQLError{Number: MySQLDuplicateEntry, Message: "Duplicate entry 'sku_1' for key 'products.sku'"}
+		mysqlUnknown := &mysql.MySQLError{Number: MySQLUnknownColumn, Message: "Unknown column 'colour' in 'field list'"}
+		pgDup := sqlStateError("23505")
+		pqDup := &pq.Error{Code: "23505", Message: `duplicate key value violates unique constraint "products_sku_key"`, Constraint: "products_sku_key"}
+		pqUnknown := &pq.Error{Code: "42703", Message: `column "colour" of relation "products" does not exist`}
+
+		testCases := []struct {
+			Err      error
+			Target   error
+			Expected error
+		}{
+			{Err: mysqlDup, Target: ErrDuplicateKey, Expected: &DuplicateKeyError{Key: "products.sku", Value: "sku_1", Err: mysqlDup}},
+			{Err: fmt.Errorf("wrapped: %w", mysqlUnknown), Target: ErrUnknownColumn},
+			{Err: mysqlUnknown, Target: ErrUnknownColumn, Expected: &UnknownColumnError{Column: "colour", Err: mysqlUnknown}},
+			{Err: pgDup, Target: ErrDuplicateKey, Expected: &DuplicateKeyError{Err: pgDup}},
+			{Err: pqDup, Target: ErrDuplicateKey, Expected: &DuplicateKeyError{Key: "products_sku_key", Err: pqDup}},
+			{Err: fmt.Errorf("failed insert db: %w", pqDup), Target: ErrDuplicateKey},
+			{Err: pqUnknown, Target: ErrUnknownColumn, Expected: &UnknownColumnError{Column: "colour", Err: pqUnknown}},
+			{Err: errors.New("UNIQUE constraint failed: products.sku"), Target: ErrDuplicateKey},
+			{Err: errors.New("no such column: colour"), Target: ErrUnknownColumn},
+		}
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				err := TranslateError(tc.Err)
+				assert.ErrorIs(t, err, tc.Target)
+				if tc.Expected != nil {
+					assert.Equal(t, tc.Expected, err)
+				}
+			})
+		}
+	})
+
+	t.Run("keeps driver error", func(t *testing.T) {
+		mysqlDup := &mysql.MySQLError{Number: MySQLDuplicateEntry, Message: "Duplicate entry 'a' for key 'sku'"}
+		err := fmt.Errorf("failed insert db: %w", TranslateError(mysqlDup))
+		var driverErr *mysql.MySQLError
+		assert.True(t, errors.As(err, &driverErr))
+		var dup *DuplicateKeyError
+		assert.True(t, errors.As(err, &dup))
+		assert.Equal(t, "sku", dup.Key)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		deadlock := &mysql.MySQLError{Number: MySQLDeadlock}
+		assert.Same(t, deadlock, TranslateError(deadlock))
+		assert.Nil(t, TranslateError(nil))
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		db := newTestDB(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, sku TEXT UNIQUE)")
+		db.MustExec("INSERT INTO items (sku) VALUES ('a')")
+		_, err := db.Exec("INSERT INTO items (sku) VALUES ('a')")
+		var dup *DuplicateKeyError
+		assert.True(t, errors.As(TranslateError(err), &dup))
+		assert.Equal(t, "items.sku", dup.Key)
+
+		_, err = db.Exec("SELECT colour FROM items")
+		assert.ErrorIs(t, TranslateError(err), ErrUnknownColumn)
+	})
+}
+
+func TestIsConstraintError(t *testing.T) {
+	testCases := []struct {
+		Err      error
+		Expected bool
+	}{
+		{Err: &DuplicateKeyError{Key: "sku"}, Expected: true},
+		{Err: fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1048, Message: "Column 'sku' cannot be null"}), Expected: true},
+		{Err: &mysql.MySQLError{Number: MySQLDeadlock}, Expected: false},
+		{Err: sqlStateError("23502"), Expected: true},
+		{Err: &pq.Error{Code: "23502", Message: `null value in column "sku" violates not-null constraint`}, Expected: true},
+		{Err: &pq.Error{Code: "23503", Message: "insert or update violates foreign key constraint"}, Expected: true},
+		{Err: &pq.Error{Code: "42601", Message: "syntax error"}, Expected: false},
+		{Err: sqlStateError("40001"), Expected: false},
+		{Err: errors.New("NOT NULL constraint failed: items.sku"), Expected: true},
+		{Err: mysql.ErrInvalidConn, Expected: false},
+		{Err: nil, Expected: false},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			assert.Equal(t, tc.Expected, IsConstraintError(tc.Err))
+		})
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	v := "v"
+	empty := []string{}
+
+	_, _, err := BuildUpdateQuery("table", payload{Field1: &v}, condition{Field3: &empty}, "")
+	assert.ErrorIs(t, err, ErrEmptyCondition)
+
+	_, _, err = BuildUpdateQuery("table", payload{}, condition{Field1: &v}, "")
+	assert.ErrorIs(t, err, ErrEmptyPayload)
+
+	_, _, err = BuildCreateQuery("table", payload{})
+	assert.ErrorIs(t, err, ErrEmptyPayload)
+
+	_, _, err = BuildDeleteQuery("table", condition{})
+	assert.ErrorIs(t, err, ErrEmptyCondition)
+
+	_, _, err = BuildSelectQuery[condition]("table", []string{"id; DROP TABLE x"}, nil, nil)
+	var fieldErr *InvalidFieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "id; DROP TABLE x", fieldErr.Field)
+	assert.ErrorIs(t, err, ErrInvalidField)
+}
@@ -0,0 +1,103 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+const exprColumn = "{column}"
+
+// Expression is an update value computed by the database. SQL marks its
+// arguments with ? and may refer to the updated column as {column}; both are
+// rendered with named binds so the arguments never reach the SQL text.
+type Expression struct {
+	SQL  string
+	Args []any
+}
+
+// Incr adds n to the column.
+func Incr(n any) Expression {
+	return Expression{SQL: exprColumn + " + ?", Args: []any{n}}
+}
+
+// Decr subtracts n from the column.
+func Decr(n any) Expression {
+	return Expression{SQL: exprColumn + " - ?", Args: []any{n}}
+}
+
+// Coalesce sets the column to value, keeping the current one when value is
+// NULL.
+func Coalesce(value any) Expression {
+	return Expression{SQL: "COALESCE(?, " + exprColumn + ")", Args: []any{value}}
+}
+
+// Expr sets the column to a raw SQL expression, e.g.
+// Expr("GREATEST(qty - ?, 0)", n).
+func Expr(sql string, args ...any) Expression {
+	return Expression{SQL: sql, Args: args}
+}
+
+// Render replaces the placeholders of the expression, binding its arguments
+// as bindKey_1, bindKey_2 and so on.
+func (e Expression) Render(column string, bindKey string) (string, map[string]any, error) {
+	bind := map[string]any{}
+	parts := strings.Split(strings.ReplaceAll(e.SQL, exprColumn, column), "?")
+	if len(parts)-1 != len(e.Args) {
+		return "", map[string]any{}, fmt.Errorf("expression %q has %d placeholders for %d args", e.SQL, len(parts)-1, len(e.Args))
+	}
+	var sb strings.Builder
+	sb.WriteString(parts[0])
+	for idx, arg := range e.Args {
+		key := fmt.Sprintf("%s_%d", bindKey, idx+1)
+		bind[key] = arg
+		sb.WriteString(":" + key)
+		sb.WriteString(parts[idx+1])
+	}
+	return sb.String(), bind, nil
+}
+
+type guard struct {
+	clause string
+	args   []any
+	err    error
+}
+
+// Set assigns column in an update next to the payload fields, value being a
+// literal or an Expression.
+func Set(column string, value any) QueryOption {
+	return func(o *queryOptions) {
+		if o.sets == nil {
+			o.sets = map[string]any{}
+		}
+		o.sets[column] = value
+	}
+}
+
+// Guard restricts an update to rows where clause holds, e.g.
+// Guard("qty >= ?", n). Arguments are bound like Expression arguments. A
+// guarded update matching no row fails with ErrGuardFailed.
+func Guard(clause string, args ...any) QueryOption {
+	return func(o *queryOptions) {
+		o.guards = append(o.guards, guard{clause: clause, args: args})
+	}
+}
+
+// renderGuards renders the guard clauses with binds prefixed by prefix.
+func (o queryOptions) renderGuards(prefix string) ([]string, map[string]any, error) {
+	clauses := []string{}
+	bind := map[string]any{}
+	for idx, g := range o.guards {
+		if g.err != nil {
+			return nil, map[string]any{}, fmt.Errorf("failed render guard: %w", g.err)
+		}
+		clause, guardBind, err := Expr(g.clause, g.args...).Render("", fmt.Sprintf("%sguard%d", prefix, idx+1))
+		if err != nil {
+			return nil, map[string]any{}, fmt.Errorf("failed render guard: %w", err)
+		}
+		clauses = append(clauses, clause)
+		for key, val := range guardBind {
+			bind[key] = val
+		}
+	}
+	return clauses, bind, nil
+}
@@ -0,0 +1,36 @@
+package sql
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpressionRender(t *testing.T) {
+	testCases := []struct {
+		Expr     Expression
+		Query    string
+		Bind     map[string]any
+		HasError bool
+	}{
+		{Expr: Incr(2), Query: "qty + :val_qty_1", Bind: map[string]any{"val_qty_1": 2}},
+		{Expr: Decr(3), Query: "qty - :val_qty_1", Bind: map[string]any{"val_qty_1": 3}},
+		{Expr: Coalesce(nil), Query: "COALESCE(:val_qty_1, qty)", Bind: map[string]any{"val_qty_1": nil}},
+		{Expr: Expr("GREATEST(qty - ?, ?)", 3, 0), Query: "GREATEST(qty - :val_qty_1, :val_qty_2)", Bind: map[string]any{"val_qty_1": 3, "val_qty_2": 0}},
+		{Expr: Expr("qty - ?"), HasError: true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			query, bind, err := tc.Expr.Render("qty", "val_qty")
+			if tc.HasError {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.Query, query)
+			assert.Equal(t, tc.Bind, bind)
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package filter
+
+import (
+	"bulk/db/sql"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("invalid filter")
+	ErrLimit   = errors.New("filter limit exceeded")
+)
+
+type Limits struct {
+	MaxDepth int
+	// MaxTerms counts the comparisons and the and/or groups.
+	MaxTerms    int
+	MaxListSize int
+}
+
+var DefaultLimits = Limits{MaxDepth: 4, MaxTerms: 32, MaxListSize: 100}
+
+type Node interface {
+	compile(c *compiler) string
+}
+
+type Group struct {
+	Or    bool
+	Nodes []Node
+}
+
+type Term struct {
+	Column string
+	Op     sql.Operator
+	Value  any
+}
+
+// Compile parses a JSON filter against the db tags of Model and renders a
+// parameterised WHERE clause using the BuildCondition bind names.
+func Compile[Model any](data []byte, prefixIdx string, limits Limits) (query string, bind map[string]any, err error) {
+	node, err := Parse(data, SchemaOf[Model](), limits)
+	if err != nil {
+		return "", map[string]any{}, err
+	}
+	query, bind = Render(node, prefixIdx)
+	return query, bind, nil
+}
+
+func Render(node Node, prefixIdx string) (query string, bind map[string]any) {
+	c := &compiler{prefixIdx: prefixIdx, bind: map[string]any{}}
+	if node == nil {
+		return "", c.bind
+	}
+	return node.compile(c), c.bind
+}
+
+func Parse(data []byte, schema Schema, limits Limits) (Node, error) {
+	p := &parser{schema: schema, limits: limits}
+	raw := bytes.TrimSpace(data)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	node, err := p.parseObject(raw, 1)
+	if err != nil {
+		return nil, err
+	}
+	if group, ok := node.(Group); ok && len(group.Nodes) == 0 {
+		return nil, nil
+	}
+	return node, nil
+}
+
+type parser struct {
+	schema Schema
+	limits Limits
+	terms  int
+}
+
+func (p *parser) parseObject(raw json.RawMessage, depth int) (Node, error) {
+	if p.limits.MaxDepth > 0 && depth > p.limits.MaxDepth {
+		return nil, fmt.Errorf("%w: depth over %d", ErrLimit, p.limits.MaxDepth)
+	}
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("%w: expected object: %v", ErrInvalid, err)
+	}
+	// Only the whole filter may be empty, an empty operand would compile to
+	// a dangling AND or OR.
+	if len(obj) == 0 && depth > 1 {
+		return nil, fmt.Errorf("%w: empty object", ErrInvalid)
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nodes := []Node{}
+	for _, key := range keys {
+		switch key {
+		case "and", "or":
+			items := []json.RawMessage{}
+			if err := json.Unmarshal(obj[key], &items); err != nil {
+				return nil, fmt.Errorf("%w: %s expects an array", ErrInvalid, key)
+			}
+			if len(items) == 0 {
+				return nil, fmt.Errorf("%w: %s is empty", ErrInvalid, key)
+			}
+			if err := p.count(); err != nil {
+				return nil, err
+			}
+			group := Group{Or: key == "or"}
+			for _, item := range items {
+				child, err := p.parseObject(item, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				group.Nodes = append(group.Nodes, child)
+			}
+			nodes = append(nodes, group)
+		default:
+			terms, err := p.parseField(key, obj[key])
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, terms...)
+		}
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return Group{Nodes: nodes}, nil
+}
+
+func (p *parser) parseField(name string, raw json.RawMessage) ([]Node, error) {
+	col, ok := p.schema.columns[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown field %q", ErrInvalid, name)
+	}
+	raw = bytes.TrimSpace(raw)
+
+	// Shorthand: scalar for eq, array for in
+	ops := map[string]json.RawMessage{}
+	switch {
+	case len(raw) > 0 && raw[0] == '{':
+		if err := json.Unmarshal(raw, &ops); err != nil {
+			return nil, fmt.Errorf("%w: field %q: %v", ErrInvalid, name, err)
+		}
+		if len(ops) == 0 {
+			return nil, fmt.Errorf("%w: field %q has no operator", ErrInvalid, name)
+		}
+	case len(raw) > 0 && raw[0] == '[':
+		ops[string(sql.OpIn)] = raw
+	default:
+		ops[string(sql.OpEq)] = raw
+	}
+
+	keys := make([]string, 0, len(ops))
+	for key := range ops {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nodes := []Node{}
+	for _, key := range keys {
+		op, ok := sql.ParseOperator(key)
+		if !ok || !col.operators[op] {
+			return nil, fmt.Errorf("%w: operator %q not allowed on field %q", ErrInvalid, key, name)
+		}
+		if err := p.count(); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue(col, op, ops[key])
+		if errors.Is(err, ErrLimit) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %q operator %q: %v", ErrInvalid, name, key, err)
+		}
+		nodes = append(nodes, Term{Column: col.name, Op: op, Value: value})
+	}
+	return nodes, nil
+}
+
+// count adds a comparison or group to the MaxTerms limit.
+func (p *parser) count() error {
+	p.terms++
+	if p.limits.MaxTerms > 0 && p.terms > p.limits.MaxTerms {
+		return fmt.Errorf("%w: more than %d terms", ErrLimit, p.limits.MaxTerms)
+	}
+	return nil
+}
+
+func (p *parser) parseValue(col column, op sql.Operator, raw json.RawMessage) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if !op.IsList() {
+		return convert(col.kind, value)
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("expects an array")
+	}
+	if len(items) == 0 {
+		return nil, errors.New("expects a non-empty array")
+	}
+	if p.limits.MaxListSize > 0 && len(items) > p.limits.MaxListSize {
+		return nil, fmt.Errorf("%w: list over %d items", ErrLimit, p.limits.MaxListSize)
+	}
+	list := make([]any, 0, len(items))
+	for _, item := range items {
+		v, err := convert(col.kind, item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func convert(k kind, value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, errors.New("value required")
+	case string:
+		switch k {
+		case kindString:
+			return v, nil
+		case kindTime:
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, errors.New("must be a RFC3339 time")
+			}
+			return t, nil
+		}
+	case bool:
+		if k == kindBool {
+			return v, nil
+		}
+	case json.Number:
+		switch k {
+		case kindInt:
+			n, err := strconv.ParseInt(v.String(), 10, 64)
+			if err != nil {
+				return nil, errors.New("must be an integer")
+			}
+			return n, nil
+		case kindUint:
+			n, err := strconv.ParseUint(v.String(), 10, 64)
+			if err != nil {
+				return nil, errors.New("must be a non-negative integer")
+			}
+			return n, nil
+		case kindFloat:
+			n, err := v.Float64()
+			if err != nil || math.IsInf(n, 0) {
+				return nil, errors.New("must be a number")
+			}
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value %v", value)
+}
+
+type compiler struct {
+	prefixIdx string
+	bind      map[string]any
+}
+
+func (c *compiler) bindKey(column string, op sql.Operator) string {
+	base := fmt.Sprintf("cond_%s%s", column, op.Suffix())
+	if c.prefixIdx != "" {
+		base = fmt.Sprintf("idx%s_%s", c.prefixIdx, base)
+	}
+	key := base
+	for n := 2; ; n++ {
+		if _, ok := c.bind[key]; !ok {
+			return key
+		}
+		key = fmt.Sprintf("%s_%d", base, n)
+	}
+}
+
+func (t Term) compile(c *compiler) string {
+	key := c.bindKey(t.Column, t.Op)
+	c.bind[key] = t.Value
+	str, _ := t.Op.Render(t.Column, key)
+	return str
+}
+
+func (g Group) compile(c *compiler) string {
+	parts := []string{}
+	for _, node := range g.Nodes {
+		str := node.compile(c)
+		if child, ok := node.(Group); ok && len(child.Nodes) > 1 {
+			str = fmt.Sprintf("(%s)", str)
+		}
+		parts = append(parts, str)
+	}
+	sep := " AND "
+	if g.Or {
+		sep = " OR "
+	}
+	return strings.Join(parts, sep)
+}
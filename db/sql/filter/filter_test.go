@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type model struct {
+	ID     *int     `db:"id"`
+	SKU    *string  `db:"sku" filter:"eq,in"`
+	Name   *string  `db:"name"`
+	Price  *float64 `db:"price"`
+	Secret *string  `db:"secret" filter:"-"`
+}
+
+type expected struct {
+	Query string
+	Bind  map[string]any
+}
+
+func TestCompile(t *testing.T) {
+
+	t.Run("failed", func(t *testing.T) {
+		testCases := []struct {
+			Filter string
+			Err    error
+		}{
+			{Filter: `[]`, Err: ErrInvalid},
+			{Filter: `{"unknown":1}`, Err: ErrInvalid},
+			{Filter: `{"secret":"x"}`, Err: ErrInvalid},
+			{Filter: `{"sku":{"like":"%x%"}}`, Err: ErrInvalid},
+			{Filter: `{"price":{"gte":"ten"}}`, Err: ErrInvalid},
+			{Filter: `{"id":{"in":[]}}`, Err: ErrInvalid},
+			{Filter: `{"id":{"eq":1.5}}`, Err: ErrInvalid},
+			{Filter: `{"and":[]}`, Err: ErrInvalid},
+			{Filter: `{"or":[]}`, Err: ErrInvalid},
+			{Filter: `{"and":[{},{"price":{"gte":1}}]}`, Err: ErrInvalid},
+			{Filter: `{"or":[{"id":1},{"and":[{}]}]}`, Err: ErrInvalid},
+			{Filter: `{"and":[{"or":[{"and":[{"or":[{"id":1}]}]}]}]}`, Err: ErrLimit},
+			{Filter: `{"id":[1,2,3,4,5,6]}`, Err: ErrLimit},
+			{Filter: `{"and":[{"id":1},{"id":2},{"id":3},{"id":4},{"id":5}]}`, Err: ErrLimit},
+			{Filter: `{"and":[{"or":[{"id":1},{"id":2}]},{"id":3}]}`, Err: ErrLimit},
+		}
+
+		limits := Limits{MaxDepth: 4, MaxTerms: 4, MaxListSize: 5}
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				_, _, err := Compile[model]([]byte(tc.Filter), "", limits)
+				assert.True(t, errors.Is(err, tc.Err), err)
+			})
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		testCases := []struct {
+			Filter   string
+			PrefixID string
+			Expected expected
+		}{
+			{
+				Filter: `{"and":[{"price":{"gte":10}},{"or":[{"sku":{"in":["a","b"]}},{"name":{"like":"%x%"}}]}]}`,
+				Expected: expected{
+					Query: "price>=:cond_price_gte AND (sku IN (:cond_sku) OR name LIKE :cond_name_like)",
+					Bind:  map[string]any{"cond_price_gte": 10.0, "cond_sku": []any{"a", "b"}, "cond_name_like": "%x%"},
+				},
+			},
+			{
+				Filter: `{"price":{"gte":1,"lt":5},"id":[1,2]}`,
+				Expected: expected{
+					Query: "id IN (:cond_id) AND price>=:cond_price_gte AND price<:cond_price_lt",
+					Bind:  map[string]any{"cond_id": []any{int64(1), int64(2)}, "cond_price_gte": 1.0, "cond_price_lt": 5.0},
+				},
+			},
+			{
+				Filter:   `{"or":[{"id":1},{"id":2}]}`,
+				PrefixID: "3",
+				Expected: expected{
+					Query: "id=:idx3_cond_id OR id=:idx3_cond_id_2",
+					Bind:  map[string]any{"idx3_cond_id": int64(1), "idx3_cond_id_2": int64(2)},
+				},
+			},
+			{
+				Filter: `{}`,
+				Expected: expected{
+					Query: "",
+					Bind:  map[string]any{},
+				},
+			},
+		}
+
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				query, bind, err := Compile[model]([]byte(tc.Filter), tc.PrefixID, DefaultLimits)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.Expected.Query, query)
+				assert.Equal(t, tc.Expected.Bind, bind)
+			})
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"bulk/db/sql"
+	"bulk/utils"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const Tag = "filter"
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindInt
+	kindUint
+	kindFloat
+	kindBool
+	kindTime
+)
+
+var defaultOperators = map[kind][]sql.Operator{
+	kindString: {sql.OpEq, sql.OpNe, sql.OpIn, sql.OpNin, sql.OpLike},
+	kindInt:    {sql.OpEq, sql.OpNe, sql.OpGt, sql.OpGte, sql.OpLt, sql.OpLte, sql.OpIn, sql.OpNin},
+	kindUint:   {sql.OpEq, sql.OpNe, sql.OpGt, sql.OpGte, sql.OpLt, sql.OpLte, sql.OpIn, sql.OpNin},
+	kindFloat:  {sql.OpEq, sql.OpNe, sql.OpGt, sql.OpGte, sql.OpLt, sql.OpLte, sql.OpIn, sql.OpNin},
+	kindBool:   {sql.OpEq, sql.OpNe},
+	kindTime:   {sql.OpEq, sql.OpNe, sql.OpGt, sql.OpGte, sql.OpLt, sql.OpLte},
+}
+
+type column struct {
+	name      string
+	kind      kind
+	operators map[sql.Operator]bool
+}
+
+// Schema lists the filterable columns of a model, read from its db tags. The
+// filter tag narrows the allowed operators (filter:"eq,in") or hides a column
+// (filter:"-").
+type Schema struct {
+	columns map[string]column
+}
+
+func SchemaOf[Model any]() Schema {
+	schema := Schema{columns: map[string]column{}}
+	rt := reflect.TypeOf((*Model)(nil)).Elem()
+	if rt.Kind() != reflect.Struct {
+		return schema
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, _ := utils.ParseTag(field.Tag.Get(sql.Tag))
+		if name == "" || name == "-" {
+			continue
+		}
+		filterTag := field.Tag.Get(Tag)
+		if filterTag == "-" {
+			continue
+		}
+		k, ok := kindOf(field.Type)
+		if !ok {
+			continue
+		}
+		operators := defaultOperators[k]
+		if filterTag != "" {
+			operators = []sql.Operator{}
+			for _, part := range strings.Split(filterTag, ",") {
+				if op, ok := sql.ParseOperator(strings.TrimSpace(part)); ok {
+					operators = append(operators, op)
+				}
+			}
+		}
+		col := column{name: name, kind: k, operators: map[sql.Operator]bool{}}
+		for _, op := range operators {
+			col.operators[op] = true
+		}
+		schema.columns[name] = col
+	}
+	return schema
+}
+
+func kindOf(rt reflect.Type) (kind, bool) {
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == reflect.TypeOf(time.Time{}) {
+		return kindTime, true
+	}
+	switch rt.Kind() {
+	case reflect.String:
+		return kindString, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return kindInt, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return kindUint, true
+	case reflect.Float32, reflect.Float64:
+		return kindFloat, true
+	case reflect.Bool:
+		return kindBool, true
+	}
+	return 0, false
+}
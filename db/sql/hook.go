@@ -0,0 +1,112 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hooks are optional methods the repository calls on pointers to its
+// Payload, Condition and Model types. db is the transaction of the operation
+// when there is one, so hooks may read or write in it. A hook error aborts
+// the operation and rolls its transaction back.
+
+type BeforeCreateHook interface {
+	BeforeCreate(ctx context.Context, db Executor) error
+}
+
+// AfterCreateHook receives the primary key of the inserted row.
+type AfterCreateHook interface {
+	AfterCreate(ctx context.Context, db Executor, id int64) error
+}
+
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context, db Executor) error
+}
+
+type AfterUpdateHook interface {
+	AfterUpdate(ctx context.Context, db Executor) error
+}
+
+// BeforeDeleteHook is called on the Condition of Delete and HardDelete.
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context, db Executor) error
+}
+
+// NormalizeHook is called on a copy of every Condition before it is
+// rendered, so conditions match values the way payload hooks store them.
+type NormalizeHook interface {
+	Normalize()
+}
+
+// AfterFindHook is called on every Model read by the repository.
+type AfterFindHook interface {
+	AfterFind(ctx context.Context, db Executor) error
+}
+
+// RowError reports the row of a bulk operation that failed.
+type RowError struct {
+	Index int
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Index, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+func beforeCreate(ctx context.Context, db Executor, target any) error {
+	if hook, ok := target.(BeforeCreateHook); ok {
+		if err := hook.BeforeCreate(ctx, db); err != nil {
+			return fmt.Errorf("before create: %w", err)
+		}
+	}
+	return nil
+}
+
+func beforeUpdate(ctx context.Context, db Executor, target any) error {
+	if hook, ok := target.(BeforeUpdateHook); ok {
+		if err := hook.BeforeUpdate(ctx, db); err != nil {
+			return fmt.Errorf("before update: %w", err)
+		}
+	}
+	return nil
+}
+
+func afterUpdate(ctx context.Context, db Executor, target any) error {
+	if hook, ok := target.(AfterUpdateHook); ok {
+		if err := hook.AfterUpdate(ctx, db); err != nil {
+			return fmt.Errorf("after update: %w", err)
+		}
+	}
+	return nil
+}
+
+func beforeDelete(ctx context.Context, db Executor, target any) error {
+	if hook, ok := target.(BeforeDeleteHook); ok {
+		if err := hook.BeforeDelete(ctx, db); err != nil {
+			return fmt.Errorf("before delete: %w", err)
+		}
+	}
+	return nil
+}
+
+func afterFind(ctx context.Context, db Executor, target any) error {
+	if hook, ok := target.(AfterFindHook); ok {
+		if err := hook.AfterFind(ctx, db); err != nil {
+			return fmt.Errorf("after find: %w", err)
+		}
+	}
+	return nil
+}
+
+func afterCreate(ctx context.Context, db Executor, target any, id int64) error {
+	if hook, ok := target.(AfterCreateHook); ok {
+		if err := hook.AfterCreate(ctx, db, id); err != nil {
+			return fmt.Errorf("after create: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type Operator string
+
+const (
+	OpEq   Operator = "eq"
+	OpNe   Operator = "ne"
+	OpGt   Operator = "gt"
+	OpGte  Operator = "gte"
+	OpLt   Operator = "lt"
+	OpLte  Operator = "lte"
+	OpLike Operator = "like"
+	OpIn   Operator = "in"
+	OpNin  Operator = "nin"
+)
+
+var Operators = []Operator{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpLike, OpIn, OpNin}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+func IsIdentifier(name string) bool {
+	return identifierPattern.MatchString(name)
+}
+
+func ParseOperator(name string) (Operator, bool) {
+	for _, op := range Operators {
+		if string(op) == name {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// IsList reports whether the operator compares against a list of values.
+func (op Operator) IsList() bool {
+	return op == OpIn || op == OpNin
+}
+
+// Suffix is the part appended to bind names and query keys, empty for the
+// implicit equality and membership operators.
+func (op Operator) Suffix() string {
+	if op == OpEq || op == OpIn {
+		return ""
+	}
+	return "_" + string(op)
+}
+
+func (op Operator) Render(column, bindKey string) (string, error) {
+	switch op {
+	case OpEq:
+		return fmt.Sprintf("%s=:%s", column, bindKey), nil
+	case OpNe:
+		return fmt.Sprintf("%s<>:%s", column, bindKey), nil
+	case OpGt:
+		return fmt.Sprintf("%s>:%s", column, bindKey), nil
+	case OpGte:
+		return fmt.Sprintf("%s>=:%s", column, bindKey), nil
+	case OpLt:
+		return fmt.Sprintf("%s<:%s", column, bindKey), nil
+	case OpLte:
+		return fmt.Sprintf("%s<=:%s", column, bindKey), nil
+	case OpLike:
+		return fmt.Sprintf("%s LIKE :%s", column, bindKey), nil
+	case OpIn:
+		return fmt.Sprintf("%s IN (:%s)", column, bindKey), nil
+	case OpNin:
+		return fmt.Sprintf("%s NOT IN (:%s)", column, bindKey), nil
+	}
+	return "", fmt.Errorf("unknown operator %q", op)
+}
+
+// fieldOperator resolves the operator of a condition field from its tag
+// options, defaulting to equality or membership depending on the value kind.
+func fieldOperator(options map[string]string, isList bool) (Operator, error) {
+	op := OpEq
+	if isList {
+		op = OpIn
+	}
+	for key := range options {
+		parsed, ok := ParseOperator(key)
+		if !ok {
+			continue
+		}
+		op = parsed
+	}
+	if isList && op == OpEq {
+		op = OpIn
+	}
+	if isList && op == OpNe {
+		op = OpNin
+	}
+	if op.IsList() != isList {
+		return "", fmt.Errorf("operator %q does not fit value", op)
+	}
+	return op, nil
+}
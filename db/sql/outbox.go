@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"bulk/utils"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Actions of the events a repository records, the event type is
+// <table>.<action>, e.g. products.updated.
+const (
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventDeleted  = "deleted"
+	EventRestored = "restored"
+)
+
+// Event is a change of one row. Key is the text of its primary key; Payload
+// holds the columns of the row after the change, only the primary key for
+// deletes.
+type Event struct {
+	Type    string
+	Key     string
+	Payload map[string]any
+}
+
+// EventRecorder stores the events of a write in the transaction db of the
+// write, so they commit or roll back together.
+type EventRecorder interface {
+	Append(ctx context.Context, db Executor, events []Event) error
+}
+
+// WithOutbox records an event for every row written by Create, CreateBulk,
+// Update, UpdateBulk, Delete, HardDelete and Restore.
+func WithOutbox(outbox EventRecorder) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.outbox = outbox
+	}
+}
+
+// published runs write and records an action event for every row matching
+// where before it. The rows are locked when read and write gets an option
+// restricting it to them, so every written row has its event.
+func (r *Repository[Model, Payload, Condition]) published(ctx context.Context, db Executor, action string, where string, bind map[string]any, write func(opts ...QueryOption) error) error {
+	if r.outbox == nil {
+		return write()
+	}
+	_, found, err := r.snapshot(ctx, db, nil, where, bind, true)
+	if err != nil {
+		return fmt.Errorf("failed read rows before %s: %w", action, err)
+	}
+	ids := make([]string, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	keys := make([]any, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, found[id])
+	}
+	if err := write(keyed(r.pk, keys)); err != nil {
+		return err
+	}
+	return r.emit(ctx, db, action, keys)
+}
+
+// emit records an action event for each primary key in keys, in order.
+func (r *Repository[Model, Payload, Condition]) emit(ctx context.Context, db Executor, action string, keys []any) error {
+	if r.outbox == nil || len(keys) == 0 {
+		return nil
+	}
+	rows := map[string]map[string]any{}
+	if action != EventDeleted {
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (:outbox_ids)", strings.Join(r.columns, ", "), r.table, r.pk)
+		query, args, err := BindNamedQuery(query, map[string]any{"outbox_ids": keys})
+		if err != nil {
+			return fmt.Errorf("failed bind named query: %w", err)
+		}
+		data := []Model{}
+		if err := sqlx.SelectContext(ctx, db, &data, db.Rebind(query), args...); err != nil {
+			return fmt.Errorf("failed read %s rows: %w", action, TranslateError(err))
+		}
+		for _, item := range data {
+			fieldMap, err := utils.StructToMap(item, Tag)
+			if err != nil {
+				return fmt.Errorf("failed make field map: %w", err)
+			}
+			if id := formatValue(fieldMap[r.pk]); id != nil {
+				rows[*id] = fieldMap
+			}
+		}
+	}
+	events := make([]Event, 0, len(keys))
+	for _, key := range keys {
+		id := formatValue(key)
+		if id == nil {
+			continue
+		}
+		payload, ok := rows[*id]
+		if !ok {
+			payload = map[string]any{r.pk: key}
+		}
+		events = append(events, Event{Type: fmt.Sprintf("%s.%s", r.table, action), Key: *id, Payload: payload})
+	}
+	if err := r.outbox.Append(ctx, db, events); err != nil {
+		return fmt.Errorf("failed record events: %w", err)
+	}
+	return nil
+}
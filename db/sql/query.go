@@ -2,29 +2,112 @@ package sql
 
 import (
 	"bulk/utils"
-	"errors"
 	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
 const Tag = "db"
 
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	where  []string
+	now    time.Time
+	actor  any
+	sets   map[string]any
+	guards []guard
+	keys   *keySet
+}
+
+// keySet restricts a write to the rows with the given primary keys.
+type keySet struct {
+	column string
+	values []any
+}
+
+// keyed restricts an update or delete to the rows with the given primary
+// keys, none when keys is empty, so it writes exactly the rows read before
+// it.
+func keyed(column string, keys []any) QueryOption {
+	return func(o *queryOptions) {
+		o.keys = &keySet{column: column, values: keys}
+	}
+}
+
+// keyClause renders the key restriction of the options into binds, empty
+// without one.
+func (o queryOptions) keyClause(prefix string, binds map[string]any) string {
+	if o.keys == nil {
+		return ""
+	}
+	if len(o.keys.values) == 0 {
+		return "1 = 0"
+	}
+	keyBind := fmt.Sprintf("%skeys", prefix)
+	binds[keyBind] = o.keys.values
+	return fmt.Sprintf("%s IN (:%s)", o.keys.column, keyBind)
+}
+
+// Where adds a raw clause ANDed to the built condition, e.g. a soft delete
+// scope. It does not count as a condition for updates and deletes.
+func Where(clause string) QueryOption {
+	return func(o *queryOptions) {
+		o.where = append(o.where, clause)
+	}
+}
+
+func newQueryOptions(opts []QueryOption) queryOptions {
+	o := queryOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.now.IsZero() {
+		o.now = time.Now()
+	}
+	return o
+}
+
+// and joins condQuery with the extra clauses of the options.
+func (o queryOptions) and(condQuery string) string {
+	return and(append([]string{condQuery}, o.where...)...)
+}
+
+// and joins the non empty clauses with AND. When there are several, each is
+// parenthesized so an OR in one clause cannot escape the others.
+func and(clauses ...string) string {
+	nonEmpty := []string{}
+	for _, clause := range clauses {
+		if clause != "" {
+			nonEmpty = append(nonEmpty, clause)
+		}
+	}
+	if len(nonEmpty) < 2 {
+		return strings.Join(nonEmpty, "")
+	}
+	for i, clause := range nonEmpty {
+		nonEmpty[i] = "(" + clause + ")"
+	}
+	return strings.Join(nonEmpty, " AND ")
+}
+
 type Update[Payload any, Condition any] struct {
 	Payload   Payload
 	Condition Condition
+	Options   []QueryOption
 }
 
-func BuildBulkUpdateQuery[Payload any, Condition any](table string, inputs []Update[Payload, Condition]) (query string, bind map[string]any, err error) {
+func BuildBulkUpdateQuery[Payload any, Condition any](table string, inputs []Update[Payload, Condition], opts ...QueryOption) (query string, bind map[string]any, err error) {
 	bind = map[string]any{}
 	queryArr := []string{}
 	for idx, input := range inputs {
 		prefixIdx := strconv.Itoa(idx)
-		itemQuery, itemBind, err := BuildUpdateQuery(table, input.Payload, input.Condition, prefixIdx)
+		itemQuery, itemBind, err := BuildUpdateQuery(table, input.Payload, input.Condition, prefixIdx, append(input.Options, opts...)...)
 		if err != nil {
 			return "", map[string]any{}, fmt.Errorf("failed to build bulk update query: %w", err)
 		}
@@ -38,7 +121,7 @@ func BuildBulkUpdateQuery[Payload any, Condition any](table string, inputs []Upd
 	return query, bind, nil
 }
 
-func BuildUpdateQuery[Payload any, Condition any](table string, payload Payload, condition Condition, prefixIdx string) (query string, binds map[string]any, err error) {
+func BuildUpdateQuery[Payload any, Condition any](table string, payload Payload, condition Condition, prefixIdx string, opts ...QueryOption) (query string, binds map[string]any, err error) {
 
 	// Field
 	binds = make(map[string]any)
@@ -47,24 +130,49 @@ func BuildUpdateQuery[Payload any, Condition any](table string, payload Payload,
 	if err != nil {
 		return query, binds, fmt.Errorf("failed to build update query, make field map: %w", err)
 	}
+	o := newQueryOptions(opts)
+	for key, val := range o.sets {
+		if !IsIdentifier(key) {
+			return query, binds, &InvalidFieldError{Field: key, Reason: "not a column name"}
+		}
+		fieldMap[key] = val
+	}
+	version, hasVersion := VersionColumn(payload)
+	delete(fieldMap, version)
+	if len(fieldMap) == 0 {
+		return query, binds, fmt.Errorf("failed to build update query: %w", ErrEmptyPayload)
+	}
+	o.stamp(fieldMap, payload, false)
+	prefix := ""
+	if prefixIdx != "" {
+		prefix = fmt.Sprintf("idx%s_", prefixIdx)
+	}
 	fieldKeys := utils.SortMapKeys(fieldMap)
 	for _, key := range fieldKeys {
-		keyBind := fmt.Sprintf("val_%s", key)
-		if prefixIdx != "" {
-			keyBind = fmt.Sprintf("idx%s_val_%s", prefixIdx, key)
-		}
+		keyBind := fmt.Sprintf("%sval_%s", prefix, key)
 		val := fieldMap[key]
+		if expr, ok := val.(Expression); ok {
+			rendered, exprBind, err := expr.Render(key, keyBind)
+			if err != nil {
+				return query, binds, &InvalidFieldError{Field: key, Reason: err.Error()}
+			}
+			fields = append(fields, fmt.Sprintf("%s=%s", key, rendered))
+			for k, v := range exprBind {
+				binds[k] = v
+			}
+			continue
+		}
 		fields = append(fields, fmt.Sprintf("%s=:%s", key, keyBind))
 		binds[keyBind] = val
 	}
+	if hasVersion {
+		fields = append(fields, fmt.Sprintf("%s=%s+1", version, version))
+	}
 
 	// Condition
-	condQuery, condBind, err := BuildCondition(condition, prefixIdx)
+	condQuery, condBind, err := buildUpdateWhere(payload, condition, prefixIdx, o)
 	if err != nil {
-		return query, binds, fmt.Errorf("failed to build update query, make condition map: %w", err)
-	}
-	if condQuery == "" {
-		return query, binds, fmt.Errorf("make sure conditional not empty: %w", err)
+		return query, binds, err
 	}
 	for key, val := range condBind {
 		binds[key] = val
@@ -75,7 +183,61 @@ func BuildUpdateQuery[Payload any, Condition any](table string, payload Payload,
 	return query, binds, nil
 }
 
-func BuildCreateQuery[Payload any](table string, input Payload) (query string, binds map[string]any, err error) {
+// buildUpdateWhere renders the WHERE clause of BuildUpdateQuery: the
+// condition, the version check, the guards, the key restriction and the
+// Where options.
+func buildUpdateWhere[Payload any, Condition any](payload Payload, condition Condition, prefixIdx string, o queryOptions) (condQuery string, binds map[string]any, err error) {
+	condQuery, binds, err = BuildCondition(condition, prefixIdx)
+	if err != nil {
+		return "", binds, fmt.Errorf("failed to build update query, make condition map: %w", err)
+	}
+	if condQuery == "" {
+		return "", binds, fmt.Errorf("make sure conditional not empty: %w", ErrEmptyCondition)
+	}
+	prefix := ""
+	if prefixIdx != "" {
+		prefix = fmt.Sprintf("idx%s_", prefixIdx)
+	}
+	if version, ok := VersionColumn(payload); ok {
+		fieldMap, err := utils.StructToMap(payload, Tag)
+		if err != nil {
+			return "", binds, fmt.Errorf("failed to build update query, make field map: %w", err)
+		}
+		expected, checkVersion := fieldMap[version]
+		if val, ok := o.sets[version]; ok {
+			expected, checkVersion = val, true
+		}
+		if checkVersion {
+			keyBind := fmt.Sprintf("%sver_%s", prefix, version)
+			condQuery = fmt.Sprintf("%s AND %s=:%s", condQuery, version, keyBind)
+			binds[keyBind] = expected
+		}
+	}
+	guards, guardBind, err := o.renderGuards(prefix)
+	if err != nil {
+		return "", binds, err
+	}
+	for key, val := range guardBind {
+		binds[key] = val
+	}
+	if clause := o.keyClause(prefix, binds); clause != "" {
+		guards = append(guards, clause)
+	}
+	return and(append(append([]string{condQuery}, guards...), o.where...)...), binds, nil
+}
+
+// VersionColumn returns the column of the payload field tagged
+// db:"name,version". Updates increment it and, when the field is set, only
+// match rows still at that version.
+func VersionColumn(payload any) (string, bool) {
+	columns := taggedColumns(payload, "version")
+	if len(columns) == 0 {
+		return "", false
+	}
+	return columns[0], true
+}
+
+func BuildCreateQuery[Payload any](table string, input Payload, opts ...QueryOption) (query string, binds map[string]any, err error) {
 	binds = map[string]any{}
 	fields := []string{}
 	placeholders := []string{}
@@ -83,6 +245,10 @@ func BuildCreateQuery[Payload any](table string, input Payload) (query string, b
 	if err != nil {
 		return "", map[string]any{}, fmt.Errorf("failed to build create query, make field map: %w", err)
 	}
+	if len(fieldMap) == 0 {
+		return "", map[string]any{}, fmt.Errorf("failed to build create query: %w", ErrEmptyPayload)
+	}
+	newQueryOptions(opts).stamp(fieldMap, input, true)
 	fieldKeys := utils.SortMapKeys(fieldMap)
 	for _, key := range fieldKeys {
 		val := fieldMap[key]
@@ -99,96 +265,217 @@ func BuildCreateQuery[Payload any](table string, input Payload) (query string, b
 	return query, binds, nil
 }
 
-func BuildSelectQuery[Condition any](table string, fields []string, condition *Condition, paginate *utils.Paginate) (query string, bind map[string]any, err error) {
+// BuildBulkCreateQuery inserts all inputs in one statement. Columns are the
+// union of the fields set on any input; inputs missing a column bind NULL.
+func BuildBulkCreateQuery[Payload any](table string, inputs []Payload, opts ...QueryOption) (query string, binds map[string]any, err error) {
+	binds = map[string]any{}
+	if len(inputs) == 0 {
+		return "", map[string]any{}, fmt.Errorf("failed to build bulk create query: %w", ErrEmptyPayload)
+	}
+	o := newQueryOptions(opts)
+	fieldMaps := []map[string]any{}
+	columns := map[string]any{}
+	for _, input := range inputs {
+		fieldMap, err := utils.StructToMap(input, Tag)
+		if err != nil {
+			return "", map[string]any{}, fmt.Errorf("failed to build bulk create query, make field map: %w", err)
+		}
+		if len(fieldMap) > 0 {
+			o.stamp(fieldMap, input, true)
+		}
+		for key := range fieldMap {
+			columns[key] = true
+		}
+		fieldMaps = append(fieldMaps, fieldMap)
+	}
+	if len(columns) == 0 {
+		return "", map[string]any{}, fmt.Errorf("failed to build bulk create query: %w", ErrEmptyPayload)
+	}
+	fields := utils.SortMapKeys(columns)
+	rows := []string{}
+	for idx, fieldMap := range fieldMaps {
+		placeholders := []string{}
+		for _, key := range fields {
+			keyBind := fmt.Sprintf("idx%d_%s", idx, key)
+			placeholders = append(placeholders, fmt.Sprintf(":%s", keyBind))
+			binds[keyBind] = fieldMap[key]
+		}
+		rows = append(rows, fmt.Sprintf("(%s)", strings.Join(placeholders, ", ")))
+	}
+	query = fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s",
+		table,
+		strings.Join(fields, ", "),
+		strings.Join(rows, ", "),
+	)
+	return query, binds, nil
+}
+
+func BuildSelectQuery[Condition any](table string, fields []string, condition *Condition, paginate *utils.Paginate, opts ...QueryOption) (query string, bind map[string]any, err error) {
 	bind = map[string]any{}
 	if len(fields) == 0 {
-		return "", map[string]any{}, errors.New("fields required")
+		return "", map[string]any{}, fmt.Errorf("fields required: %w", ErrInvalidField)
+	}
+	for _, field := range fields {
+		if field != "*" && !IsIdentifier(field) {
+			return "", map[string]any{}, &InvalidFieldError{Field: field, Reason: "not a column name"}
+		}
 	}
 	sort.Strings(fields)
 	query = fmt.Sprintf("SELECT %s FROM %s", strings.Join(fields, ", "), table)
 
 	// Condition
+	condQuery := ""
 	if condition != nil {
-		condQuery, condBind, err := BuildCondition(*condition, "")
+		where, condBind, err := BuildCondition(*condition, "")
 		if err != nil {
 			return "", map[string]any{}, fmt.Errorf("failed to build field map: %w", err)
 		}
-		if condQuery != "" {
-			query = fmt.Sprintf("%s WHERE %s", query, condQuery)
-			for k, v := range condBind {
-				bind[k] = v
-			}
+		condQuery = where
+		for k, v := range condBind {
+			bind[k] = v
 		}
 	}
+	if condQuery = newQueryOptions(opts).and(condQuery); condQuery != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, condQuery)
+	}
 
 	// Pagination
 	if paginate != nil {
-		query = fmt.Sprintf("%s LIMIT :paginate_limit OFFSET :paginate_offset", query)
-		bind["paginate_offset"] = paginate.GetOffset()
-		bind["paginate_limit"] = paginate.Limit
+		orderBy, err := buildOrderBy(paginate.Sort)
+		if err != nil {
+			return "", map[string]any{}, fmt.Errorf("failed build sort: %w", err)
+		}
+		if orderBy != "" {
+			query = fmt.Sprintf("%s ORDER BY %s", query, orderBy)
+		}
+		if paginate.Limit > 0 {
+			query = fmt.Sprintf("%s LIMIT :paginate_limit OFFSET :paginate_offset", query)
+			bind["paginate_offset"] = paginate.GetOffset()
+			bind["paginate_limit"] = paginate.Limit
+		}
 	}
 
 	return query, bind, nil
 }
 
-func BuildCountQuery[Condition any](table string, condition *Condition) (query string, bind map[string]any, err error) {
+func buildOrderBy(sorts []utils.Sort) (string, error) {
+	orders := []string{}
+	for _, s := range sorts {
+		if !IsIdentifier(s.Field) {
+			return "", &InvalidFieldError{Field: s.Field, Reason: "not a sortable column"}
+		}
+		direction := "ASC"
+		if s.Desc {
+			direction = "DESC"
+		}
+		orders = append(orders, fmt.Sprintf("%s %s", s.Field, direction))
+	}
+	return strings.Join(orders, ", "), nil
+}
+
+func BuildCountQuery[Condition any](table string, condition *Condition, opts ...QueryOption) (query string, bind map[string]any, err error) {
 	bind = map[string]any{}
 	query = fmt.Sprintf("SELECT COUNT(*) FROM %s", table)
 
 	// Condition
+	condQuery := ""
 	if condition != nil {
-		condQuery, condBind, err := BuildCondition(*condition, "")
+		where, condBind, err := BuildCondition(*condition, "")
 		if err != nil {
 			return "", map[string]any{}, fmt.Errorf("failed to build field map: %w", err)
 		}
-		if condQuery != "" {
-			query = fmt.Sprintf("%s WHERE %s", query, condQuery)
-			for k, v := range condBind {
-				bind[k] = v
-			}
+		condQuery = where
+		for k, v := range condBind {
+			bind[k] = v
 		}
 	}
+	if condQuery = newQueryOptions(opts).and(condQuery); condQuery != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, condQuery)
+	}
 
 	return query, bind, nil
 }
 
-func BuildDeleteQuery[Condition any](table string, condition Condition) (query string, bind map[string]any, err error) {
+func BuildDeleteQuery[Condition any](table string, condition Condition, opts ...QueryOption) (query string, bind map[string]any, err error) {
 	condQuery, condBind, err := BuildCondition(condition, "")
 	if err != nil {
 		return "", map[string]any{}, fmt.Errorf("failed build condition: %w", err)
 	}
 	if condQuery == "" {
-		return "", map[string]any{}, fmt.Errorf("make sure condition param not empty: %w", err)
+		return "", map[string]any{}, fmt.Errorf("make sure condition param not empty: %w", ErrEmptyCondition)
 	}
-	query = fmt.Sprintf("DELETE FROM %s WHERE %s", table, condQuery)
+	o := newQueryOptions(opts)
+	query = fmt.Sprintf("DELETE FROM %s WHERE %s", table, and(append([]string{condQuery, o.keyClause("", condBind)}, o.where...)...))
 	return query, condBind, nil
 }
 
+// BuildSoftDeleteQuery marks the rows matching condition by setting column to
+// deletedAt. A nil deletedAt clears the mark, restoring the rows.
+func BuildSoftDeleteQuery[Condition any](table string, column string, deletedAt any, condition Condition, opts ...QueryOption) (query string, bind map[string]any, err error) {
+	if !IsIdentifier(column) {
+		return "", map[string]any{}, &InvalidFieldError{Field: column, Reason: "not a column name"}
+	}
+	condQuery, bind, err := BuildCondition(condition, "")
+	if err != nil {
+		return "", map[string]any{}, fmt.Errorf("failed build condition: %w", err)
+	}
+	if condQuery == "" {
+		return "", map[string]any{}, fmt.Errorf("make sure condition param not empty: %w", ErrEmptyCondition)
+	}
+	keyBind := fmt.Sprintf("val_%s", column)
+	bind[keyBind] = deletedAt
+	o := newQueryOptions(opts)
+	where := and(append([]string{condQuery, o.keyClause("", bind)}, o.where...)...)
+	query = fmt.Sprintf("UPDATE %s SET %s=:%s WHERE %s", table, column, keyBind, where)
+	return query, bind, nil
+}
+
 func BuildCondition[Condition any](condition Condition, prefixIdx string) (query string, bind map[string]any, err error) {
+	if hook, ok := any(&condition).(NormalizeHook); ok {
+		hook.Normalize()
+	}
 	bind = map[string]any{}
-	cond := []string{}
-	condMap, err := utils.StructToMap(condition, Tag)
+	clauses := map[string]string{}
+	fields, err := utils.StructFields(condition, Tag)
 	if err != nil {
 		return "", map[string]any{}, fmt.Errorf("failed build condition: %w", err)
 	}
-	for _, key := range utils.SortMapKeys(condMap) {
-		val := condMap[key]
-		kind := reflect.TypeOf(val).Kind()
-		bindKey := fmt.Sprintf("cond_%s", key)
+	for _, field := range fields {
+		kind := reflect.TypeOf(field.Value).Kind()
+		isList := kind == reflect.Array || kind == reflect.Slice
+		if isList && reflect.ValueOf(field.Value).Len() == 0 {
+			continue
+		}
+		op, err := fieldOperator(field.Options, isList)
+		if err != nil {
+			return "", map[string]any{}, &InvalidFieldError{Field: field.Name, Reason: err.Error()}
+		}
+		suffix := op.Suffix()
+		// An explicit in binds apart from the plain column, so a scalar and
+		// a list field may filter the same column.
+		if _, ok := field.Options[string(OpIn)]; ok {
+			suffix = "_" + string(OpIn)
+		}
+		bindKey := fmt.Sprintf("cond_%s%s", field.Name, suffix)
 		if prefixIdx != "" {
-			bindKey = fmt.Sprintf("idx%s_cond_%s", prefixIdx, key)
+			bindKey = fmt.Sprintf("idx%s_%s", prefixIdx, bindKey)
 		}
-		str := ""
-		if kind == reflect.Array || kind == reflect.Slice {
-			if reflect.ValueOf(val).Len() == 0 {
-				continue
-			}
-			str = fmt.Sprintf("%s IN (:%s)", key, bindKey)
-		} else {
-			str = fmt.Sprintf("%s=:%s", key, bindKey)
+		// Two fields binding one key, e.g. ID and IDs on one column, would
+		// drop a clause and widen the matched rows.
+		if _, ok := bind[bindKey]; ok {
+			return "", map[string]any{}, &InvalidFieldError{Field: field.Name, Reason: fmt.Sprintf("condition %s set twice", bindKey)}
 		}
-		cond = append(cond, str)
-		bind[bindKey] = val
+		str, err := op.Render(field.Name, bindKey)
+		if err != nil {
+			return "", map[string]any{}, fmt.Errorf("failed build condition %s: %w", field.Name, err)
+		}
+		clauses[bindKey] = str
+		bind[bindKey] = field.Value
+	}
+	cond := []string{}
+	for _, key := range utils.SortMapKeys(bind) {
+		cond = append(cond, clauses[key])
 	}
 	return strings.Join(cond, " AND "), bind, nil
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -21,6 +22,42 @@ type condition struct {
 	Field3 *[]string `db:"f3"`
 }
 
+type normalizedCondition struct {
+	Field1 *string `db:"f1"`
+}
+
+func (c *normalizedCondition) Normalize() {
+	if c.Field1 != nil {
+		upper := strings.ToUpper(*c.Field1)
+		c.Field1 = &upper
+	}
+}
+
+type rangeCondition struct {
+	Min    *int      `db:"f2,gte"`
+	Max    *int      `db:"f2,lt"`
+	Like   *string   `db:"f1,like"`
+	Except *[]string `db:"f3,ne"`
+}
+
+type versionPayload struct {
+	Field1  *string `db:"f1"`
+	Version *int    `db:"version,version"`
+}
+
+type auditPayload struct {
+	Field1    *string    `db:"f1"`
+	CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+	UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
+	CreatedBy *string    `db:"created_by,createdBy"`
+	UpdatedBy *string    `db:"updated_by,updatedBy"`
+}
+
+type exprPayload struct {
+	Field1 *string `db:"f1"`
+	Field2 any     `db:"f2"`
+}
+
 type expected struct {
 	Query string
 	Bind  map[string]any
@@ -29,7 +66,7 @@ type expected struct {
 func TestBuildBulkUpdateQuery(t *testing.T) {
 
 	t.Run("failed", func(t *testing.T) {
-		_, _, err := BuildBulkUpdateQuery("", []Update[int, int]{{1, 1}})
+		_, _, err := BuildBulkUpdateQuery("", []Update[int, int]{{Payload: 1, Condition: 1}})
 		assert.NotNil(t, err)
 	})
 
@@ -159,6 +196,55 @@ func TestBuildUpdateQuery(t *testing.T) {
 	})
 }
 
+func TestBuildUpdateQueryVersion(t *testing.T) {
+	v1 := "v1"
+	c1 := "c1"
+	version := 3
+
+	testCases := []struct {
+		PrefixID string
+		Update   versionPayload
+		Expected expected
+	}{
+		{
+			Update: versionPayload{Field1: &v1},
+			Expected: expected{
+				Query: "UPDATE table SET f1=:val_f1, version=version+1 WHERE f1=:cond_f1",
+				Bind:  map[string]any{"val_f1": v1, "cond_f1": c1},
+			},
+		},
+		{
+			Update: versionPayload{Field1: &v1, Version: &version},
+			Expected: expected{
+				Query: "UPDATE table SET f1=:val_f1, version=version+1 WHERE f1=:cond_f1 AND version=:ver_version",
+				Bind:  map[string]any{"val_f1": v1, "cond_f1": c1, "ver_version": version},
+			},
+		},
+		{
+			PrefixID: "2",
+			Update:   versionPayload{Field1: &v1, Version: &version},
+			Expected: expected{
+				Query: "UPDATE table SET f1=:idx2_val_f1, version=version+1 WHERE f1=:idx2_cond_f1 AND version=:idx2_ver_version",
+				Bind:  map[string]any{"idx2_val_f1": v1, "idx2_cond_f1": c1, "idx2_ver_version": version},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			query, bind, err := BuildUpdateQuery("table", tc.Update, condition{Field1: &c1}, tc.PrefixID)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.Expected.Query, query)
+			assert.Equal(t, tc.Expected.Bind, bind)
+		})
+	}
+
+	t.Run("version only", func(t *testing.T) {
+		_, _, err := BuildUpdateQuery("table", versionPayload{Version: &version}, condition{Field1: &c1}, "")
+		assert.ErrorIs(t, err, ErrEmptyPayload)
+	})
+}
+
 func TestBuildCreateQuery(t *testing.T) {
 
 	t.Run("failed", func(t *testing.T) {
@@ -214,6 +300,50 @@ func TestBuildCreateQuery(t *testing.T) {
 	})
 }
 
+func TestBuildBulkCreateQuery(t *testing.T) {
+
+	t.Run("failed", func(t *testing.T) {
+		_, _, err := BuildBulkCreateQuery("table", []payload{})
+		assert.ErrorIs(t, err, ErrEmptyPayload)
+		_, _, err = BuildBulkCreateQuery("table", []payload{{}})
+		assert.ErrorIs(t, err, ErrEmptyPayload)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		v1 := "v1"
+		v2 := "v2"
+
+		testCases := []struct {
+			Input    []payload
+			Expected expected
+		}{
+			{
+				Input: []payload{{Field1: &v1}},
+				Expected: expected{
+					Query: "INSERT INTO table (f1) VALUES (:idx0_f1)",
+					Bind:  map[string]any{"idx0_f1": v1},
+				},
+			},
+			{
+				Input: []payload{{Field1: &v1}, {Field1: &v2, Field2: &v2}},
+				Expected: expected{
+					Query: "INSERT INTO table (f1, f2) VALUES (:idx0_f1, :idx0_f2), (:idx1_f1, :idx1_f2)",
+					Bind:  map[string]any{"idx0_f1": v1, "idx0_f2": nil, "idx1_f1": v2, "idx1_f2": v2},
+				},
+			},
+		}
+
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				query, bind, err := BuildBulkCreateQuery("table", tc.Input)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.Expected.Query, query)
+				assert.Equal(t, tc.Expected.Bind, bind)
+			})
+		}
+	})
+}
+
 func TestBuildSelectQuery(t *testing.T) {
 	t.Run("failed", func(t *testing.T) {
 		// Empty select
@@ -223,6 +353,10 @@ func TestBuildSelectQuery(t *testing.T) {
 		// Invalid condition
 		_, _, err = BuildSelectQuery("", []string{"*"}, new(map[string]any), nil)
 		assert.NotNil(t, err)
+
+		// Invalid sort
+		_, _, err = BuildSelectQuery("", []string{"*"}, new(condition), &utils.Paginate{Sort: []utils.Sort{{Field: "f1; DROP"}}})
+		assert.NotNil(t, err)
 	})
 
 	t.Run("success", func(t *testing.T) {
@@ -259,6 +393,25 @@ func TestBuildSelectQuery(t *testing.T) {
 					Bind:  map[string]any{"paginate_offset": 30, "paginate_limit": 10},
 				},
 			},
+			{
+				Table:     table,
+				Fields:    []string{"*"},
+				Condition: &condition{Field1: &c1},
+				Paginate:  &utils.Paginate{Page: 1, Limit: 10, Sort: []utils.Sort{{Field: "f2", Desc: true}, {Field: "f1"}}},
+				Expected: expected{
+					Query: "SELECT * FROM table WHERE f1=:cond_f1 ORDER BY f2 DESC, f1 ASC LIMIT :paginate_limit OFFSET :paginate_offset",
+					Bind:  map[string]any{"cond_f1": c1, "paginate_offset": 0, "paginate_limit": 10},
+				},
+			},
+			{
+				Table:    table,
+				Fields:   []string{"*"},
+				Paginate: &utils.Paginate{Sort: []utils.Sort{{Field: "f1"}}},
+				Expected: expected{
+					Query: "SELECT * FROM table ORDER BY f1 ASC",
+					Bind:  map[string]any{},
+				},
+			},
 			{
 				Table:  table,
 				Fields: []string{"username", "email"},
@@ -398,11 +551,244 @@ func TestBuildDeleteQuery(t *testing.T) {
 	})
 }
 
+func TestBuildSoftDeleteQuery(t *testing.T) {
+	v1 := "v1"
+	now := "2024-01-02 03:04:05"
+
+	t.Run("failed", func(t *testing.T) {
+		_, _, err := BuildSoftDeleteQuery("table", "deleted_at", now, condition{})
+		assert.ErrorIs(t, err, ErrEmptyCondition)
+		_, _, err = BuildSoftDeleteQuery("table", "deleted at", now, condition{Field1: &v1})
+		assert.ErrorIs(t, err, ErrInvalidField)
+	})
+
+	t.Run("scalar and explicit in on one column", func(t *testing.T) {
+		id, ids := 1, []int{1, 2}
+		query, bind, err := BuildCondition(struct {
+			ID  *int   `db:"id"`
+			IDs *[]int `db:"id,in"`
+		}{ID: &id, IDs: &ids}, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "id=:cond_id AND id IN (:cond_id_in)", query)
+		assert.Equal(t, map[string]any{"cond_id": id, "cond_id_in": ids}, bind)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		query, bind, err := BuildSoftDeleteQuery("table", "deleted_at", now, condition{Field1: &v1}, Where("deleted_at IS NULL"))
+		assert.Nil(t, err)
+		assert.Equal(t, "UPDATE table SET deleted_at=:val_deleted_at WHERE (f1=:cond_f1) AND (deleted_at IS NULL)", query)
+		assert.Equal(t, map[string]any{"cond_f1": v1, "val_deleted_at": now}, bind)
+
+		query, bind, err = BuildSoftDeleteQuery("table", "deleted_at", nil, condition{Field1: &v1})
+		assert.Nil(t, err)
+		assert.Equal(t, "UPDATE table SET deleted_at=:val_deleted_at WHERE f1=:cond_f1", query)
+		assert.Equal(t, map[string]any{"cond_f1": v1, "val_deleted_at": nil}, bind)
+	})
+}
+
+func TestQueryOptions(t *testing.T) {
+	v1 := "v1"
+	scope := Where("deleted_at IS NULL")
+
+	query, _, err := BuildSelectQuery[condition]("table", []string{"f1"}, nil, nil, scope)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT f1 FROM table WHERE deleted_at IS NULL", query)
+
+	query, _, err = BuildSelectQuery("table", []string{"f1"}, &condition{Field1: &v1}, &utils.Paginate{Page: 1, Limit: 5}, scope)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT f1 FROM table WHERE (f1=:cond_f1) AND (deleted_at IS NULL) LIMIT :paginate_limit OFFSET :paginate_offset", query)
+
+	query, _, err = BuildCountQuery("table", &condition{Field1: &v1}, scope, Where("f2 > 0"))
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT COUNT(*) FROM table WHERE (f1=:cond_f1) AND (deleted_at IS NULL) AND (f2 > 0)", query)
+
+	query, _, err = BuildUpdateQuery("table", payload{Field2: &v1}, condition{Field1: &v1}, "", scope)
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE table SET f2=:val_f2 WHERE (f1=:cond_f1) AND (deleted_at IS NULL)", query)
+
+	query, _, err = BuildDeleteQuery("table", condition{Field1: &v1}, scope)
+	assert.Nil(t, err)
+	assert.Equal(t, "DELETE FROM table WHERE (f1=:cond_f1) AND (deleted_at IS NULL)", query)
+
+	_, _, err = BuildDeleteQuery("table", condition{}, scope)
+	assert.ErrorIs(t, err, ErrEmptyCondition)
+
+	query, bind, err := BuildDeleteQuery("table", condition{Field1: &v1}, keyed("id", []any{1}), scope)
+	assert.Nil(t, err)
+	assert.Equal(t, "DELETE FROM table WHERE (f1=:cond_f1) AND (id IN (:keys)) AND (deleted_at IS NULL)", query)
+	assert.Equal(t, map[string]any{"cond_f1": v1, "keys": []any{1}}, bind)
+
+	query, _, err = BuildSoftDeleteQuery("table", "deleted_at", nil, condition{Field1: &v1}, keyed("id", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE table SET deleted_at=:val_deleted_at WHERE (f1=:cond_f1) AND (1 = 0)", query)
+}
+
+func TestBuildQueryStamp(t *testing.T) {
+	v1 := "v1"
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	stamp := Stamp(now, "alice")
+
+	t.Run("create", func(t *testing.T) {
+		query, bind, err := BuildCreateQuery("table", auditPayload{Field1: &v1}, stamp)
+		assert.Nil(t, err)
+		assert.Equal(t, "INSERT INTO table (created_at, created_by, f1, updated_at, updated_by) VALUES (:created_at, :created_by, :f1, :updated_at, :updated_by)", query)
+		assert.Equal(t, map[string]any{"f1": v1, "created_at": now, "updated_at": now, "created_by": "alice", "updated_by": "alice"}, bind)
+	})
+
+	t.Run("create keeps given values", func(t *testing.T) {
+		_, bind, err := BuildCreateQuery("table", auditPayload{Field1: &v1, CreatedAt: &earlier}, Stamp(now, nil))
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]any{"f1": v1, "created_at": earlier, "updated_at": now}, bind)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		query, bind, err := BuildUpdateQuery("table", auditPayload{Field1: &v1}, condition{Field1: &v1}, "", stamp)
+		assert.Nil(t, err)
+		assert.Equal(t, "UPDATE table SET f1=:val_f1, updated_at=:val_updated_at, updated_by=:val_updated_by WHERE f1=:cond_f1", query)
+		assert.Equal(t, map[string]any{"val_f1": v1, "val_updated_at": now, "val_updated_by": "alice", "cond_f1": v1}, bind)
+
+		_, _, err = BuildUpdateQuery("table", auditPayload{}, condition{Field1: &v1}, "", stamp)
+		assert.ErrorIs(t, err, ErrEmptyPayload)
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		query, bind, err := BuildBulkCreateQuery("table", []auditPayload{{Field1: &v1}, {Field1: &v1}}, Stamp(now, nil))
+		assert.Nil(t, err)
+		assert.Equal(t, "INSERT INTO table (created_at, f1, updated_at) VALUES (:idx0_created_at, :idx0_f1, :idx0_updated_at), (:idx1_created_at, :idx1_f1, :idx1_updated_at)", query)
+		assert.Equal(t, now, bind["idx1_created_at"])
+
+		query, _, err = BuildBulkUpdateQuery("table", []Update[auditPayload, condition]{{Payload: auditPayload{Field1: &v1}, Condition: condition{Field1: &v1}}}, stamp)
+		assert.Nil(t, err)
+		assert.Contains(t, query, "UPDATE table SET f1=:idx0_val_f1, updated_at=:idx0_val_updated_at, updated_by=:idx0_val_updated_by WHERE f1=:idx0_cond_f1;")
+	})
+
+	t.Run("default clock", func(t *testing.T) {
+		_, bind, err := BuildCreateQuery("table", auditPayload{Field1: &v1})
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now(), bind["created_at"].(time.Time), time.Minute)
+		assert.NotContains(t, bind, "created_by")
+	})
+}
+
+func TestBuildUpdateQueryExpression(t *testing.T) {
+	v1 := "v1"
+	c1 := "c1"
+
+	testCases := []struct {
+		PrefixID string
+		Update   exprPayload
+		Options  []QueryOption
+		Expected expected
+	}{
+		{
+			Update: exprPayload{Field2: Decr(3)},
+			Expected: expected{
+				Query: "UPDATE table SET f2=f2 - :val_f2_1 WHERE f1=:cond_f1",
+				Bind:  map[string]any{"val_f2_1": 3, "cond_f1": c1},
+			},
+		},
+		{
+			Update:  exprPayload{Field1: &v1},
+			Options: []QueryOption{Set("f3", Expr("GREATEST(f3 - ?, 0)", 3)), Guard("f3 >= ?", 3)},
+			Expected: expected{
+				Query: "UPDATE table SET f1=:val_f1, f3=GREATEST(f3 - :val_f3_1, 0) WHERE (f1=:cond_f1) AND (f3 >= :guard1_1)",
+				Bind:  map[string]any{"val_f1": v1, "val_f3_1": 3, "guard1_1": 3, "cond_f1": c1},
+			},
+		},
+		{
+			PrefixID: "1",
+			Update:   exprPayload{Field2: Incr(2)},
+			Options:  []QueryOption{Set("f1", "v"), Guard("f2 + ? <= ?", 2, 10), Where("deleted_at IS NULL")},
+			Expected: expected{
+				Query: "UPDATE table SET f1=:idx1_val_f1, f2=f2 + :idx1_val_f2_1 WHERE (f1=:idx1_cond_f1) AND (f2 + :idx1_guard1_1 <= :idx1_guard1_2) AND (deleted_at IS NULL)",
+				Bind:  map[string]any{"idx1_val_f1": "v", "idx1_val_f2_1": 2, "idx1_guard1_1": 2, "idx1_guard1_2": 10, "idx1_cond_f1": c1},
+			},
+		},
+		{
+			Update:  exprPayload{Field1: &v1},
+			Options: []QueryOption{Guard("f2 = ? OR f3 > ?", 1, 2), Where("deleted_at IS NULL")},
+			Expected: expected{
+				Query: "UPDATE table SET f1=:val_f1 WHERE (f1=:cond_f1) AND (f2 = :guard1_1 OR f3 > :guard1_2) AND (deleted_at IS NULL)",
+				Bind:  map[string]any{"val_f1": v1, "guard1_1": 1, "guard1_2": 2, "cond_f1": c1},
+			},
+		},
+		{
+			PrefixID: "2",
+			Update:   exprPayload{Field1: &v1},
+			Options:  []QueryOption{keyed("id", []any{1, 2})},
+			Expected: expected{
+				Query: "UPDATE table SET f1=:idx2_val_f1 WHERE (f1=:idx2_cond_f1) AND (id IN (:idx2_keys))",
+				Bind:  map[string]any{"idx2_val_f1": v1, "idx2_keys": []any{1, 2}, "idx2_cond_f1": c1},
+			},
+		},
+		{
+			Update:  exprPayload{Field1: &v1},
+			Options: []QueryOption{keyed("id", []any{})},
+			Expected: expected{
+				Query: "UPDATE table SET f1=:val_f1 WHERE (f1=:cond_f1) AND (1 = 0)",
+				Bind:  map[string]any{"val_f1": v1, "cond_f1": c1},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			query, bind, err := BuildUpdateQuery("table", tc.Update, condition{Field1: &c1}, tc.PrefixID, tc.Options...)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.Expected.Query, query)
+			assert.Equal(t, tc.Expected.Bind, bind)
+		})
+	}
+
+	t.Run("failed", func(t *testing.T) {
+		_, _, err := BuildUpdateQuery("table", exprPayload{Field2: Expr("f2 - ?")}, condition{Field1: &c1}, "")
+		assert.ErrorIs(t, err, ErrInvalidField)
+		_, _, err = BuildUpdateQuery("table", exprPayload{}, condition{Field1: &c1}, "", Set("f2; DROP", 1))
+		assert.ErrorIs(t, err, ErrInvalidField)
+		_, _, err = BuildUpdateQuery("table", exprPayload{Field1: &v1}, condition{Field1: &c1}, "", Guard("f2 > ?"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		query, bind, err := BuildBulkUpdateQuery("table", []Update[exprPayload, condition]{
+			{Payload: exprPayload{Field2: Decr(1)}, Condition: condition{Field1: &c1}, Options: []QueryOption{Guard("f2 >= ?", 1)}},
+			{Payload: exprPayload{Field2: Decr(2)}, Condition: condition{Field1: &c1}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "START TRANSACTION;\nUPDATE table SET f2=f2 - :idx0_val_f2_1 WHERE (f1=:idx0_cond_f1) AND (f2 >= :idx0_guard1_1);\nUPDATE table SET f2=f2 - :idx1_val_f2_1 WHERE f1=:idx1_cond_f1;\nCOMMIT;", query)
+		assert.Equal(t, 2, bind["idx1_val_f2_1"])
+	})
+}
+
 func TestBuildCondition(t *testing.T) {
 
 	t.Run("failed", func(t *testing.T) {
 		_, _, err := BuildCondition(1, "")
 		assert.NotNil(t, err)
+
+		id, ids := 1, []int{1, 2}
+		_, _, err = BuildCondition(struct {
+			ID  *int   `db:"id"`
+			IDs *[]int `db:"id"`
+		}{ID: &id, IDs: &ids}, "")
+		assert.ErrorIs(t, err, ErrInvalidField)
+		_, _, err = BuildDeleteQuery("table", struct {
+			ID  *int   `db:"id"`
+			IDs *[]int `db:"id"`
+		}{ID: &id, IDs: &ids})
+		assert.ErrorIs(t, err, ErrInvalidField)
+	})
+
+	t.Run("scalar and explicit in on one column", func(t *testing.T) {
+		id, ids := 1, []int{1, 2}
+		query, bind, err := BuildCondition(struct {
+			ID  *int   `db:"id"`
+			IDs *[]int `db:"id,in"`
+		}{ID: &id, IDs: &ids}, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "id=:cond_id AND id IN (:cond_id_in)", query)
+		assert.Equal(t, map[string]any{"cond_id": id, "cond_id_in": ids}, bind)
 	})
 
 	t.Run("success", func(t *testing.T) {
@@ -451,6 +837,53 @@ func TestBuildCondition(t *testing.T) {
 		}
 
 	})
+
+	t.Run("operator", func(t *testing.T) {
+		min := 1
+		max := 10
+		like := "%v%"
+		except := []string{"a", "b"}
+
+		testCases := []struct {
+			Condition rangeCondition
+			PrefixID  string
+			Expected  expected
+		}{
+			{
+				Condition: rangeCondition{Min: &min, Max: &max, Like: &like, Except: &except},
+				Expected: expected{
+					Query: "f1 LIKE :cond_f1_like AND f2>=:cond_f2_gte AND f2<:cond_f2_lt AND f3 NOT IN (:cond_f3_nin)",
+					Bind:  map[string]any{"cond_f1_like": like, "cond_f2_gte": min, "cond_f2_lt": max, "cond_f3_nin": except},
+				},
+			},
+			{
+				Condition: rangeCondition{Min: &min},
+				PrefixID:  "2",
+				Expected: expected{
+					Query: "f2>=:idx2_cond_f2_gte",
+					Bind:  map[string]any{"idx2_cond_f2_gte": min},
+				},
+			},
+		}
+
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				query, bind, err := BuildCondition(tc.Condition, tc.PrefixID)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.Expected.Query, query)
+				assert.Equal(t, tc.Expected.Bind, bind)
+			})
+		}
+	})
+
+	t.Run("normalize hook", func(t *testing.T) {
+		v1 := "a"
+		query, bind, err := BuildCondition(normalizedCondition{Field1: &v1}, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "f1=:cond_f1", query)
+		assert.Equal(t, map[string]any{"cond_f1": "A"}, bind)
+		assert.Equal(t, "a", v1)
+	})
 }
 
 func TestBindNamedQuery(t *testing.T) {
@@ -0,0 +1,602 @@
+package sql
+
+import (
+	"bulk/utils"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type RepositoryOption func(*repositoryOptions)
+
+type repositoryOptions struct {
+	retry   RetryPolicy
+	clock   func() time.Time
+	auditor Auditor
+	outbox  EventRecorder
+	router  *Router
+}
+
+// WithRetryPolicy sets how writes retry deadlocks and other transient errors.
+// A connection lost during the commit is not retried, writes fail with
+// ErrCommitUnknown.
+func WithRetryPolicy(policy RetryPolicy) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.retry = policy
+	}
+}
+
+// WithClock sets the time source of the autoCreateTime, autoUpdateTime and
+// soft delete columns.
+func WithClock(clock func() time.Time) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.clock = clock
+	}
+}
+
+// Repository implements the common data access of one table. Model is the
+// scanned row, Payload the written columns and Condition the filter; all are
+// structs tagged with db. The primary key is the model column tagged
+// db:"name,pk", or id when no column is tagged. A model column tagged
+// db:"name,softdelete" switches Delete to marking rows, see WithDeleted and
+// OnlyDeleted to read them back.
+type Repository[Model any, Payload any, Condition any] struct {
+	db         *sqlx.DB
+	table      string
+	columns    []string
+	pk         string
+	softDelete string
+	repositoryOptions
+}
+
+func NewRepository[Model any, Payload any, Condition any](db *sqlx.DB, table string, opts ...RepositoryOption) *Repository[Model, Payload, Condition] {
+	r := &Repository[Model, Payload, Condition]{
+		db:                db,
+		table:             table,
+		pk:                "id",
+		repositoryOptions: repositoryOptions{retry: DefaultRetryPolicy, clock: time.Now},
+	}
+	for _, opt := range opts {
+		opt(&r.repositoryOptions)
+	}
+	rt := reflect.TypeOf((*Model)(nil)).Elem()
+	for i := 0; i < rt.NumField(); i++ {
+		name, options := utils.ParseTag(rt.Field(i).Tag.Get(Tag))
+		if name == "" || name == "-" {
+			continue
+		}
+		r.columns = append(r.columns, name)
+		if _, ok := options["pk"]; ok {
+			r.pk = name
+		}
+		if _, ok := options["softdelete"]; ok {
+			r.softDelete = name
+		}
+	}
+	return r
+}
+
+func (r *Repository[Model, Payload, Condition]) DB() *sqlx.DB {
+	return r.db
+}
+
+// Columns lists every db column of Model in declaration order.
+func (r *Repository[Model, Payload, Condition]) Columns() []string {
+	return append([]string{}, r.columns...)
+}
+
+func (r *Repository[Model, Payload, Condition]) Table() string {
+	return r.table
+}
+
+func (r *Repository[Model, Payload, Condition]) Select(ctx context.Context, fields []string, condition *Condition, paginate *utils.Paginate) (result utils.Result[Model], err error) {
+	empty := utils.Result[Model]{Data: []Model{}}
+
+	// Result data
+	query, param, err := BuildSelectQuery(r.table, fields, condition, paginate, r.scope(ctx)...)
+	if err != nil {
+		return empty, fmt.Errorf("failed build query: %w", err)
+	}
+	query, args, err := BindNamedQuery(query, param)
+	if err != nil {
+		return empty, fmt.Errorf("failed bind named query: %w", err)
+	}
+	db := r.reader(ctx)
+	data := []Model{}
+	if err := sqlx.SelectContext(ctx, db, &data, db.Rebind(query), args...); err != nil {
+		return empty, fmt.Errorf("failed select db: %w", TranslateError(err))
+	}
+	for idx := range data {
+		if err := afterFind(ctx, db, &data[idx]); err != nil {
+			return empty, err
+		}
+	}
+
+	// Total
+	query, param, err = BuildCountQuery(r.table, condition, r.scope(ctx)...)
+	if err != nil {
+		return empty, fmt.Errorf("failed build count query: %w", err)
+	}
+	query, args, err = BindNamedQuery(query, param)
+	if err != nil {
+		return empty, fmt.Errorf("failed bind count named query: %w", err)
+	}
+	total := 0
+	if err := sqlx.GetContext(ctx, db, &total, db.Rebind(query), args...); err != nil {
+		return empty, fmt.Errorf("failed count data: %w", TranslateError(err))
+	}
+
+	return utils.Pagination(data, total, paginate), nil
+}
+
+func (r *Repository[Model, Payload, Condition]) Stream(ctx context.Context, fields []string, condition *Condition, paginate *utils.Paginate, fn func(Model) error) error {
+	query, param, err := BuildSelectQuery(r.table, fields, condition, paginate, r.scope(ctx)...)
+	if err != nil {
+		return fmt.Errorf("failed build query: %w", err)
+	}
+	query, args, err := BindNamedQuery(query, param)
+	if err != nil {
+		return fmt.Errorf("failed bind named query: %w", err)
+	}
+	db := r.reader(ctx)
+	rows, err := db.QueryxContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("failed select db: %w", TranslateError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item Model
+		if err := rows.StructScan(&item); err != nil {
+			return fmt.Errorf("failed scan row: %w", err)
+		}
+		if err := afterFind(ctx, db, &item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed read rows: %w", err)
+	}
+	return nil
+}
+
+// GetByID fetches the row whose primary key equals id.
+func (r *Repository[Model, Payload, Condition]) GetByID(ctx context.Context, id any) (Model, error) {
+	where := fmt.Sprintf("%s=:cond_%s", r.pk, r.pk)
+	return r.findOne(ctx, where, map[string]any{fmt.Sprintf("cond_%s", r.pk): id})
+}
+
+// FindOne fetches the single row matching condition. It fails with
+// ErrNotFound when nothing matches and ErrNotUnique when several rows do.
+func (r *Repository[Model, Payload, Condition]) FindOne(ctx context.Context, condition Condition) (Model, error) {
+	var empty Model
+	where, bind, err := BuildCondition(condition, "")
+	if err != nil {
+		return empty, fmt.Errorf("failed build condition: %w", err)
+	}
+	if where == "" {
+		return empty, fmt.Errorf("find one: %w", ErrEmptyCondition)
+	}
+	return r.findOne(ctx, where, bind)
+}
+
+func (r *Repository[Model, Payload, Condition]) findOne(ctx context.Context, where string, bind map[string]any) (Model, error) {
+	var empty Model
+	where = newQueryOptions(r.scope(ctx)).and(where)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 2", strings.Join(r.columns, ", "), r.table, where)
+	query, args, err := BindNamedQuery(query, bind)
+	if err != nil {
+		return empty, fmt.Errorf("failed bind named query: %w", err)
+	}
+	db := r.reader(ctx)
+	data := []Model{}
+	if err := sqlx.SelectContext(ctx, db, &data, db.Rebind(query), args...); err != nil {
+		return empty, fmt.Errorf("failed select db: %w", TranslateError(err))
+	}
+	switch len(data) {
+	case 0:
+		return empty, &NotFoundError{Table: r.table}
+	case 1:
+		if err := afterFind(ctx, db, &data[0]); err != nil {
+			return empty, err
+		}
+		return data[0], nil
+	}
+	return empty, fmt.Errorf("%s: %w", r.table, ErrNotUnique)
+}
+
+// Exists reports whether any row matches condition without counting them.
+func (r *Repository[Model, Payload, Condition]) Exists(ctx context.Context, condition Condition) (bool, error) {
+	where, bind, err := BuildCondition(condition, "")
+	if err != nil {
+		return false, fmt.Errorf("failed build condition: %w", err)
+	}
+	query := fmt.Sprintf("SELECT 1 FROM %s", r.table)
+	if where = newQueryOptions(r.scope(ctx)).and(where); where != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, where)
+	}
+	query, args, err := BindNamedQuery(fmt.Sprintf("%s LIMIT 1", query), bind)
+	if err != nil {
+		return false, fmt.Errorf("failed bind named query: %w", err)
+	}
+	db := r.reader(ctx)
+	found := []int{}
+	if err := sqlx.SelectContext(ctx, db, &found, db.Rebind(query), args...); err != nil {
+		return false, fmt.Errorf("failed select db: %w", TranslateError(err))
+	}
+	return len(found) > 0, nil
+}
+
+// Create inserts payload and returns the stored row, read back by the
+// generated or given primary key.
+func (r *Repository[Model, Payload, Condition]) Create(ctx context.Context, payload Payload) (Model, error) {
+	var created Model
+	err := r.write(ctx, func(ctx context.Context, db Executor) error {
+		row := payload
+		if err := beforeCreate(ctx, db, &row); err != nil {
+			return err
+		}
+		if err := utils.Validate(row, Tag); err != nil {
+			return err
+		}
+		query, param, err := BuildCreateQuery(r.table, row, r.stamp(ctx))
+		if err != nil {
+			return fmt.Errorf("failed build query: %w", err)
+		}
+		ids, err := r.insert(ctx, db, query, param, []Payload{row})
+		if err != nil {
+			return err
+		}
+		if err := afterCreate(ctx, db, &row, ids[0]); err != nil {
+			return err
+		}
+		if err := r.emit(ctx, db, EventCreated, []any{ids[0]}); err != nil {
+			return err
+		}
+		created, err = r.GetByID(ctx, ids[0])
+		if err != nil {
+			return fmt.Errorf("failed read created row: %w", err)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// CreateBulk inserts all payloads in one statement and returns their primary
+// keys in input order. Hooks run per row, a failing one is reported as
+// RowError.
+func (r *Repository[Model, Payload, Condition]) CreateBulk(ctx context.Context, payload []Payload) (ids []int64, err error) {
+	if len(payload) <= 0 {
+		return []int64{}, fmt.Errorf("payload is required: %w", ErrEmptyPayload)
+	}
+	err = r.write(ctx, func(ctx context.Context, db Executor) error {
+		rows := append([]Payload{}, payload...)
+		for idx := range rows {
+			if err := beforeCreate(ctx, db, &rows[idx]); err != nil {
+				return &RowError{Index: idx, Err: err}
+			}
+			if err := utils.Validate(rows[idx], Tag); err != nil {
+				return &RowError{Index: idx, Err: err}
+			}
+		}
+		query, param, err := BuildBulkCreateQuery(r.table, rows, r.stamp(ctx))
+		if err != nil {
+			return fmt.Errorf("failed build query: %w", err)
+		}
+		ids, err = r.insert(ctx, db, query, param, rows)
+		if err != nil {
+			return err
+		}
+		for idx := range rows {
+			if err := afterCreate(ctx, db, &rows[idx], ids[idx]); err != nil {
+				return &RowError{Index: idx, Err: err}
+			}
+		}
+		keys := make([]any, 0, len(ids))
+		for _, id := range ids {
+			keys = append(keys, id)
+		}
+		return r.emit(ctx, db, EventCreated, keys)
+	})
+	if err != nil {
+		return []int64{}, err
+	}
+	return ids, nil
+}
+
+// insert runs an INSERT of payloads and resolves their primary keys: from the
+// payloads when they carry the key, through RETURNING where the dialect has
+// it, otherwise from LastInsertId. MySQL reports the first id of a multi-row
+// insert, the rest follow it, which holds with auto_increment_increment=1 and
+// an innodb_autoinc_lock_mode that keeps simple inserts consecutive.
+func (r *Repository[Model, Payload, Condition]) insert(ctx context.Context, db Executor, query string, param map[string]any, payload []Payload) ([]int64, error) {
+	given, err := r.givenIDs(payload)
+	if err != nil {
+		return nil, err
+	}
+	returning := given == nil && DialectOf(db.DriverName()).SupportsReturning()
+	if returning {
+		query = fmt.Sprintf("%s RETURNING %s", query, r.pk)
+	}
+	query, args, err := BindNamedQuery(query, param)
+	if err != nil {
+		return nil, fmt.Errorf("failed bind named query: %w", err)
+	}
+
+	if returning {
+		ids := []int64{}
+		if err := sqlx.SelectContext(ctx, db, &ids, db.Rebind(query), args...); err != nil {
+			return nil, fmt.Errorf("failed insert db: %w", TranslateError(err))
+		}
+		if len(ids) != len(payload) {
+			return nil, fmt.Errorf("failed insert db: returned %d ids for %d rows", len(ids), len(payload))
+		}
+		// Keys are generated in VALUES order, RETURNING order is not guaranteed
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return ids, nil
+	}
+
+	result, err := db.ExecContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed insert db: %w", TranslateError(err))
+	}
+	if given != nil {
+		return given, nil
+	}
+	first, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed get last insert id: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed get rows affected: %w", err)
+	}
+	if affected != int64(len(payload)) {
+		return nil, fmt.Errorf("failed insert db: affected %d rows for %d payloads", affected, len(payload))
+	}
+	ids := make([]int64, 0, len(payload))
+	for i := range payload {
+		ids = append(ids, first+int64(i))
+	}
+	return ids, nil
+}
+
+// givenIDs returns the primary keys set on every payload, or nil when the
+// database has to generate them.
+func (r *Repository[Model, Payload, Condition]) givenIDs(payload []Payload) ([]int64, error) {
+	ids := []int64{}
+	for _, item := range payload {
+		fieldMap, err := utils.StructToMap(item, Tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed make field map: %w", err)
+		}
+		val, ok := fieldMap[r.pk]
+		if !ok {
+			continue
+		}
+		rv := reflect.ValueOf(val)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			ids = append(ids, rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			ids = append(ids, int64(rv.Uint()))
+		default:
+			return nil, &InvalidFieldError{Field: r.pk, Reason: "primary key is not an integer"}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) != len(payload) {
+		return nil, &InvalidFieldError{Field: r.pk, Reason: "primary key set on some payloads only"}
+	}
+	return ids, nil
+}
+
+// UpdateBulk applies every update in one transaction. Any failure rolls the
+// whole batch back, so all inputs are returned as fails; retryable failures
+// re-run the transaction from the start.
+func (r *Repository[Model, Payload, Condition]) UpdateBulk(ctx context.Context, payload []Update[Payload, Condition]) (fails []Update[Payload, Condition], err error) {
+	err = r.write(ctx, func(ctx context.Context, db Executor) error {
+		for idx, v := range payload {
+			if err := r.update(ctx, db, v.Payload, v.Condition, v.Options...); err != nil {
+				return &RowError{Index: idx, Err: err}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return payload, err
+	}
+	return nil, nil
+}
+
+// Update writes payload to the rows matching condition. When Payload has a
+// version column set, only rows still at that version match and a miss is
+// reported as ConflictError. opts add Set expressions and Guard clauses; a
+// guarded update matching no row fails with ErrGuardFailed.
+func (r *Repository[Model, Payload, Condition]) Update(ctx context.Context, payload Payload, condition Condition, opts ...QueryOption) error {
+	return r.write(ctx, func(ctx context.Context, db Executor) error {
+		return r.update(ctx, db, payload, condition, opts...)
+	})
+}
+
+func (r *Repository[Model, Payload, Condition]) update(ctx context.Context, db Executor, payload Payload, condition Condition, opts ...QueryOption) error {
+	if err := beforeUpdate(ctx, db, &payload); err != nil {
+		return err
+	}
+	if err := utils.ValidatePartial(payload, Tag); err != nil {
+		return err
+	}
+	where, bind, err := r.updateWhere(ctx, payload, condition, opts)
+	if err != nil {
+		return err
+	}
+	err = r.audited(ctx, db, payload, where, bind, opts, func(keys ...QueryOption) error {
+		return r.published(ctx, db, EventUpdated, where, bind, func(published ...QueryOption) error {
+			return r.updateExec(ctx, db, payload, condition, append(append(append([]QueryOption{}, opts...), keys...), published...))
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return afterUpdate(ctx, db, &payload)
+}
+
+// updateWhere renders the clause matching the rows an update with opts
+// writes in ctx.
+func (r *Repository[Model, Payload, Condition]) updateWhere(ctx context.Context, payload Payload, condition Condition, opts []QueryOption) (string, map[string]any, error) {
+	o := newQueryOptions(append(append([]QueryOption{}, opts...), r.scope(ctx)...))
+	where, bind, err := buildUpdateWhere(payload, condition, "", o)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed build condition: %w", err)
+	}
+	return where, bind, nil
+}
+
+func (r *Repository[Model, Payload, Condition]) updateExec(ctx context.Context, db Executor, payload Payload, condition Condition, opts []QueryOption) error {
+	opts = append(append(append([]QueryOption{}, opts...), r.scope(ctx)...), r.stamp(ctx))
+	query, param, err := BuildUpdateQuery(r.table, payload, condition, "", opts...)
+	if err != nil {
+		return fmt.Errorf("failed build query: %w", err)
+	}
+	query, args, err := BindNamedQuery(query, param)
+	if err != nil {
+		return fmt.Errorf("failed bind named query: %w", err)
+	}
+	result, err := db.ExecContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("failed update db: %w", TranslateError(err))
+	}
+	version, _ := VersionColumn(payload)
+	expected, versioned := param[fmt.Sprintf("ver_%s", version)]
+	guarded := len(newQueryOptions(opts).guards) > 0
+	if !versioned && !guarded {
+		return nil
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed get rows affected: %w", err)
+	}
+	if affected == 0 && versioned {
+		return &ConflictError{Table: r.table, Version: expected}
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", r.table, ErrGuardFailed)
+	}
+	return nil
+}
+
+// Delete removes the rows matching condition. With a soft delete column the
+// rows are marked deleted instead and stay in the table.
+func (r *Repository[Model, Payload, Condition]) Delete(ctx context.Context, condition Condition) error {
+	if r.softDelete == "" {
+		return r.HardDelete(ctx, condition)
+	}
+	return r.write(ctx, func(ctx context.Context, db Executor) error {
+		if err := beforeDelete(ctx, db, &condition); err != nil {
+			return err
+		}
+		scope := DeletedScopeOptions(r.softDelete, ScopeLive)
+		deletedAt := r.clock()
+		return r.publishedExec(ctx, db, "delete", EventDeleted, condition, scope, func(opts ...QueryOption) (string, map[string]any, error) {
+			return BuildSoftDeleteQuery(r.table, r.softDelete, deletedAt, condition, append(opts, scope...)...)
+		})
+	})
+}
+
+// Restore clears the soft delete mark of the deleted rows matching condition.
+func (r *Repository[Model, Payload, Condition]) Restore(ctx context.Context, condition Condition) error {
+	if r.softDelete == "" {
+		return fmt.Errorf("restore %s: no soft delete column: %w", r.table, ErrInvalidField)
+	}
+	scope := DeletedScopeOptions(r.softDelete, ScopeOnlyDeleted)
+	return r.write(ctx, func(ctx context.Context, db Executor) error {
+		return r.publishedExec(ctx, db, "restore", EventRestored, condition, scope, func(opts ...QueryOption) (string, map[string]any, error) {
+			return BuildSoftDeleteQuery(r.table, r.softDelete, nil, condition, append(opts, scope...)...)
+		})
+	})
+}
+
+// HardDelete removes the rows matching condition from the table, deleted or
+// not, regardless of soft delete.
+func (r *Repository[Model, Payload, Condition]) HardDelete(ctx context.Context, condition Condition) error {
+	return r.write(ctx, func(ctx context.Context, db Executor) error {
+		if err := beforeDelete(ctx, db, &condition); err != nil {
+			return err
+		}
+		return r.publishedExec(ctx, db, "delete", EventDeleted, condition, nil, func(opts ...QueryOption) (string, map[string]any, error) {
+			return BuildDeleteQuery(r.table, condition, opts...)
+		})
+	})
+}
+
+// publishedExec runs the query of build, recording an event for the rows
+// condition and opts match. build gets the options restricting the query to
+// the rows the events are recorded for.
+func (r *Repository[Model, Payload, Condition]) publishedExec(ctx context.Context, db Executor, action string, event string, condition Condition, opts []QueryOption, build func(opts ...QueryOption) (string, map[string]any, error)) error {
+	if _, _, err := build(); err != nil {
+		return fmt.Errorf("failed build query: %w", err)
+	}
+	where, bind, err := BuildCondition(condition, "")
+	if err != nil {
+		return fmt.Errorf("failed build condition: %w", err)
+	}
+	return r.published(ctx, db, event, newQueryOptions(opts).and(where), bind, func(keys ...QueryOption) error {
+		query, param, err := build(keys...)
+		if err != nil {
+			return fmt.Errorf("failed build query: %w", err)
+		}
+		return r.exec(ctx, db, action, query, param)
+	})
+}
+
+func (r *Repository[Model, Payload, Condition]) exec(ctx context.Context, db Executor, action string, query string, param map[string]any) error {
+	query, args, err := BindNamedQuery(query, param)
+	if err != nil {
+		return fmt.Errorf("failed bind named query: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, db.Rebind(query), args...); err != nil {
+		return fmt.Errorf("failed %s db: %w", action, TranslateError(err))
+	}
+	return nil
+}
+
+// stamp returns the audit values of writes made with ctx.
+func (r *Repository[Model, Payload, Condition]) stamp(ctx context.Context) QueryOption {
+	actor, _ := ActorFrom(ctx)
+	return Stamp(r.clock(), actor)
+}
+
+// scope returns the soft delete restriction of ctx, none when the model has
+// no soft delete column.
+func (r *Repository[Model, Payload, Condition]) scope(ctx context.Context) []QueryOption {
+	if r.softDelete == "" {
+		return nil
+	}
+	return DeletedScopeOptions(r.softDelete, DeletedScopeFrom(ctx))
+}
+
+// reader returns the executor of a read made with ctx.
+func (r *Repository[Model, Payload, Condition]) reader(ctx context.Context) Executor {
+	if r.router == nil {
+		return ExecutorFrom(ctx, r.db)
+	}
+	return r.router.Reader(ctx)
+}
+
+// write runs fn in a retried transaction, or in the caller's transaction
+// when ctx already carries one.
+func (r *Repository[Model, Payload, Condition]) write(ctx context.Context, fn func(ctx context.Context, db Executor) error) error {
+	markWritten(ctx)
+	return RetryTransaction(ctx, r.db, r.retry, func(ctx context.Context) error {
+		return fn(ctx, ExecutorFrom(ctx, r.db))
+	})
+}
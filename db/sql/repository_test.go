@@ -0,0 +1,519 @@
+package sql
+
+import (
+	"bulk/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+const itemSchema = "CREATE TABLE items (item_id INTEGER PRIMARY KEY AUTOINCREMENT, sku TEXT NOT NULL UNIQUE, name TEXT, qty INTEGER NOT NULL DEFAULT 0)"
+
+type itemModel struct {
+	ID   *int    `db:"item_id,pk"`
+	SKU  *string `db:"sku"`
+	Name *string `db:"name"`
+	Qty  *int    `db:"qty"`
+}
+
+type itemPayload struct {
+	SKU  *string `db:"sku"`
+	Name *string `db:"name"`
+	Qty  *int    `db:"qty"`
+}
+
+type itemCondition struct {
+	ID     *int      `db:"item_id"`
+	SKU    *string   `db:"sku"`
+	SKUs   *[]string `db:"sku"`
+	QtyGte *int      `db:"qty,gte"`
+}
+
+func newItemRepo(t *testing.T, opts ...RepositoryOption) *Repository[itemModel, itemPayload, itemCondition] {
+	return NewRepository[itemModel, itemPayload, itemCondition](newTestDB(t, itemSchema), "items", opts...)
+}
+
+func itemOf(sku string, qty int) itemPayload {
+	name := "item " + sku
+	return itemPayload{SKU: &sku, Name: &name, Qty: &qty}
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	r := newItemRepo(t)
+	assert.Equal(t, []string{"item_id", "sku", "name", "qty"}, r.Columns())
+
+	item, err := r.Create(ctx, itemOf("a", 1))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *item.ID)
+	assert.Equal(t, "item a", *item.Name)
+	ids, err := r.CreateBulk(ctx, []itemPayload{itemOf("b", 2), itemOf("c", 3)})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{2, 3}, ids)
+
+	t.Run("create duplicate", func(t *testing.T) {
+		_, err := r.Create(ctx, itemOf("a", 1))
+		var dup *DuplicateKeyError
+		assert.True(t, errors.As(err, &dup))
+		assert.Equal(t, "items.sku", dup.Key)
+	})
+
+	t.Run("select", func(t *testing.T) {
+		qty := 2
+		result, err := r.Select(ctx, []string{"sku", "qty"}, &itemCondition{QtyGte: &qty}, &utils.Paginate{Page: 1, Limit: 1, Sort: []utils.Sort{{Field: "qty", Desc: true}}})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, result.Total)
+		assert.Len(t, result.Data, 1)
+		assert.Equal(t, "c", *result.Data[0].SKU)
+		assert.Nil(t, result.Data[0].ID)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		SKUs := []string{}
+		err := r.Stream(ctx, []string{"sku"}, nil, &utils.Paginate{Sort: []utils.Sort{{Field: "sku"}}}, func(item itemModel) error {
+			SKUs = append(SKUs, *item.SKU)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, SKUs)
+	})
+
+	t.Run("get by id", func(t *testing.T) {
+		item, err := r.GetByID(ctx, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, "b", *item.SKU)
+		assert.Equal(t, "item b", *item.Name)
+
+		_, err = r.GetByID(ctx, 99)
+		assert.ErrorIs(t, err, ErrNotFound)
+		var notFound *NotFoundError
+		assert.True(t, errors.As(err, &notFound))
+		assert.Equal(t, "items", notFound.Table)
+	})
+
+	t.Run("find one", func(t *testing.T) {
+		sku := "c"
+		item, err := r.FindOne(ctx, itemCondition{SKU: &sku})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, *item.Qty)
+
+		qty := 1
+		_, err = r.FindOne(ctx, itemCondition{QtyGte: &qty})
+		assert.ErrorIs(t, err, ErrNotUnique)
+
+		missing := "z"
+		_, err = r.FindOne(ctx, itemCondition{SKU: &missing})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = r.FindOne(ctx, itemCondition{})
+		assert.ErrorIs(t, err, ErrEmptyCondition)
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		SKUs := []string{"z", "a"}
+		found, err := r.Exists(ctx, itemCondition{SKUs: &SKUs})
+		assert.Nil(t, err)
+		assert.True(t, found)
+
+		missing := "z"
+		found, err = r.Exists(ctx, itemCondition{SKU: &missing})
+		assert.Nil(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("update bulk rolls back", func(t *testing.T) {
+		a, b := "a", "b"
+		qty := 10
+		fails, err := r.UpdateBulk(ctx, []Update[itemPayload, itemCondition]{
+			{Payload: itemPayload{Qty: &qty}, Condition: itemCondition{SKU: &a}},
+			{Payload: itemPayload{SKU: &a}, Condition: itemCondition{SKU: &b}},
+		})
+		assert.ErrorIs(t, err, ErrDuplicateKey)
+		assert.Len(t, fails, 2)
+		item, _ := r.FindOne(ctx, itemCondition{SKU: &a})
+		assert.Equal(t, 1, *item.Qty)
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		a := "a"
+		qty := 7
+		assert.Nil(t, r.Update(ctx, itemPayload{Qty: &qty}, itemCondition{SKU: &a}))
+		item, _ := r.FindOne(ctx, itemCondition{SKU: &a})
+		assert.Equal(t, 7, *item.Qty)
+
+		assert.Nil(t, r.Delete(ctx, itemCondition{SKU: &a}))
+		found, _ := r.Exists(ctx, itemCondition{SKU: &a})
+		assert.False(t, found)
+	})
+}
+
+func TestRepositoryVersion(t *testing.T) {
+	type versionedModel struct {
+		ID      *int    `db:"id,pk"`
+		Name    *string `db:"name"`
+		Version *int    `db:"version,version"`
+	}
+	type versionedPayload struct {
+		Name    *string `db:"name"`
+		Version *int    `db:"version,version"`
+	}
+	type versionedCondition struct {
+		ID *int `db:"id"`
+	}
+	ctx := context.Background()
+	db := newTestDB(t, "CREATE TABLE docs (id INTEGER PRIMARY KEY, name TEXT, version INTEGER NOT NULL DEFAULT 1)")
+	r := NewRepository[versionedModel, versionedPayload, versionedCondition](db, "docs")
+
+	name := "draft"
+	created, err := r.Create(ctx, versionedPayload{Name: &name})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *created.Version)
+	condition := versionedCondition{ID: created.ID}
+
+	t.Run("update", func(t *testing.T) {
+		first, second := "first", "second"
+		assert.Nil(t, r.Update(ctx, versionedPayload{Name: &first, Version: created.Version}, condition))
+
+		err := r.Update(ctx, versionedPayload{Name: &second, Version: created.Version}, condition)
+		assert.ErrorIs(t, err, ErrConflict)
+		var conflict *ConflictError
+		assert.True(t, errors.As(err, &conflict))
+		assert.Equal(t, "docs", conflict.Table)
+
+		item, _ := r.GetByID(ctx, *created.ID)
+		assert.Equal(t, "first", *item.Name)
+		assert.Equal(t, 2, *item.Version)
+	})
+
+	t.Run("update without version", func(t *testing.T) {
+		third := "third"
+		assert.Nil(t, r.Update(ctx, versionedPayload{Name: &third}, condition))
+		item, _ := r.GetByID(ctx, *created.ID)
+		assert.Equal(t, 3, *item.Version)
+	})
+
+	t.Run("update bulk", func(t *testing.T) {
+		fourth := "fourth"
+		current, stale := 3, 1
+		fails, err := r.UpdateBulk(ctx, []Update[versionedPayload, versionedCondition]{
+			{Payload: versionedPayload{Name: &fourth, Version: &current}, Condition: condition},
+			{Payload: versionedPayload{Name: &fourth, Version: &stale}, Condition: condition},
+		})
+		assert.ErrorIs(t, err, ErrConflict)
+		assert.Len(t, fails, 2)
+		item, _ := r.GetByID(ctx, *created.ID)
+		assert.Equal(t, "third", *item.Name)
+		assert.Equal(t, 3, *item.Version)
+	})
+}
+
+func TestRepositorySoftDelete(t *testing.T) {
+	type archivedModel struct {
+		ID        *int       `db:"id,pk"`
+		Name      *string    `db:"name"`
+		DeletedAt *time.Time `db:"deleted_at,softdelete"`
+	}
+	type archivedPayload struct {
+		Name *string `db:"name"`
+	}
+	type archivedCondition struct {
+		ID   *int    `db:"id"`
+		Name *string `db:"name"`
+	}
+	ctx := context.Background()
+	db := newTestDB(t, "CREATE TABLE archives (id INTEGER PRIMARY KEY, name TEXT, deleted_at DATETIME)")
+	r := NewRepository[archivedModel, archivedPayload, archivedCondition](db, "archives")
+
+	a, b := "a", "b"
+	first, err := r.Create(ctx, archivedPayload{Name: &a})
+	assert.Nil(t, err)
+	_, err = r.Create(ctx, archivedPayload{Name: &b})
+	assert.Nil(t, err)
+	assert.Nil(t, r.Delete(ctx, archivedCondition{Name: &a}))
+	assert.Equal(t, 2, countRows(t, db, "archives"))
+
+	t.Run("excluded by default", func(t *testing.T) {
+		result, err := r.Select(ctx, []string{"name"}, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "b", *result.Data[0].Name)
+
+		_, err = r.GetByID(ctx, *first.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		found, _ := r.Exists(ctx, archivedCondition{Name: &a})
+		assert.False(t, found)
+
+		renamed := "renamed"
+		assert.Nil(t, r.Update(ctx, archivedPayload{Name: &renamed}, archivedCondition{ID: first.ID}))
+		item, _ := r.GetByID(WithDeleted(ctx), *first.ID)
+		assert.Equal(t, "a", *item.Name)
+	})
+
+	t.Run("with deleted", func(t *testing.T) {
+		result, err := r.Select(WithDeleted(ctx), []string{"name"}, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, result.Total)
+
+		item, err := r.GetByID(WithDeleted(ctx), *first.ID)
+		assert.Nil(t, err)
+		assert.NotNil(t, item.DeletedAt)
+	})
+
+	t.Run("only deleted", func(t *testing.T) {
+		result, err := r.Select(OnlyDeleted(ctx), []string{"name"}, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "a", *result.Data[0].Name)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		assert.Nil(t, r.Restore(ctx, archivedCondition{Name: &a}))
+		item, err := r.GetByID(ctx, *first.ID)
+		assert.Nil(t, err)
+		assert.Nil(t, item.DeletedAt)
+	})
+
+	t.Run("hard delete", func(t *testing.T) {
+		assert.Nil(t, r.HardDelete(ctx, archivedCondition{Name: &a}))
+		assert.Equal(t, 1, countRows(t, db, "archives"))
+	})
+
+	t.Run("restore without soft delete", func(t *testing.T) {
+		err := newItemRepo(t).Restore(ctx, itemCondition{SKU: &a})
+		assert.ErrorIs(t, err, ErrInvalidField)
+	})
+}
+
+func TestRepositoryAudit(t *testing.T) {
+	type noteModel struct {
+		ID        *int       `db:"id,pk"`
+		Body      *string    `db:"body"`
+		CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+		UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
+		CreatedBy *string    `db:"created_by,createdBy"`
+		UpdatedBy *string    `db:"updated_by,updatedBy"`
+	}
+	type notePayload struct {
+		Body      *string    `db:"body"`
+		CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+		UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
+		CreatedBy *string    `db:"created_by,createdBy"`
+		UpdatedBy *string    `db:"updated_by,updatedBy"`
+	}
+	type noteCondition struct {
+		ID *int `db:"id"`
+	}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time { return now }
+	db := newTestDB(t, "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT, created_at DATETIME, updated_at DATETIME, created_by TEXT, updated_by TEXT)")
+	r := NewRepository[noteModel, notePayload, noteCondition](db, "notes", WithClock(clock))
+
+	body := "first"
+	created, err := r.Create(WithActor(context.Background(), "alice"), notePayload{Body: &body})
+	assert.Nil(t, err)
+	assert.True(t, now.Equal(*created.CreatedAt))
+	assert.True(t, now.Equal(*created.UpdatedAt))
+	assert.Equal(t, "alice", *created.CreatedBy)
+	assert.Equal(t, "alice", *created.UpdatedBy)
+
+	now = now.Add(time.Hour)
+	body = "second"
+	ctx := WithActor(context.Background(), "bob")
+	assert.Nil(t, r.Update(ctx, notePayload{Body: &body}, noteCondition{ID: created.ID}))
+	item, err := r.GetByID(ctx, *created.ID)
+	assert.Nil(t, err)
+	assert.True(t, created.CreatedAt.Equal(*item.CreatedAt))
+	assert.True(t, now.Equal(*item.UpdatedAt))
+	assert.Equal(t, "alice", *item.CreatedBy)
+	assert.Equal(t, "bob", *item.UpdatedBy)
+
+	ids, err := r.CreateBulk(context.Background(), []notePayload{{Body: &body}, {Body: &body}})
+	assert.Nil(t, err)
+	item, _ = r.GetByID(ctx, ids[1])
+	assert.True(t, now.Equal(*item.CreatedAt))
+	assert.Nil(t, item.CreatedBy)
+}
+
+type hookedPayload struct {
+	SKU *string `db:"sku"`
+	Qty *int    `db:"qty"`
+
+	created *[]int64
+}
+
+func (p *hookedPayload) BeforeCreate(ctx context.Context, db Executor) error {
+	if p.Qty != nil && *p.Qty < 0 {
+		return errors.New("negative qty")
+	}
+	SKU := strings.ToUpper(*p.SKU)
+	p.SKU = &SKU
+	return nil
+}
+
+func (p *hookedPayload) AfterCreate(ctx context.Context, db Executor, id int64) error {
+	if p.created != nil {
+		*p.created = append(*p.created, id)
+	}
+	return nil
+}
+
+func (p *hookedPayload) BeforeUpdate(ctx context.Context, db Executor) error {
+	return p.BeforeCreate(ctx, db)
+}
+
+type hookedCondition struct {
+	SKU *string `db:"sku"`
+}
+
+func (c *hookedCondition) BeforeDelete(ctx context.Context, db Executor) error {
+	total := 0
+	if err := sqlx.GetContext(ctx, db, &total, "SELECT COUNT(*) FROM items WHERE qty > 0 AND sku = ?", *c.SKU); err != nil {
+		return err
+	}
+	if total > 0 {
+		return errors.New("item in stock")
+	}
+	return nil
+}
+
+type hookedModel struct {
+	ID    *int    `db:"item_id,pk"`
+	SKU   *string `db:"sku"`
+	Qty   *int    `db:"qty"`
+	Label string  `db:"-"`
+}
+
+func (m *hookedModel) AfterFind(ctx context.Context, db Executor) error {
+	m.Label = fmt.Sprintf("%s x%d", *m.SKU, *m.Qty)
+	return nil
+}
+
+func TestRepositoryHooks(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, itemSchema)
+	r := NewRepository[hookedModel, hookedPayload, hookedCondition](db, "items")
+	hooked := func(sku string, qty int, created *[]int64) hookedPayload {
+		return hookedPayload{SKU: &sku, Qty: &qty, created: created}
+	}
+
+	t.Run("create", func(t *testing.T) {
+		created := []int64{}
+		item, err := r.Create(ctx, hooked("a", 1, &created))
+		assert.Nil(t, err)
+		assert.Equal(t, "A", *item.SKU)
+		assert.Equal(t, "A x1", item.Label)
+		assert.Equal(t, []int64{int64(*item.ID)}, created)
+
+		_, err = r.Create(ctx, hooked("b", -1, nil))
+		assert.ErrorContains(t, err, "negative qty")
+	})
+
+	t.Run("create bulk", func(t *testing.T) {
+		created := []int64{}
+		ids, err := r.CreateBulk(ctx, []hookedPayload{hooked("b", 0, &created), hooked("c", 2, &created)})
+		assert.Nil(t, err)
+		assert.Equal(t, ids, created)
+
+		_, err = r.CreateBulk(ctx, []hookedPayload{hooked("d", 1, nil), hooked("e", -1, nil)})
+		var rowErr *RowError
+		assert.True(t, errors.As(err, &rowErr))
+		assert.Equal(t, 1, rowErr.Index)
+		assert.Equal(t, 3, countRows(t, db, "items"))
+	})
+
+	t.Run("update bulk", func(t *testing.T) {
+		A, B := "A", "B"
+		fails, err := r.UpdateBulk(ctx, []Update[hookedPayload, hookedCondition]{
+			{Payload: hooked("a2", 1, nil), Condition: hookedCondition{SKU: &A}},
+			{Payload: hooked("b2", -5, nil), Condition: hookedCondition{SKU: &B}},
+		})
+		var rowErr *RowError
+		assert.True(t, errors.As(err, &rowErr))
+		assert.Equal(t, 1, rowErr.Index)
+		assert.Len(t, fails, 2)
+
+		assert.Nil(t, r.Update(ctx, hooked("a2", 0, nil), hookedCondition{SKU: &A}))
+		result, err := r.Select(ctx, []string{"sku", "qty"}, nil, &utils.Paginate{Sort: []utils.Sort{{Field: "sku"}}})
+		assert.Nil(t, err)
+		assert.Equal(t, "A2 x0", result.Data[0].Label)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		B, C := "B", "C"
+		assert.ErrorContains(t, r.Delete(ctx, hookedCondition{SKU: &C}), "item in stock")
+		assert.Nil(t, r.Delete(ctx, hookedCondition{SKU: &B}))
+		assert.Equal(t, 2, countRows(t, db, "items"))
+	})
+}
+
+func TestRepositoryValidate(t *testing.T) {
+	type checkedPayload struct {
+		SKU *string `db:"sku" validate:"required,pattern=^[a-z]+$"`
+		Qty *int    `db:"qty" validate:"min=0"`
+	}
+	ctx := context.Background()
+	r := NewRepository[itemModel, checkedPayload, itemCondition](newTestDB(t, itemSchema), "items")
+	sku, bad := "a", "A1"
+	qty, negative := 1, -1
+
+	_, err := r.Create(ctx, checkedPayload{Qty: &negative})
+	var validation utils.ValidationErrors
+	assert.True(t, errors.As(err, &validation))
+	assert.Equal(t, map[string][]string{"sku": {"is required"}, "qty": {"must be at least 0"}}, validation.Fields())
+
+	_, err = r.CreateBulk(ctx, []checkedPayload{{SKU: &sku, Qty: &qty}, {SKU: &bad}})
+	var rowErr *RowError
+	assert.True(t, errors.As(err, &rowErr))
+	assert.Equal(t, 1, rowErr.Index)
+	assert.True(t, errors.As(err, &validation))
+
+	_, err = r.Create(ctx, checkedPayload{SKU: &sku})
+	assert.Nil(t, err)
+	assert.Nil(t, r.Update(ctx, checkedPayload{Qty: &qty}, itemCondition{SKU: &sku}))
+	assert.True(t, errors.As(r.Update(ctx, checkedPayload{Qty: &negative}, itemCondition{SKU: &sku}), &validation))
+}
+
+func TestRepositoryUpdateExpression(t *testing.T) {
+	ctx := context.Background()
+	r := newItemRepo(t)
+	_, err := r.CreateBulk(ctx, []itemPayload{itemOf("a", 5), itemOf("b", 1)})
+	assert.Nil(t, err)
+	a, b := "a", "b"
+	take := func(n int) QueryOption { return Guard("qty >= ?", n) }
+
+	assert.Nil(t, r.Update(ctx, itemPayload{}, itemCondition{SKU: &a}, Set("qty", Decr(3)), take(3)))
+	err = r.Update(ctx, itemPayload{}, itemCondition{SKU: &a}, Set("qty", Decr(3)), take(3))
+	assert.ErrorIs(t, err, ErrGuardFailed)
+	item, _ := r.FindOne(ctx, itemCondition{SKU: &a})
+	assert.Equal(t, 2, *item.Qty)
+
+	fails, err := r.UpdateBulk(ctx, []Update[itemPayload, itemCondition]{
+		{Condition: itemCondition{SKU: &a}, Options: []QueryOption{Set("qty", Incr(10))}},
+		{Condition: itemCondition{SKU: &b}, Options: []QueryOption{Set("qty", Decr(2)), take(2)}},
+	})
+	var rowErr *RowError
+	assert.True(t, errors.As(err, &rowErr))
+	assert.Equal(t, 1, rowErr.Index)
+	assert.ErrorIs(t, err, ErrGuardFailed)
+	assert.Len(t, fails, 2)
+	item, _ = r.FindOne(ctx, itemCondition{SKU: &a})
+	assert.Equal(t, 2, *item.Qty)
+
+	assert.Nil(t, r.Update(ctx, itemPayload{}, itemCondition{SKU: &b}, Set("qty", Expr("MAX(qty - ?, 0)", 5))))
+	item, _ = r.FindOne(ctx, itemCondition{SKU: &b})
+	assert.Equal(t, 0, *item.Qty)
+
+	t.Run("or guard stays scoped", func(t *testing.T) {
+		assert.Nil(t, r.Update(ctx, itemPayload{}, itemCondition{SKU: &b}, Set("qty", Incr(1)), Guard("qty = ? OR qty > ?", 0, 1)))
+		item, _ := r.FindOne(ctx, itemCondition{SKU: &a})
+		assert.Equal(t, 2, *item.Qty)
+		item, _ = r.FindOne(ctx, itemCondition{SKU: &b})
+		assert.Equal(t, 1, *item.Qty)
+	})
+}
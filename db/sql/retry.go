@@ -0,0 +1,153 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	MySQLDeadlock        = 1213
+	MySQLLockWaitTimeout = 1205
+)
+
+type RetryPolicy struct {
+	// MaxAttempts counts the first try; 1 disables retrying.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter is the fraction of each delay that is randomised, 0 to 1.
+	Jitter float64
+
+	Retryable func(error) bool
+	Sleep     func(ctx context.Context, d time.Duration) error
+	Random    func() float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   20 * time.Millisecond,
+	MaxDelay:    time.Second,
+	Jitter:      0.5,
+}
+
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// Do runs fn until it succeeds, returns an error that is not retryable, the
+// attempts run out or ctx is done.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	sleep := p.Sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+		if err := sleep(ctx, p.Delay(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// Delay is the wait after the given failed attempt: exponential from
+// BaseDelay, capped at MaxDelay, with the jittered part drawn at random.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter <= 0 {
+		return delay
+	}
+	random := p.Random
+	if random == nil {
+		random = rand.Float64
+	}
+	jitter := p.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	fixed := float64(delay) * (1 - jitter)
+	return time.Duration(fixed + float64(delay)*jitter*random())
+}
+
+// RetryTransaction re-runs the whole transaction on retryable errors. Inside
+// an outer transaction it runs fn once, leaving retries to the outer caller.
+func RetryTransaction(ctx context.Context, db *sqlx.DB, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	if _, ok := TxFrom(ctx); ok {
+		return fn(ctx)
+	}
+	return policy.Do(ctx, func(ctx context.Context) error {
+		return Transaction(ctx, db, fn)
+	})
+}
+
+// IsRetryable recognises transient errors of the supported drivers: MySQL
+// deadlocks and lock wait timeouts, Postgres serialization failures and
+// deadlocks and SQLite busy errors, all of which roll the transaction back.
+// A broken connection is retryable only when the transaction failed to
+// begin; later it may hide a commit, see CommitError.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var begin *beginError
+	if errors.As(err, &begin) && isConnError(begin.err) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == MySQLDeadlock || mysqlErr.Number == MySQLLockWaitTimeout
+	}
+	if state, ok := sqlState(err); ok {
+		return state == "40001" || state == "40P01"
+	}
+	message := err.Error()
+	return strings.Contains(message, "database is locked") || strings.Contains(message, "database table is locked")
+}
+
+// beginError marks a failure to begin a transaction, before any statement
+// was sent.
+type beginError struct {
+	err error
+}
+
+func (e *beginError) Error() string { return e.err.Error() }
+func (e *beginError) Unwrap() error { return e.err }
+
+func isConnError(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
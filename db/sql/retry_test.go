@@ -0,0 +1,207 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// sqlStateError stands for drivers exposing a SQLState method, such as pgx.
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pq: " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func noSleep(delays *[]time.Duration) func(context.Context, time.Duration) error {
+	return func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return ctx.Err()
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	testCases := []struct {
+		Err      error
+		Expected bool
+	}{
+		{Err: nil, Expected: false},
+		{Err: &mysql.MySQLError{Number: MySQLDeadlock}, Expected: true},
+		{Err: fmt.Errorf("failed update db: %w", &mysql.MySQLError{Number: MySQLLockWaitTimeout}), Expected: true},
+		{Err: &mysql.MySQLError{Number: 1062}, Expected: false},
+		{Err: &pq.Error{Code: "40001", Message: "could not serialize access due to concurrent update"}, Expected: true},
+		{Err: fmt.Errorf("failed update db: %w", &pq.Error{Code: "40P01", Message: "deadlock detected"}), Expected: true},
+		{Err: &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}, Expected: false},
+		{Err: sqlStateError("40001"), Expected: true},
+		{Err: driver.ErrBadConn, Expected: false},
+		{Err: mysql.ErrInvalidConn, Expected: false},
+		{Err: fmt.Errorf("failed begin transaction: %w", &beginError{err: driver.ErrBadConn}), Expected: true},
+		{Err: &beginError{err: errors.New("access denied")}, Expected: false},
+		{Err: &CommitError{Err: mysql.ErrInvalidConn}, Expected: false},
+		{Err: errors.New("database is locked"), Expected: true},
+		{Err: errors.New("syntax error"), Expected: false},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			assert.Equal(t, tc.Expected, IsRetryable(tc.Err))
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: MySQLDeadlock}
+
+	t.Run("delay", func(t *testing.T) {
+		policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+		assert.Equal(t, 10*time.Millisecond, policy.Delay(1))
+		assert.Equal(t, 20*time.Millisecond, policy.Delay(2))
+		assert.Equal(t, 40*time.Millisecond, policy.Delay(3))
+		assert.Equal(t, 50*time.Millisecond, policy.Delay(4))
+
+		policy.Jitter = 0.5
+		policy.Random = func() float64 { return 0 }
+		assert.Equal(t, 5*time.Millisecond, policy.Delay(1))
+		policy.Random = func() float64 { return 1 }
+		assert.Equal(t, 10*time.Millisecond, policy.Delay(1))
+	})
+
+	t.Run("retry until success", func(t *testing.T) {
+		delays := []time.Duration{}
+		policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, Sleep: noSleep(&delays)}
+		attempts := 0
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return deadlock
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, delays)
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		delays := []time.Duration{}
+		policy := RetryPolicy{MaxAttempts: 3, Sleep: noSleep(&delays)}
+		attempts := 0
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return deadlock
+		})
+		assert.ErrorIs(t, err, deadlock)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		delays := []time.Duration{}
+		policy := RetryPolicy{MaxAttempts: 3, Sleep: noSleep(&delays)}
+		attempts := 0
+		failed := errors.New("duplicate")
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := DefaultRetryPolicy.Do(ctx, func(ctx context.Context) error { return deadlock })
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestRetryTransaction(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+	delays := []time.Duration{}
+	policy := RetryPolicy{MaxAttempts: 3, Sleep: noSleep(&delays)}
+
+	// The first attempt writes a row and then deadlocks; only the second,
+	// complete attempt may be committed.
+	attempts := 0
+	err := RetryTransaction(context.Background(), db, policy, func(ctx context.Context) error {
+		attempts++
+		db := ExecutorFrom(ctx, db)
+		if _, err := db.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", "a"); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return &mysql.MySQLError{Number: MySQLDeadlock}
+		}
+		_, err := db.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", "b")
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 2, countRows(t, db, "items"))
+}
+
+// lostConn is a driver connection dropping at begin or at commit.
+type lostConn struct {
+	failBegin  bool
+	failCommit bool
+	begins     int
+}
+
+func (c *lostConn) Connect(ctx context.Context) (driver.Conn, error) { return c, nil }
+func (c *lostConn) Driver() driver.Driver                            { return nil }
+func (c *lostConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *lostConn) Close() error { return nil }
+func (c *lostConn) Begin() (driver.Tx, error) {
+	c.begins++
+	if c.failBegin && c.begins == 1 {
+		return nil, mysql.ErrInvalidConn
+	}
+	return c, nil
+}
+func (c *lostConn) Commit() error {
+	if c.failCommit {
+		return mysql.ErrInvalidConn
+	}
+	return nil
+}
+func (c *lostConn) Rollback() error { return nil }
+
+func TestRetryTransactionConnectionLost(t *testing.T) {
+	delays := []time.Duration{}
+	policy := RetryPolicy{MaxAttempts: 3, Sleep: noSleep(&delays)}
+
+	t.Run("before begin", func(t *testing.T) {
+		conn := &lostConn{failBegin: true}
+		db := sqlx.NewDb(stdsql.OpenDB(conn), "mysql")
+		attempts := 0
+		err := RetryTransaction(context.Background(), db, policy, func(ctx context.Context) error {
+			attempts++
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, 2, conn.begins)
+	})
+
+	t.Run("during commit", func(t *testing.T) {
+		conn := &lostConn{failCommit: true}
+		db := sqlx.NewDb(stdsql.OpenDB(conn), "mysql")
+		attempts := 0
+		err := RetryTransaction(context.Background(), db, policy, func(ctx context.Context) error {
+			attempts++
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrCommitUnknown)
+		assert.ErrorIs(t, err, mysql.ErrInvalidConn)
+		assert.Equal(t, 1, attempts)
+	})
+}
@@ -0,0 +1,185 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Balance picks the replica serving a read.
+type Balance int
+
+const (
+	RoundRobin Balance = iota
+	LeastLatency
+)
+
+type RouterOption func(*Router)
+
+// WithBalance sets how reads spread over the healthy replicas, RoundRobin by
+// default. LeastLatency picks the replica with the lowest ping time measured
+// by CheckHealth.
+func WithBalance(balance Balance) RouterOption {
+	return func(r *Router) {
+		r.balance = balance
+	}
+}
+
+// WithStickiness limits how long the reads of a session stay on the primary
+// after its last write. By default they stay for the rest of the session.
+func WithStickiness(window time.Duration) RouterOption {
+	return func(r *Router) {
+		r.stickiness = window
+	}
+}
+
+type replica struct {
+	db      *sqlx.DB
+	healthy bool
+	latency time.Duration
+}
+
+// Router sends reads to replicas and leaves writes to the primary. Reads go
+// to the primary when ctx carries a transaction, when its session wrote,
+// see WithSession, or when no replica is healthy.
+type Router struct {
+	primary    *sqlx.DB
+	balance    Balance
+	stickiness time.Duration
+	next       uint32
+
+	mu       sync.RWMutex
+	replicas []*replica
+}
+
+func NewRouter(primary *sqlx.DB, replicas []*sqlx.DB, opts ...RouterOption) *Router {
+	r := &Router{primary: primary}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db, healthy: true})
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Router) Primary() *sqlx.DB {
+	return r.primary
+}
+
+// Reader returns the executor of a read made with ctx.
+func (r *Router) Reader(ctx context.Context) Executor {
+	if tx, ok := TxFrom(ctx); ok {
+		return tx
+	}
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok && s.sticky(r.stickiness) {
+		return r.primary
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	healthy := make([]*replica, 0, len(r.replicas))
+	for _, item := range r.replicas {
+		if item.healthy {
+			healthy = append(healthy, item)
+		}
+	}
+	if len(healthy) == 0 {
+		return r.primary
+	}
+	if r.balance == LeastLatency {
+		best := healthy[0]
+		for _, item := range healthy[1:] {
+			if item.latency < best.latency {
+				best = item
+			}
+		}
+		return best.db
+	}
+	idx := atomic.AddUint32(&r.next, 1) - 1
+	return healthy[int(idx%uint32(len(healthy)))].db
+}
+
+// SetHealthy marks the replica at index, in NewRouter order, as serving reads
+// or not.
+func (r *Router) SetHealthy(index int, healthy bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if index >= 0 && index < len(r.replicas) {
+		r.replicas[index].healthy = healthy
+	}
+}
+
+// CheckHealth pings every replica, marking the ones that fail unhealthy and
+// recording the ping time of the others.
+func (r *Router) CheckHealth(ctx context.Context) {
+	r.mu.RLock()
+	replicas := append([]*replica{}, r.replicas...)
+	r.mu.RUnlock()
+	for _, item := range replicas {
+		start := time.Now()
+		err := item.db.PingContext(ctx)
+		latency := time.Since(start)
+		r.mu.Lock()
+		item.healthy = err == nil
+		if err == nil {
+			item.latency = latency
+		}
+		r.mu.Unlock()
+	}
+}
+
+// RunHealthCheck checks the replicas every interval until ctx is done.
+func (r *Router) RunHealthCheck(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.CheckHealth(ctx)
+		}
+	}
+}
+
+// WithRouter makes the repository read through router. The repository db
+// stays the primary all writes go to.
+func WithRouter(router *Router) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.router = router
+	}
+}
+
+type sessionKey struct{}
+
+type session struct {
+	mu      sync.Mutex
+	written time.Time
+}
+
+// WithSession starts a session on ctx: once a repository writes with it, the
+// reads of the session go to the primary so they see the write.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// markWritten records a write of the session of ctx, if any.
+func markWritten(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.mu.Lock()
+		s.written = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+func (s *session) sticky(window time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.written.IsZero() {
+		return false
+	}
+	return window <= 0 || time.Since(s.written) < window
+}
@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// newServers returns a primary and replicas holding one item each, its sku
+// naming the server, so reads tell where they were served.
+func newServers(t *testing.T, replicas int) (*sqlx.DB, []*sqlx.DB) {
+	server := func(name string) *sqlx.DB {
+		db := newTestDB(t, itemSchema)
+		db.MustExec("INSERT INTO items (sku, qty) VALUES (?, 1)", name)
+		return db
+	}
+	primary := server("primary")
+	dbs := []*sqlx.DB{}
+	for i := 0; i < replicas; i++ {
+		dbs = append(dbs, server(fmt.Sprintf("replica%d", i)))
+	}
+	return primary, dbs
+}
+
+func servedBy(t *testing.T, r *Repository[itemModel, itemPayload, itemCondition], ctx context.Context) string {
+	item, err := r.GetByID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *item.SKU
+}
+
+func TestRouter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("round robin over healthy replicas", func(t *testing.T) {
+		primary, replicas := newServers(t, 3)
+		router := NewRouter(primary, replicas)
+		r := NewRepository[itemModel, itemPayload, itemCondition](primary, "items", WithRouter(router))
+		router.SetHealthy(1, false)
+
+		testCases := []string{"replica0", "replica2", "replica0", "replica2"}
+		for i, expected := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				assert.Equal(t, expected, servedBy(t, r, ctx))
+			})
+		}
+
+		result, err := r.Select(ctx, r.Columns(), &itemCondition{}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "replica0", *result.Data[0].SKU)
+
+		router.SetHealthy(0, false)
+		router.SetHealthy(2, false)
+		assert.Equal(t, "primary", servedBy(t, r, ctx))
+	})
+
+	t.Run("least latency", func(t *testing.T) {
+		primary, replicas := newServers(t, 2)
+		router := NewRouter(primary, replicas, WithBalance(LeastLatency))
+		router.CheckHealth(ctx)
+		router.replicas[0].latency = time.Second
+		router.replicas[1].latency = time.Millisecond
+		r := NewRepository[itemModel, itemPayload, itemCondition](primary, "items", WithRouter(router))
+		assert.Equal(t, "replica1", servedBy(t, r, ctx))
+		assert.Equal(t, "replica1", servedBy(t, r, ctx))
+	})
+
+	t.Run("health check skips unreachable replicas", func(t *testing.T) {
+		primary, replicas := newServers(t, 2)
+		router := NewRouter(primary, replicas)
+		r := NewRepository[itemModel, itemPayload, itemCondition](primary, "items", WithRouter(router))
+		replicas[0].Close()
+		router.CheckHealth(ctx)
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, "replica1", servedBy(t, r, ctx))
+		}
+	})
+
+	t.Run("writes go to the primary and stick their session", func(t *testing.T) {
+		primary, replicas := newServers(t, 1)
+		router := NewRouter(primary, replicas)
+		r := NewRepository[itemModel, itemPayload, itemCondition](primary, "items", WithRouter(router))
+
+		session := WithSession(ctx)
+		assert.Equal(t, "replica0", servedBy(t, r, session))
+		_, err := r.Create(session, itemOf("new", 1))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, countRows(t, primary, "items"))
+		assert.Equal(t, 1, countRows(t, replicas[0], "items"))
+
+		assert.Equal(t, "primary", servedBy(t, r, session))
+		exists, err := r.Exists(session, itemCondition{SKU: strPtr("new")})
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, "replica0", servedBy(t, r, ctx))
+	})
+
+	t.Run("stickiness window", func(t *testing.T) {
+		primary, replicas := newServers(t, 1)
+		router := NewRouter(primary, replicas, WithStickiness(50*time.Millisecond))
+		r := NewRepository[itemModel, itemPayload, itemCondition](primary, "items", WithRouter(router))
+		session := WithSession(ctx)
+		assert.Nil(t, r.Update(session, itemOf("primary", 2), itemCondition{SKU: strPtr("primary")}))
+		assert.Equal(t, "primary", servedBy(t, r, session))
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, "replica0", servedBy(t, r, session))
+	})
+
+	t.Run("transactions read the primary", func(t *testing.T) {
+		primary, replicas := newServers(t, 1)
+		router := NewRouter(primary, replicas)
+		r := NewRepository[itemModel, itemPayload, itemCondition](primary, "items", WithRouter(router))
+		err := Transaction(ctx, primary, func(ctx context.Context) error {
+			assert.Equal(t, "primary", servedBy(t, r, ctx))
+			return nil
+		})
+		assert.Nil(t, err)
+	})
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,43 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+)
+
+type DeletedScope int
+
+const (
+	ScopeLive DeletedScope = iota
+	ScopeWithDeleted
+	ScopeOnlyDeleted
+)
+
+type deletedScopeKey struct{}
+
+// WithDeleted makes soft delete repositories also see deleted rows.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedScopeKey{}, ScopeWithDeleted)
+}
+
+// OnlyDeleted makes soft delete repositories see deleted rows only.
+func OnlyDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedScopeKey{}, ScopeOnlyDeleted)
+}
+
+func DeletedScopeFrom(ctx context.Context) DeletedScope {
+	scope, _ := ctx.Value(deletedScopeKey{}).(DeletedScope)
+	return scope
+}
+
+// DeletedScopeOptions returns the query options restricting column to the
+// rows visible in scope.
+func DeletedScopeOptions(column string, scope DeletedScope) []QueryOption {
+	switch scope {
+	case ScopeWithDeleted:
+		return nil
+	case ScopeOnlyDeleted:
+		return []QueryOption{Where(fmt.Sprintf("%s IS NOT NULL", column))}
+	}
+	return []QueryOption{Where(fmt.Sprintf("%s IS NULL", column))}
+}
@@ -0,0 +1,102 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type txKey struct{}
+
+// txValue is the transaction carried by a context. hooks is set when the
+// transaction was begun by Transaction, which runs them after the commit.
+type txValue struct {
+	tx    *sqlx.Tx
+	hooks *txHooks
+}
+
+type txHooks struct {
+	mu          sync.Mutex
+	afterCommit []func()
+}
+
+// Executor runs statements either on the database or on the transaction
+// carried by the context.
+type Executor interface {
+	sqlx.ExtContext
+}
+
+func WithTx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	if current, ok := ctx.Value(txKey{}).(txValue); ok && current.tx == tx {
+		return ctx
+	}
+	return context.WithValue(ctx, txKey{}, txValue{tx: tx})
+}
+
+func TxFrom(ctx context.Context) (*sqlx.Tx, bool) {
+	value, ok := ctx.Value(txKey{}).(txValue)
+	return value.tx, ok && value.tx != nil
+}
+
+// AfterCommit runs fn once the transaction carried by ctx commits and drops
+// it on rollback. Without a transaction fn runs immediately, as it does in a
+// transaction begun outside Transaction, whose commit cannot be observed.
+func AfterCommit(ctx context.Context, fn func()) {
+	value, _ := ctx.Value(txKey{}).(txValue)
+	if value.tx == nil || value.hooks == nil {
+		fn()
+		return
+	}
+	value.hooks.mu.Lock()
+	defer value.hooks.mu.Unlock()
+	value.hooks.afterCommit = append(value.hooks.afterCommit, fn)
+}
+
+func ExecutorFrom(ctx context.Context, db *sqlx.DB) Executor {
+	if tx, ok := TxFrom(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// Transaction runs fn inside a transaction reachable through ctx. When ctx
+// already carries a transaction fn joins it, so the outermost caller decides
+// on commit and rollback. The AfterCommit functions of fn run once the
+// transaction commits. A connection lost during the commit is reported as a
+// CommitError.
+func Transaction(ctx context.Context, db *sqlx.DB, fn func(ctx context.Context) error) (err error) {
+	if _, ok := TxFrom(ctx); ok {
+		return fn(ctx)
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %w", &beginError{err: err})
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	hooks := &txHooks{}
+	if err := fn(context.WithValue(ctx, txKey{}, txValue{tx: tx, hooks: hooks})); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, stdsql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		if isConnError(err) {
+			return &CommitError{Err: err}
+		}
+		return fmt.Errorf("failed commit transaction: %w", err)
+	}
+	for _, hook := range hooks.afterCommit {
+		hook()
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDB(t *testing.T, schema ...string) *sqlx.DB {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, statement := range schema {
+		db.MustExec(statement)
+	}
+	return db
+}
+
+func countRows(t *testing.T, db *sqlx.DB, table string) int {
+	total := 0
+	if err := db.Get(&total, "SELECT COUNT(*) FROM "+table); err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
+
+func TestTransaction(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+	ctx := context.Background()
+	insert := func(ctx context.Context, name string) error {
+		_, err := ExecutorFrom(ctx, db).ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", name)
+		return err
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		err := Transaction(ctx, db, func(ctx context.Context) error {
+			_, ok := TxFrom(ctx)
+			assert.True(t, ok)
+			return insert(ctx, "a")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, countRows(t, db, "items"))
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		failed := errors.New("failed")
+		err := Transaction(ctx, db, func(ctx context.Context) error {
+			if err := insert(ctx, "b"); err != nil {
+				return err
+			}
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		assert.Equal(t, 1, countRows(t, db, "items"))
+	})
+
+	t.Run("nested joins outer", func(t *testing.T) {
+		failed := errors.New("failed")
+		err := Transaction(ctx, db, func(ctx context.Context) error {
+			outer, _ := TxFrom(ctx)
+			err := Transaction(ctx, db, func(ctx context.Context) error {
+				inner, _ := TxFrom(ctx)
+				assert.Same(t, outer, inner)
+				return insert(ctx, "c")
+			})
+			assert.Nil(t, err)
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		assert.Equal(t, 1, countRows(t, db, "items"))
+	})
+	t.Run("after commit", func(t *testing.T) {
+		calls := []string{}
+		AfterCommit(ctx, func() { calls = append(calls, "no tx") })
+		err := Transaction(ctx, db, func(ctx context.Context) error {
+			AfterCommit(ctx, func() { calls = append(calls, "committed") })
+			assert.Equal(t, []string{"no tx"}, calls)
+			return insert(ctx, "d")
+		})
+		assert.Nil(t, err)
+		err = Transaction(ctx, db, func(ctx context.Context) error {
+			AfterCommit(ctx, func() { calls = append(calls, "rolled back") })
+			return errors.New("failed")
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, []string{"no tx", "committed"}, calls)
+	})
+}
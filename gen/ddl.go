@@ -0,0 +1,382 @@
+package gen
+
+import (
+	"bulk/db/sql"
+	"fmt"
+	"strings"
+)
+
+// ParseDDL reads the tables declared by the CREATE TABLE and CREATE INDEX
+// statements of script, in the MySQL, Postgres or SQLite dialect. Other
+// statements, foreign keys and checks are ignored.
+func ParseDDL(script string) ([]sql.TableDef, error) {
+	tables := []sql.TableDef{}
+	find := func(name string) *sql.TableDef {
+		for i := range tables {
+			if tables[i].Name == name {
+				return &tables[i]
+			}
+		}
+		return nil
+	}
+	for _, statement := range splitDDL(script) {
+		tokens := tokenize(statement)
+		words := upper(tokens)
+		switch {
+		case startsWith(words, "CREATE", "TABLE"), startsWith(words, "CREATE", "TEMPORARY", "TABLE"):
+			table, err := parseCreateTable(tokens)
+			if err != nil {
+				return nil, err
+			}
+			if find(table.Name) != nil {
+				return nil, fmt.Errorf("table %s declared twice", table.Name)
+			}
+			tables = append(tables, table)
+		case startsWith(words, "CREATE", "INDEX"), startsWith(words, "CREATE", "UNIQUE", "INDEX"):
+			name, index, err := parseCreateIndex(tokens)
+			if err != nil {
+				return nil, err
+			}
+			table := find(name)
+			if table == nil {
+				return nil, fmt.Errorf("index %s on undeclared table %s", index.Name, name)
+			}
+			table.Indexes = append(table.Indexes, index)
+		}
+	}
+	return tables, nil
+}
+
+func parseCreateTable(tokens []string) (sql.TableDef, error) {
+	words := upper(tokens)
+	i := 2
+	if words[1] == "TEMPORARY" {
+		i = 3
+	}
+	if startsWith(words[i:], "IF", "NOT", "EXISTS") {
+		i += 3
+	}
+	if i >= len(tokens) {
+		return sql.TableDef{}, fmt.Errorf("failed parse create table: %s", strings.Join(tokens, " "))
+	}
+	name, body := group(tokens[i:])
+	if body == "" {
+		return sql.TableDef{}, fmt.Errorf("failed parse create table: %s", strings.Join(tokens, " "))
+	}
+	table := sql.TableDef{Name: name}
+	for _, item := range splitTop(inner(body), ',') {
+		if err := parseTableItem(&table, tokenize(item)); err != nil {
+			return sql.TableDef{}, fmt.Errorf("failed parse table %s: %w", table.Name, err)
+		}
+	}
+	if len(table.Columns) == 0 {
+		return sql.TableDef{}, fmt.Errorf("table %s has no columns", table.Name)
+	}
+	return table, nil
+}
+
+func parseTableItem(table *sql.TableDef, tokens []string) error {
+	words := upper(tokens)
+	if len(words) == 0 {
+		return nil
+	}
+	name := ""
+	if words[0] == "CONSTRAINT" && len(tokens) > 2 {
+		name, tokens, words = unquote(tokens[1]), tokens[2:], words[2:]
+	}
+	switch words[0] {
+	case "PRIMARY":
+		columns := indexColumns(tokens[len(tokens)-1])
+		table.PrimaryKey = columns
+		for i := range table.Columns {
+			for _, column := range columns {
+				if table.Columns[i].Name == column {
+					table.Columns[i].PrimaryKey = true
+				}
+			}
+		}
+		return nil
+	case "UNIQUE", "KEY", "INDEX", "FULLTEXT", "SPATIAL":
+		if words[0] == "FULLTEXT" || words[0] == "SPATIAL" {
+			return nil
+		}
+		unique := words[0] == "UNIQUE"
+		rest := tokens[1:]
+		if len(rest) > 0 && (strings.EqualFold(rest[0], "KEY") || strings.EqualFold(rest[0], "INDEX")) {
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			return fmt.Errorf("index without columns")
+		}
+		definition := rest[0]
+		if !strings.HasPrefix(rest[0], "(") {
+			name, definition = group(rest)
+		}
+		if definition == "" {
+			return fmt.Errorf("index %s without columns", name)
+		}
+		columns := indexColumns(definition)
+		if name == "" && unique && len(columns) == 1 {
+			for i := range table.Columns {
+				if table.Columns[i].Name == columns[0] {
+					table.Columns[i].Unique = true
+				}
+			}
+			return nil
+		}
+		if name == "" {
+			prefix := "idx_"
+			if unique {
+				prefix = "uq_"
+			}
+			name = prefix + table.Name + "_" + strings.Join(columns, "_")
+		}
+		table.Indexes = append(table.Indexes, sql.IndexDef{Name: name, Unique: unique, Columns: columns})
+		return nil
+	case "FOREIGN", "CHECK", "EXCLUDE":
+		return nil
+	}
+	return parseColumn(table, tokens)
+}
+
+var columnKeywords = map[string]bool{
+	"NOT": true, "NULL": true, "DEFAULT": true, "PRIMARY": true, "UNIQUE": true, "AUTO_INCREMENT": true,
+	"AUTOINCREMENT": true, "GENERATED": true, "REFERENCES": true, "CHECK": true, "COLLATE": true,
+	"COMMENT": true, "CONSTRAINT": true, "ON": true, "CHARACTER": true, "CHARSET": true, "KEY": true,
+}
+
+func parseColumn(table *sql.TableDef, tokens []string) error {
+	words := upper(tokens)
+	column := sql.ColumnDef{Name: unquote(tokens[0])}
+	i := 1
+	typ := []string{}
+	for ; i < len(tokens); i++ {
+		// CHARACTER VARYING is a type, CHARACTER SET an attribute.
+		if columnKeywords[words[i]] && !(words[i] == "CHARACTER" && len(typ) == 0) {
+			break
+		}
+		typ = append(typ, tokens[i])
+	}
+	column.Type = strings.Join(typ, " ")
+	switch strings.ToUpper(column.Type) {
+	case "SERIAL":
+		column.Type, column.AutoIncrement, column.NotNull = "INTEGER", true, true
+	case "BIGSERIAL":
+		column.Type, column.AutoIncrement, column.NotNull = "BIGINT", true, true
+	}
+	for ; i < len(tokens); i++ {
+		switch words[i] {
+		case "NOT":
+			if i+1 < len(words) && words[i+1] == "NULL" {
+				column.NotNull = true
+				i++
+			}
+		case "DEFAULT":
+			if i+1 < len(tokens) {
+				value := tokens[i+1]
+				column.Default = &value
+				i++
+			}
+		case "PRIMARY":
+			column.PrimaryKey = true
+			table.PrimaryKey = []string{column.Name}
+		case "UNIQUE":
+			column.Unique = true
+		case "AUTO_INCREMENT", "AUTOINCREMENT":
+			column.AutoIncrement = true
+		case "GENERATED":
+			for i < len(words) && words[i] != "IDENTITY" {
+				i++
+			}
+			column.AutoIncrement = true
+		case "REFERENCES", "CHECK", "COMMENT", "COLLATE":
+			i++
+		}
+	}
+	if column.Type == "" {
+		return fmt.Errorf("column %s has no type", column.Name)
+	}
+	table.Columns = append(table.Columns, column)
+	return nil
+}
+
+func parseCreateIndex(tokens []string) (string, sql.IndexDef, error) {
+	words := upper(tokens)
+	index := sql.IndexDef{}
+	i := 2
+	if words[1] == "UNIQUE" {
+		index.Unique, i = true, 3
+	}
+	if startsWith(words[i:], "IF", "NOT", "EXISTS") {
+		i += 3
+	}
+	if i+2 >= len(tokens) || words[i+1] != "ON" {
+		return "", index, fmt.Errorf("failed parse create index: %s", strings.Join(tokens, " "))
+	}
+	index.Name = unquote(tokens[i])
+	rest := tokens[i+2:]
+	table, columns := group(rest)
+	if columns == "" && len(rest) > 2 && strings.EqualFold(rest[1], "USING") {
+		_, columns = group(rest[2:])
+	}
+	if columns == "" {
+		return "", index, fmt.Errorf("index %s without columns", index.Name)
+	}
+	index.Columns = indexColumns(columns)
+	return table, index, nil
+}
+
+// indexColumns lists the column names of a parenthesized index definition,
+// dropping sort orders and prefix lengths.
+func indexColumns(group string) []string {
+	columns := []string{}
+	for _, part := range splitTop(inner(group), ',') {
+		fields := tokenize(part)
+		if len(fields) > 0 {
+			name := fields[0]
+			if i := strings.Index(name, "("); i > 0 {
+				name = name[:i]
+			}
+			columns = append(columns, unquote(name))
+		}
+	}
+	return columns
+}
+
+// splitDDL splits a script into statements on semicolons outside quotes,
+// dropping comments.
+func splitDDL(script string) []string {
+	cleaned := strings.Builder{}
+	for _, line := range strings.Split(script, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 && strings.Count(line[:i], "'")%2 == 0 {
+			line = line[:i]
+		}
+		cleaned.WriteString(line)
+		cleaned.WriteString("\n")
+	}
+	statements := []string{}
+	for _, statement := range splitTop(cleaned.String(), ';') {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// splitTop splits s on sep outside parentheses and quotes.
+func splitTop(s string, sep rune) []string {
+	parts := []string{}
+	depth, quote, start := 0, rune(0), 0
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// tokenize splits a statement into words, quoted strings and parenthesized
+// groups. A group directly following a word is kept with it, so VARCHAR(64)
+// and DECIMAL (12, 2) are single tokens.
+func tokenize(s string) []string {
+	tokens := []string{}
+	current := strings.Builder{}
+	depth, quote := 0, rune(0)
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			current.WriteRune(c)
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteRune(c)
+		case c == '(':
+			if depth == 0 && current.Len() == 0 && len(tokens) > 0 && isWord(tokens[len(tokens)-1]) {
+				current.WriteString(tokens[len(tokens)-1])
+				tokens = tokens[:len(tokens)-1]
+			}
+			depth++
+			current.WriteRune(c)
+		case c == ')':
+			depth--
+			current.WriteRune(c)
+		case depth == 0 && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			flush()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func isWord(token string) bool {
+	if token == "" || strings.ContainsAny(token, "()'\"`") {
+		return false
+	}
+	return !columnKeywords[strings.ToUpper(token)] && !strings.EqualFold(token, "ON") && !strings.EqualFold(token, "USING")
+}
+
+// group reads a name followed by a parenthesized group, whether tokenize
+// kept them together or not.
+func group(tokens []string) (name string, definition string) {
+	if i := strings.Index(tokens[0], "("); i > 0 {
+		return unquote(tokens[0][:i]), tokens[0][i:]
+	}
+	if len(tokens) > 1 && strings.HasPrefix(tokens[1], "(") {
+		return unquote(tokens[0]), tokens[1]
+	}
+	return unquote(tokens[0]), ""
+}
+
+func inner(group string) string {
+	start, end := strings.Index(group, "("), strings.LastIndex(group, ")")
+	if start < 0 || end < start {
+		return ""
+	}
+	return group[start+1 : end]
+}
+
+func unquote(name string) string {
+	return strings.Trim(name, "`\"[]")
+}
+
+func upper(tokens []string) []string {
+	words := make([]string, len(tokens))
+	for i, token := range tokens {
+		words[i] = strings.ToUpper(token)
+	}
+	return words
+}
+
+func startsWith(words []string, prefix ...string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if words[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package gen
+
+import (
+	"bulk/db/sql"
+	"bulk/schema"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestParseDDL(t *testing.T) {
+	testCases := []struct {
+		DDL      string
+		Expected []sql.TableDef
+		HasError bool
+	}{
+		{
+			DDL: "CREATE TABLE IF NOT EXISTS `items` (\n" +
+				"  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT, -- key\n" +
+				"  `sku` VARCHAR(64) CHARACTER SET utf8mb4 NOT NULL,\n" +
+				"  price DECIMAL (12, 2) DEFAULT '0.00',\n" +
+				"  updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  UNIQUE KEY uq_items_sku (`sku`),\n" +
+				"  KEY idx_items_price(price, sku(10)),\n" +
+				"  FOREIGN KEY (sku) REFERENCES skus (code)\n" +
+				") ENGINE=InnoDB;",
+			Expected: []sql.TableDef{{
+				Name: "items",
+				Columns: []sql.ColumnDef{
+					{Name: "id", Type: "BIGINT UNSIGNED", NotNull: true, PrimaryKey: true, AutoIncrement: true},
+					{Name: "sku", Type: "VARCHAR(64)", NotNull: true},
+					{Name: "price", Type: "DECIMAL(12, 2)", Default: strPtr("'0.00'")},
+					{Name: "updated_at", Type: "DATETIME(6)", Default: strPtr("CURRENT_TIMESTAMP(6)")},
+				},
+				PrimaryKey: []string{"id"},
+				Indexes: []sql.IndexDef{
+					{Name: "uq_items_sku", Unique: true, Columns: []string{"sku"}},
+					{Name: "idx_items_price", Columns: []string{"price", "sku"}},
+				},
+			}},
+		},
+		{
+			DDL: `CREATE TABLE "items" (
+	id BIGSERIAL PRIMARY KEY,
+	code CHARACTER VARYING(32) UNIQUE,
+	seen TIMESTAMP WITH TIME ZONE NOT NULL,
+	ref BIGINT GENERATED BY DEFAULT AS IDENTITY,
+	CONSTRAINT uq_items_ref UNIQUE (ref, code)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_items_seen ON items USING btree (seen DESC);
+INSERT INTO items (code) VALUES ('a;b');`,
+			Expected: []sql.TableDef{{
+				Name: "items",
+				Columns: []sql.ColumnDef{
+					{Name: "id", Type: "BIGINT", NotNull: true, PrimaryKey: true, AutoIncrement: true},
+					{Name: "code", Type: "CHARACTER VARYING(32)", Unique: true},
+					{Name: "seen", Type: "TIMESTAMP WITH TIME ZONE", NotNull: true},
+					{Name: "ref", Type: "BIGINT", AutoIncrement: true},
+				},
+				PrimaryKey: []string{"id"},
+				Indexes: []sql.IndexDef{
+					{Name: "uq_items_ref", Unique: true, Columns: []string{"ref", "code"}},
+					{Name: "idx_items_seen", Unique: true, Columns: []string{"seen"}},
+				},
+			}},
+		},
+		{
+			DDL: "CREATE TABLE pairs (a INTEGER, b TEXT, PRIMARY KEY (a, b), UNIQUE (b, a))",
+			Expected: []sql.TableDef{{
+				Name: "pairs",
+				Columns: []sql.ColumnDef{
+					{Name: "a", Type: "INTEGER", PrimaryKey: true},
+					{Name: "b", Type: "TEXT", PrimaryKey: true},
+				},
+				PrimaryKey: []string{"a", "b"},
+				Indexes:    []sql.IndexDef{{Name: "uq_pairs_b_a", Unique: true, Columns: []string{"b", "a"}}},
+			}},
+		},
+		{DDL: "CREATE INDEX idx ON missing (a)", HasError: true},
+		{DDL: "CREATE TABLE a (id INTEGER); CREATE TABLE a (id INTEGER)", HasError: true},
+		{DDL: "CREATE TABLE a (id)", HasError: true},
+		{DDL: "CREATE TABLE a", HasError: true},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			actual, err := ParseDDL(tc.DDL)
+			assert.Equal(t, tc.HasError, err != nil, err)
+			if !tc.HasError {
+				assert.Equal(t, tc.Expected, actual)
+			}
+		})
+	}
+}
+
+// The builtin migrations parse, and for SQLite agree with the live tables.
+func TestParseDDLBuiltin(t *testing.T) {
+	for _, dialect := range []sql.Dialect{sql.MySQL, sql.Postgres, sql.SQLite} {
+		files, err := filepath.Glob(filepath.Join("..", "migrate", "migrations", string(dialect), "*.up.sql"))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, files)
+		for _, file := range files {
+			script, err := os.ReadFile(file)
+			assert.Nil(t, err)
+			tables, err := ParseDDL(string(script))
+			assert.Nil(t, err, file)
+			assert.Len(t, tables, 1, file)
+			if dialect != sql.SQLite || len(tables) != 1 {
+				continue
+			}
+
+			db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+			assert.Nil(t, err)
+			for _, statement := range strings.Split(string(script), ";") {
+				if strings.TrimSpace(statement) != "" {
+					db.MustExec(statement)
+				}
+			}
+			live, err := schema.Inspect(context.Background(), db, tables[0].Name)
+			assert.Nil(t, err)
+			assert.Empty(t, schema.Compare(sql.SQLite, tables[0], live), file)
+			db.Close()
+		}
+	}
+}
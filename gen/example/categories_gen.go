@@ -0,0 +1,108 @@
+// Code generated by bulk/cmd/gen from schema.sql. DO NOT EDIT.
+
+package example
+
+import (
+	"bulk/db/sql"
+	"bulk/utils"
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const CategoryTable = "categories"
+
+// The index idx_categories_visible (visible, parent_id) does not fit the model tags.
+type CategoryModel struct {
+	ID        *int64     `db:"id,pk,autoincrement"`
+	ParentID  *int64     `db:"parent_id,index=idx_categories_parent"`
+	Slug      *string    `db:"slug,type=VARCHAR(64),notnull,unique"`
+	Name      *string    `db:"name,notnull"`
+	Position  *int       `db:"position,type=INT,notnull,default=0,index=idx_categories_parent"`
+	Visible   *bool      `db:"visible,notnull,default=TRUE"`
+	Discount  *float64   `db:"discount,type=DECIMAL(5,2)"`
+	Icon      *[]byte    `db:"icon"`
+	Version   *int       `db:"version,version,type=INT,notnull,default=1"`
+	CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+	UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
+	DeletedAt *time.Time `db:"deleted_at,softdelete"`
+}
+
+// CategoryColumns are the typed column handles of CategoryModel, see
+// sql.ColumnsOf.
+type CategoryColumns struct {
+	sql.ColumnSet
+	ID        sql.Column
+	ParentID  sql.Column
+	Slug      sql.Column
+	Name      sql.Column
+	Position  sql.Column
+	Visible   sql.Column
+	Discount  sql.Column
+	Icon      sql.Column
+	Version   sql.Column
+	CreatedAt sql.Column
+	UpdatedAt sql.Column
+	DeletedAt sql.Column
+}
+
+var CategoryCols = sql.MustColumnsOf[CategoryColumns, CategoryModel]()
+
+type CategoryPayload struct {
+	ParentID  *int64     `db:"parent_id"`
+	Slug      *string    `db:"slug" validate:"required,max=64"`
+	Name      *string    `db:"name" validate:"required,max=255"`
+	Position  *int       `db:"position"`
+	Visible   *bool      `db:"visible"`
+	Discount  *float64   `db:"discount"`
+	Icon      *[]byte    `db:"icon"`
+	Version   *int       `db:"version,version"`
+	CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+	UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
+}
+
+type CategoryCondition struct {
+	ID              *int64     `db:"id"`
+	IDs             *[]int64   `db:"id,in"`
+	ParentID        *int64     `db:"parent_id"`
+	ParentIDs       *[]int64   `db:"parent_id,in"`
+	Slug            *string    `db:"slug"`
+	Slugs           *[]string  `db:"slug,in"`
+	Name            *string    `db:"name"`
+	Position        *int       `db:"position"`
+	Visible         *bool      `db:"visible"`
+	Discount        *float64   `db:"discount"`
+	Version         *int       `db:"version"`
+	CreatedAtBefore *time.Time `db:"created_at,lte"`
+	CreatedAtAfter  *time.Time `db:"created_at,gte"`
+	UpdatedAtBefore *time.Time `db:"updated_at,lte"`
+	UpdatedAtAfter  *time.Time `db:"updated_at,gte"`
+}
+
+type CategoryRepo interface {
+	Table() string
+	Columns() []string
+	Select(ctx context.Context, fields []string, condition *CategoryCondition, paginate *utils.Paginate) (utils.Result[CategoryModel], error)
+	Stream(ctx context.Context, fields []string, condition *CategoryCondition, paginate *utils.Paginate, fn func(CategoryModel) error) error
+	GetByID(ctx context.Context, id any) (CategoryModel, error)
+	FindOne(ctx context.Context, condition CategoryCondition) (CategoryModel, error)
+	Exists(ctx context.Context, condition CategoryCondition) (bool, error)
+	Create(ctx context.Context, payload CategoryPayload) (CategoryModel, error)
+	CreateBulk(ctx context.Context, payload []CategoryPayload) (ids []int64, err error)
+	Update(ctx context.Context, payload CategoryPayload, condition CategoryCondition, opts ...sql.QueryOption) error
+	UpdateBulk(ctx context.Context, payload []sql.Update[CategoryPayload, CategoryCondition]) (fails []sql.Update[CategoryPayload, CategoryCondition], err error)
+	Delete(ctx context.Context, condition CategoryCondition) error
+	HardDelete(ctx context.Context, condition CategoryCondition) error
+	Restore(ctx context.Context, condition CategoryCondition) error
+}
+
+type categoryRepo struct {
+	*sql.Repository[CategoryModel, CategoryPayload, CategoryCondition]
+}
+
+func NewCategorySQLRepo(db *sqlx.DB, opts ...sql.RepositoryOption) CategoryRepo {
+	return &categoryRepo{
+		Repository: sql.NewRepository[CategoryModel, CategoryPayload, CategoryCondition](db, CategoryTable, opts...),
+	}
+}
@@ -0,0 +1,70 @@
+// Code generated by bulk/cmd/gen from schema.sql. DO NOT EDIT.
+
+package example
+
+import (
+	"bulk/db/sql"
+	"bulk/utils"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryRepoConformance(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	statements, err := sql.BuildCreateTable(sql.SQLite, CategoryTable, CategoryModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range statements {
+		db.MustExec(statement)
+	}
+
+	r := NewCategorySQLRepo(db)
+	ctx := context.Background()
+	assert.Equal(t, []string{"id", "parent_id", "slug", "name", "position", "visible", "discount", "icon", "version", "created_at", "updated_at", "deleted_at"}, r.Columns())
+	assert.Equal(t, r.Columns(), CategoryCols.All())
+	parentID := int64(1)
+	slug := "a"
+	name := "a"
+	position := 1
+	visible := true
+	discount := 1.5
+	icon := []byte("a")
+	payload := CategoryPayload{ParentID: &parentID, Slug: &slug, Name: &name, Position: &position, Visible: &visible, Discount: &discount, Icon: &icon}
+
+	created, err := r.Create(ctx, payload)
+	assert.Nil(t, err)
+	if created.ID == nil {
+		t.Fatal("created row has no primary key")
+	}
+	found, err := r.GetByID(ctx, *created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, parentID, *found.ParentID)
+	assert.Equal(t, slug, *found.Slug)
+	assert.Equal(t, name, *found.Name)
+	assert.Equal(t, position, *found.Position)
+	assert.Equal(t, visible, *found.Visible)
+	assert.Equal(t, discount, *found.Discount)
+	assert.Equal(t, icon, *found.Icon)
+
+	exists, err := r.Exists(ctx, CategoryCondition{ID: created.ID, IDs: &[]int64{*created.ID}})
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	result, err := r.Select(ctx, CategoryCols.All(), &CategoryCondition{ID: created.ID}, &utils.Paginate{Sort: []utils.Sort{CategoryCols.ID.Asc()}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Total)
+
+	assert.Nil(t, r.Update(ctx, payload, CategoryCondition{ID: created.ID}))
+	assert.Nil(t, r.Delete(ctx, CategoryCondition{ID: created.ID}))
+	_, err = r.GetByID(ctx, *created.ID)
+	assert.ErrorIs(t, err, sql.ErrNotFound)
+}
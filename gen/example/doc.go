@@ -0,0 +1,6 @@
+// Package example holds the files gen writes for the categories table of
+// schema.sql, kept in the tree to show the output and run its conformance
+// test.
+package example
+
+//go:generate go run bulk/cmd/gen -ddl schema.sql -table categories
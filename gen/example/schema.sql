@@ -0,0 +1,17 @@
+-- Example schema for the generator, see doc.go.
+CREATE TABLE categories (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	parent_id BIGINT,
+	slug VARCHAR(64) NOT NULL UNIQUE,
+	name VARCHAR(255) NOT NULL,
+	position INT NOT NULL DEFAULT 0,
+	visible BOOLEAN NOT NULL DEFAULT TRUE,
+	discount DECIMAL(5,2),
+	icon BLOB,
+	version INT NOT NULL DEFAULT 1,
+	created_at DATETIME(6),
+	updated_at DATETIME(6),
+	deleted_at DATETIME(6),
+	KEY idx_categories_parent (parent_id, position)
+);
+CREATE INDEX idx_categories_visible ON categories (visible, parent_id);
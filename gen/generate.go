@@ -0,0 +1,482 @@
+package gen
+
+import (
+	"bulk/db/sql"
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type Config struct {
+	Package string
+	// Type prefixes the generated names, the singular of the table by default.
+	Type string
+	// Source names the schema in the generated header.
+	Source string
+}
+
+// File is the generated Go source of one table and its conformance test.
+type File struct {
+	Code []byte
+	Test []byte
+}
+
+type field struct {
+	Name   string
+	Type   string
+	Column string
+	Tag    string
+}
+
+type sample struct {
+	Field  string
+	Var    string
+	Value  string
+	Assert string
+}
+
+type data struct {
+	Config
+	Table       string
+	Model       []field
+	Payload     []field
+	Condition   []field
+	Unexpressed []string
+	Repo        string
+	PK          field
+	PKs         string
+	AutoPK      bool
+	Columns     string
+	Samples     []sample
+	CodeImports []string
+	TestImports []string
+}
+
+// Generate renders the model, its typed column handles, payload, condition
+// and repo of table, see ModelTags for the model, with a conformance test running the repo against
+// SQLite. Conditions have an equality filter per column, a slice filter for
+// the primary key, unique and leading index columns and before and after
+// bounds for time columns.
+func Generate(table sql.TableDef, config Config) (File, error) {
+	if len(table.PrimaryKey) != 1 {
+		return File{}, fmt.Errorf("table %s needs a single column primary key, has %d", table.Name, len(table.PrimaryKey))
+	}
+	if config.Package == "" {
+		return File{}, fmt.Errorf("package required")
+	}
+	if config.Type == "" {
+		parts := strings.Split(table.Name, "_")
+		parts[len(parts)-1] = singular(parts[len(parts)-1])
+		config.Type = goName(strings.Join(parts, "_"))
+	}
+	d := data{Config: config, Table: table.Name, Repo: unexported(config.Type) + "Repo"}
+
+	tags, unexpressed, err := ModelTags(table)
+	if err != nil {
+		return File{}, err
+	}
+	d.Unexpressed = unexpressed
+	indexed := map[string]bool{table.PrimaryKey[0]: true}
+	for _, index := range table.Indexes {
+		indexed[index.Columns[0]] = true
+	}
+	columns := []string{}
+	usesTime := false
+	for _, column := range table.Columns {
+		goType := fieldType(column.Type)
+		usesTime = usesTime || goType == "time.Time"
+		name := goName(column.Name)
+		f := field{Name: name, Type: "*" + goType, Column: column.Name, Tag: tags[column.Name]}
+		d.Model = append(d.Model, f)
+		columns = append(columns, strconv.Quote(column.Name))
+		behaviour := behaviourOf(column, goType)
+		if column.PrimaryKey {
+			d.PK, d.AutoPK = f, column.AutoIncrement
+		}
+
+		if !(column.PrimaryKey && column.AutoIncrement) && behaviour != "softdelete" {
+			tag := quoteTag("db", strings.TrimSuffix(column.Name+","+behaviour, ","))
+			if validate := validation(column, goType, behaviour); validate != "" {
+				tag += " " + quoteTag("validate", validate)
+			}
+			d.Payload = append(d.Payload, field{Name: name, Type: f.Type, Column: column.Name, Tag: tag})
+			if behaviour == "" {
+				d.Samples = append(d.Samples, sampleOf(name, goType))
+			}
+		}
+
+		switch {
+		case behaviour == "softdelete" || goType == "[]byte":
+		case goType == "time.Time" && !column.PrimaryKey:
+			d.Condition = append(d.Condition,
+				field{Name: name + "Before", Type: f.Type, Tag: quoteTag("db", column.Name+",lte")},
+				field{Name: name + "After", Type: f.Type, Tag: quoteTag("db", column.Name+",gte")},
+			)
+		default:
+			d.Condition = append(d.Condition, field{Name: name, Type: f.Type, Tag: quoteTag("db", column.Name)})
+			if (indexed[column.Name] || column.Unique) && goType != "bool" {
+				d.Condition = append(d.Condition, field{Name: plural(name), Type: "*[]" + goType, Tag: quoteTag("db", column.Name+",in")})
+			}
+		}
+	}
+	d.PKs = plural(d.PK.Name)
+	d.Columns = strings.Join(columns, ", ")
+
+	d.CodeImports = []string{`"bulk/db/sql"`, `"bulk/utils"`, `"context"`}
+	d.TestImports = []string{`"bulk/db/sql"`, `"bulk/utils"`, `"context"`, `"path/filepath"`, `"testing"`}
+	if usesTime {
+		d.CodeImports = append(d.CodeImports, `"time"`)
+	}
+	for _, s := range d.Samples {
+		if strings.HasPrefix(s.Value, "time.") {
+			d.TestImports = append(d.TestImports, `"time"`)
+			break
+		}
+	}
+
+	code, err := render(codeTemplate, d)
+	if err != nil {
+		return File{}, err
+	}
+	test, err := render(testTemplate, d)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Code: code, Test: test}, nil
+}
+
+// ModelTags renders the db tag of every column of table, keyed by column, in
+// the options of sql.DescribeTable. A column carries one unique and one
+// plain index option, the indexes which do not fit are listed in unexpressed.
+func ModelTags(table sql.TableDef) (tags map[string]string, unexpressed []string, err error) {
+	options := map[string][]string{}
+	taken := map[string]bool{}
+	for _, index := range table.Indexes {
+		key := "index"
+		if index.Unique {
+			key = "unique"
+		}
+		fits := true
+		for _, column := range index.Columns {
+			c, ok := table.Column(column)
+			if !ok {
+				return nil, nil, fmt.Errorf("index %s on unknown column %s", index.Name, column)
+			}
+			if taken[key+" "+column] || (index.Unique && c.Unique) {
+				fits = false
+			}
+		}
+		if !fits {
+			unexpressed = append(unexpressed, describe(index))
+			continue
+		}
+		for i, column := range index.Columns {
+			taken[key+" "+column] = true
+			value := index.Name
+			if outOfOrder(table, index) {
+				value += ":" + strconv.Itoa(i+1)
+			}
+			options[column] = append(options[column], key+"="+value)
+		}
+	}
+
+	tags = map[string]string{}
+	for _, column := range table.Columns {
+		if strings.Contains(column.Type, "`") || (column.Default != nil && strings.Contains(*column.Default, "`")) {
+			return nil, nil, fmt.Errorf("column %s: backquotes do not fit a struct tag", column.Name)
+		}
+		parts := []string{column.Name}
+		if column.PrimaryKey {
+			parts = append(parts, "pk")
+		}
+		if column.AutoIncrement {
+			parts = append(parts, "autoincrement")
+		}
+		if behaviour := behaviourOf(column, fieldType(column.Type)); behaviour != "" {
+			parts = append(parts, behaviour)
+		}
+		if !inferred(column.Type) {
+			parts = append(parts, "type="+column.Type)
+		}
+		if column.NotNull && !column.PrimaryKey {
+			parts = append(parts, "notnull")
+		}
+		if column.Default != nil {
+			parts = append(parts, "default="+*column.Default)
+		}
+		if column.Unique {
+			parts = append(parts, "unique")
+		}
+		parts = append(parts, options[column.Name]...)
+		tags[column.Name] = quoteTag("db", strings.Join(parts, ","))
+	}
+	return tags, unexpressed, nil
+}
+
+// outOfOrder reports whether the columns of index differ from their order in
+// the table, which the tags then spell out with positions.
+func outOfOrder(table sql.TableDef, index sql.IndexDef) bool {
+	last := -1
+	for _, column := range index.Columns {
+		for i, c := range table.Columns {
+			if c.Name == column {
+				if i < last {
+					return true
+				}
+				last = i
+			}
+		}
+	}
+	return false
+}
+
+func describe(index sql.IndexDef) string {
+	kind := "index"
+	if index.Unique {
+		kind = "unique index"
+	}
+	return fmt.Sprintf("%s %s (%s)", kind, index.Name, strings.Join(index.Columns, ", "))
+}
+
+// behaviourOf maps the conventional column names of this repo to their
+// repository behaviour.
+func behaviourOf(column sql.ColumnDef, goType string) string {
+	switch {
+	case column.Name == "deleted_at" && goType == "time.Time":
+		return "softdelete"
+	case column.Name == "created_at" && goType == "time.Time":
+		return "autoCreateTime"
+	case column.Name == "updated_at" && goType == "time.Time":
+		return "autoUpdateTime"
+	case column.Name == "version" && strings.HasPrefix(goType, "int"):
+		return "version"
+	}
+	return ""
+}
+
+var length = regexp.MustCompile(`(?i)^(var)?char(acter)?( varying)?\s*\((\d+)\)`)
+
+func validation(column sql.ColumnDef, goType string, behaviour string) string {
+	rules := []string{}
+	if column.NotNull && column.Default == nil && behaviour == "" && goType != "bool" {
+		rules = append(rules, "required")
+	}
+	if match := length.FindStringSubmatch(column.Type); match != nil {
+		rules = append(rules, "max="+match[4])
+	}
+	return strings.Join(rules, ",")
+}
+
+var goTypes = map[string]reflect.Type{
+	"bool":      reflect.TypeOf(false),
+	"int":       reflect.TypeOf(0),
+	"int64":     reflect.TypeOf(int64(0)),
+	"float64":   reflect.TypeOf(0.0),
+	"string":    reflect.TypeOf(""),
+	"time.Time": reflect.TypeOf(time.Time{}),
+	"[]byte":    reflect.TypeOf([]byte{}),
+}
+
+// inferred reports whether sql.ColumnType infers sqlType from the Go type of
+// its field in some dialect, so the tag needs no type option. Leaving it out
+// lets the model build in any dialect: DATETIME(6) is MySQL only.
+func inferred(sqlType string) bool {
+	for _, dialect := range []sql.Dialect{sql.MySQL, sql.Postgres, sql.SQLite} {
+		typ, err := sql.ColumnType(dialect, goTypes[fieldType(sqlType)])
+		if err == nil && strings.EqualFold(typ, strings.TrimSpace(sqlType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldType maps a SQL type to the Go type of its field.
+func fieldType(sqlType string) string {
+	typ := strings.ToLower(strings.TrimSpace(sqlType))
+	base := typ
+	if i := strings.IndexAny(typ, "( "); i >= 0 {
+		base = typ[:i]
+	}
+	switch {
+	case base == "bool" || base == "boolean" || typ == "tinyint(1)" || typ == "bit(1)":
+		return "bool"
+	case base == "bigint" || base == "int8" || base == "bigserial":
+		return "int64"
+	case strings.Contains(base, "int") || base == "serial" || base == "smallserial":
+		return "int"
+	case base == "decimal" || base == "numeric" || base == "real" || base == "double" || base == "float" ||
+		base == "float4" || base == "float8" || base == "money":
+		return "float64"
+	case base == "date" || base == "datetime" || base == "timestamp" || base == "timestamptz":
+		return "time.Time"
+	case strings.Contains(base, "blob") || strings.Contains(base, "binary") || base == "bytea":
+		return "[]byte"
+	}
+	return "string"
+}
+
+func sampleOf(name string, goType string) sample {
+	s := sample{Field: name, Var: unexported(name)}
+	if s.Var == "type" || s.Var == "func" || s.Var == "range" || s.Var == "map" || s.Var == "string" {
+		s.Var += "Value"
+	}
+	s.Assert = fmt.Sprintf("assert.Equal(t, %s, *found.%s)", s.Var, name)
+	switch goType {
+	case "bool":
+		s.Value = "true"
+	case "int":
+		s.Value = "1"
+	case "int64":
+		s.Value = "int64(1)"
+	case "float64":
+		s.Value = "1.5"
+	case "time.Time":
+		s.Value = "time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)"
+		s.Assert = fmt.Sprintf("assert.True(t, %s.Equal(*found.%s))", s.Var, name)
+	case "[]byte":
+		s.Value = `[]byte("a")`
+	default:
+		s.Value = `"a"`
+	}
+	return s
+}
+
+func quoteTag(key string, value string) string {
+	return key + ":" + strconv.Quote(value)
+}
+
+func render(tmpl *template.Template, d data) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return nil, fmt.Errorf("failed render %s: %w", tmpl.Name(), err)
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed format %s: %w", tmpl.Name(), err)
+	}
+	return source, nil
+}
+
+var codeTemplate = template.Must(template.New("code").Parse(`// Code generated by bulk/cmd/gen{{if .Source}} from {{.Source}}{{end}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .CodeImports}}	{{.}}
+{{end}}
+	"github.com/jmoiron/sqlx"
+)
+
+const {{.Type}}Table = "{{.Table}}"
+
+{{range .Unexpressed}}// The {{.}} does not fit the model tags.
+{{end}}type {{.Type}}Model struct {
+{{range .Model}}	{{.Name}} {{.Type}} ` + "`{{.Tag}}`" + `
+{{end}}}
+
+// {{.Type}}Columns are the typed column handles of {{.Type}}Model, see
+// sql.ColumnsOf.
+type {{.Type}}Columns struct {
+	sql.ColumnSet
+{{range .Model}}	{{.Name}} sql.Column
+{{end}}}
+
+var {{.Type}}Cols = sql.MustColumnsOf[{{.Type}}Columns, {{.Type}}Model]()
+
+type {{.Type}}Payload struct {
+{{range .Payload}}	{{.Name}} {{.Type}} ` + "`{{.Tag}}`" + `
+{{end}}}
+
+type {{.Type}}Condition struct {
+{{range .Condition}}	{{.Name}} {{.Type}} ` + "`{{.Tag}}`" + `
+{{end}}}
+
+type {{.Type}}Repo interface {
+	Table() string
+	Columns() []string
+	Select(ctx context.Context, fields []string, condition *{{.Type}}Condition, paginate *utils.Paginate) (utils.Result[{{.Type}}Model], error)
+	Stream(ctx context.Context, fields []string, condition *{{.Type}}Condition, paginate *utils.Paginate, fn func({{.Type}}Model) error) error
+	GetByID(ctx context.Context, id any) ({{.Type}}Model, error)
+	FindOne(ctx context.Context, condition {{.Type}}Condition) ({{.Type}}Model, error)
+	Exists(ctx context.Context, condition {{.Type}}Condition) (bool, error)
+	Create(ctx context.Context, payload {{.Type}}Payload) ({{.Type}}Model, error)
+	CreateBulk(ctx context.Context, payload []{{.Type}}Payload) (ids []int64, err error)
+	Update(ctx context.Context, payload {{.Type}}Payload, condition {{.Type}}Condition, opts ...sql.QueryOption) error
+	UpdateBulk(ctx context.Context, payload []sql.Update[{{.Type}}Payload, {{.Type}}Condition]) (fails []sql.Update[{{.Type}}Payload, {{.Type}}Condition], err error)
+	Delete(ctx context.Context, condition {{.Type}}Condition) error
+	HardDelete(ctx context.Context, condition {{.Type}}Condition) error
+	Restore(ctx context.Context, condition {{.Type}}Condition) error
+}
+
+type {{.Repo}} struct {
+	*sql.Repository[{{.Type}}Model, {{.Type}}Payload, {{.Type}}Condition]
+}
+
+func New{{.Type}}SQLRepo(db *sqlx.DB, opts ...sql.RepositoryOption) {{.Type}}Repo {
+	return &{{.Repo}}{
+		Repository: sql.NewRepository[{{.Type}}Model, {{.Type}}Payload, {{.Type}}Condition](db, {{.Type}}Table, opts...),
+	}
+}
+`))
+
+var testTemplate = template.Must(template.New("test").Parse(`// Code generated by bulk/cmd/gen{{if .Source}} from {{.Source}}{{end}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .TestImports}}	{{.}}
+{{end}}
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test{{.Type}}RepoConformance(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	statements, err := sql.BuildCreateTable(sql.SQLite, {{.Type}}Table, {{.Type}}Model{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range statements {
+		db.MustExec(statement)
+	}
+
+	r := New{{.Type}}SQLRepo(db)
+	ctx := context.Background()
+	assert.Equal(t, []string{ {{.Columns}} }, r.Columns())
+	assert.Equal(t, r.Columns(), {{.Type}}Cols.All())
+{{range .Samples}}	{{.Var}} := {{.Value}}
+{{end}}	payload := {{.Type}}Payload{ {{range .Samples}}{{.Field}}: &{{.Var}}, {{end}} }
+
+	created, err := r.Create(ctx, payload)
+	assert.Nil(t, err)
+	if created.{{.PK.Name}} == nil {
+		t.Fatal("created row has no primary key")
+	}
+	found, err := r.GetByID(ctx, *created.{{.PK.Name}})
+	assert.Nil(t, err)
+{{range .Samples}}	{{.Assert}}
+{{end}}
+	exists, err := r.Exists(ctx, {{.Type}}Condition{ {{.PK.Name}}: created.{{.PK.Name}}, {{.PKs}}: &[]{{slice .PK.Type 1}}{*created.{{.PK.Name}}} })
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	result, err := r.Select(ctx, {{.Type}}Cols.All(), &{{.Type}}Condition{ {{.PK.Name}}: created.{{.PK.Name}} }, &utils.Paginate{Sort: []utils.Sort{ {{.Type}}Cols.{{.PK.Name}}.Asc() }})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Total)
+
+	assert.Nil(t, r.Update(ctx, payload, {{.Type}}Condition{ {{.PK.Name}}: created.{{.PK.Name}} }))
+	assert.Nil(t, r.Delete(ctx, {{.Type}}Condition{ {{.PK.Name}}: created.{{.PK.Name}} }))
+	_, err = r.GetByID(ctx, *created.{{.PK.Name}})
+	assert.ErrorIs(t, err, sql.ErrNotFound)
+}
+`))
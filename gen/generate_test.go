@@ -0,0 +1,115 @@
+package gen
+
+import (
+	"bulk/db/sql"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaming(t *testing.T) {
+	testCases := []struct {
+		Column   string
+		Name     string
+		Plural   string
+		Singular string
+	}{
+		{Column: "id", Name: "ID", Plural: "IDs", Singular: "id"},
+		{Column: "sku", Name: "SKU", Plural: "SKUs", Singular: "sku"},
+		{Column: "parent_id", Name: "ParentID", Plural: "ParentIDs", Singular: "parent_id"},
+		{Column: "status", Name: "Status", Plural: "Statuses", Singular: "status"},
+		{Column: "categories", Name: "Categories", Singular: "category"},
+		{Column: "boxes", Name: "Boxes", Singular: "box"},
+		{Column: "statuses", Name: "Statuses", Singular: "status"},
+		{Column: "houses", Name: "Houses", Singular: "house"},
+		{Column: "cases", Name: "Cases", Singular: "case"},
+		{Column: "key", Name: "Key", Plural: "Keys", Singular: "key"},
+		{Column: "api_url", Name: "APIURL", Plural: "APIURLs", Singular: "api_url"},
+		{Column: "addresses", Name: "Addresses", Singular: "address"},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			assert.Equal(t, tc.Name, goName(tc.Column))
+			if tc.Plural != "" {
+				assert.Equal(t, tc.Plural, plural(tc.Name))
+			}
+			assert.Equal(t, tc.Singular, singular(tc.Column))
+		})
+	}
+	assert.Equal(t, "skuCode", unexported("SKUCode"))
+	assert.Equal(t, "id", unexported("ID"))
+	assert.Equal(t, "category", unexported("Category"))
+}
+
+func TestModelTags(t *testing.T) {
+	tables, err := ParseDDL(`CREATE TABLE items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	a VARCHAR(16) NOT NULL DEFAULT 'x,y',
+	b DATETIME,
+	c INTEGER,
+	UNIQUE KEY uq_items_c_a (c, a),
+	KEY idx_items_b (b),
+	KEY idx_items_bc (b, c)
+)`)
+	assert.Nil(t, err)
+	tags, unexpressed, err := ModelTags(tables[0])
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"id": `db:"id,pk,autoincrement"`,
+		"a":  `db:"a,type=VARCHAR(16),notnull,default='x,y',unique=uq_items_c_a:2"`,
+		"b":  `db:"b,index=idx_items_b"`,
+		"c":  `db:"c,unique=uq_items_c_a:1"`,
+	}, tags)
+	assert.Equal(t, []string{"index idx_items_bc (b, c)"}, unexpressed)
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("example is up to date", func(t *testing.T) {
+		script, err := os.ReadFile(filepath.Join("example", "schema.sql"))
+		assert.Nil(t, err)
+		tables, err := ParseDDL(string(script))
+		assert.Nil(t, err)
+		file, err := Generate(tables[0], Config{Package: "example", Source: "schema.sql"})
+		assert.Nil(t, err)
+		code, err := os.ReadFile(filepath.Join("example", "categories_gen.go"))
+		assert.Nil(t, err)
+		test, err := os.ReadFile(filepath.Join("example", "categories_gen_test.go"))
+		assert.Nil(t, err)
+		assert.Equal(t, string(code), string(file.Code), "run go generate ./gen/example")
+		assert.Equal(t, string(test), string(file.Test), "run go generate ./gen/example")
+	})
+
+	t.Run("from a live sqlite database", func(t *testing.T) {
+		db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+		assert.Nil(t, err)
+		defer db.Close()
+		db.MustExec("CREATE TABLE tags (id INTEGER PRIMARY KEY, label TEXT NOT NULL UNIQUE, weight REAL)")
+		tables, err := Inspect(context.Background(), db, "tags")
+		assert.Nil(t, err)
+		assert.Equal(t, []sql.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true, AutoIncrement: true},
+			{Name: "label", Type: "TEXT", NotNull: true, Unique: true},
+			{Name: "weight", Type: "REAL"},
+		}, tables[0].Columns)
+		assert.Empty(t, tables[0].Indexes)
+		file, err := Generate(tables[0], Config{Package: "tags", Type: "Tag"})
+		assert.Nil(t, err)
+		assert.Contains(t, string(file.Code), "Labels *[]string `db:\"label,in\"`")
+		assert.Contains(t, string(file.Code), "func NewTagSQLRepo(")
+	})
+
+	t.Run("needs a single primary key and a package", func(t *testing.T) {
+		tables, err := ParseDDL("CREATE TABLE pairs (a INTEGER, b INTEGER, PRIMARY KEY (a, b)); CREATE TABLE one (id INTEGER PRIMARY KEY)")
+		assert.Nil(t, err)
+		_, err = Generate(tables[0], Config{Package: "pairs"})
+		assert.NotNil(t, err)
+		_, err = Generate(tables[1], Config{})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,68 @@
+package gen
+
+import "strings"
+
+var initialisms = map[string]bool{
+	"id": true, "ids": true, "sku": true, "url": true, "uuid": true, "api": true, "json": true,
+	"http": true, "ip": true, "sql": true, "html": true, "uri": true,
+}
+
+// goName turns a snake_case identifier into an exported Go name, keeping
+// initialisms upper case: sku_id becomes SKUID.
+func goName(name string) string {
+	b := strings.Builder{}
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		lower := strings.ToLower(part)
+		switch {
+		case lower == "ids":
+			b.WriteString("IDs")
+		case initialisms[lower]:
+			b.WriteString(strings.ToUpper(lower))
+		default:
+			b.WriteString(strings.ToUpper(lower[:1]) + lower[1:])
+		}
+	}
+	return b.String()
+}
+
+// unexported lower cases the leading word of an exported Go name.
+func unexported(name string) string {
+	i := 1
+	for i < len(name) && strings.ToUpper(name[i:i+1]) == name[i:i+1] && (i+1 == len(name) || strings.ToUpper(name[i+1:i+2]) == name[i+1:i+2]) {
+		i++
+	}
+	return strings.ToLower(name[:i]) + name[i:]
+}
+
+func plural(name string) string {
+	if strings.HasSuffix(name, "ID") || strings.HasSuffix(name, "SKU") {
+		return name + "s"
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsAny(lower[len(lower)-2:len(lower)-1], "aeiou"):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
+
+func singular(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "ies"):
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "uses") && !strings.HasSuffix(lower, "ouses"),
+		strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(lower, "ss"), strings.HasSuffix(lower, "us"):
+		return name
+	case strings.HasSuffix(lower, "s"):
+		return name[:len(name)-1]
+	}
+	return name
+}
@@ -0,0 +1,55 @@
+package gen
+
+import (
+	"bulk/db/sql"
+	"bulk/schema"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Inspect reads tables from a live SQLite database, for generating offline
+// without the DDL at hand. The INTEGER PRIMARY KEY column aliases the rowid
+// and is reported as autoincrement; the indexes SQLite creates for UNIQUE
+// constraints become unique columns or named unique indexes.
+func Inspect(ctx context.Context, db *sqlx.DB, tables ...string) ([]sql.TableDef, error) {
+	if sql.DialectOf(db.DriverName()) != sql.SQLite {
+		return nil, fmt.Errorf("inspect needs a sqlite database, got %s", db.DriverName())
+	}
+	defs := []sql.TableDef{}
+	for _, table := range tables {
+		def, err := schema.Inspect(ctx, db, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(def.PrimaryKey) == 1 {
+			for i := range def.Columns {
+				if def.Columns[i].PrimaryKey && strings.EqualFold(def.Columns[i].Type, "INTEGER") {
+					def.Columns[i].AutoIncrement = true
+				}
+			}
+		}
+		indexes := []sql.IndexDef{}
+		for _, index := range def.Indexes {
+			if !strings.HasPrefix(index.Name, "sqlite_autoindex_") {
+				indexes = append(indexes, index)
+				continue
+			}
+			if len(index.Columns) == 1 {
+				for i := range def.Columns {
+					if def.Columns[i].Name == index.Columns[0] {
+						def.Columns[i].Unique = true
+					}
+				}
+				continue
+			}
+			index.Name = "uq_" + table + "_" + strings.Join(index.Columns, "_")
+			indexes = append(indexes, index)
+		}
+		def.Indexes = indexes
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
@@ -12,6 +12,8 @@ require (
 
 require (
 	github.com/google/uuid v1.3.0
-	github.com/jmoiron/sqlx v1.3.5 // indirect
+	github.com/jmoiron/sqlx v1.3.5
+	github.com/lib/pq v1.2.0
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/stretchr/testify v1.8.1
 )
@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"bulk/db/sql"
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations
+var builtin embed.FS
+
+// Builtin returns the migrations creating the tables of this module for
+// dialect. A table that already exists, e.g. one created before migrations
+// were used, first gets the columns it lacks added.
+func Builtin(dialect sql.Dialect) ([]Migration, error) {
+	return load(builtin, path.Join("migrations", string(dialect)), upgradeScript)
+}
+
+func upgradeScript(script string) func(ctx context.Context, tx *sqlx.Tx) error {
+	run := execScript(script)
+	return func(ctx context.Context, tx *sqlx.Tx) error {
+		for _, statement := range splitStatements(script) {
+			table, columns, ok := parseCreateTable(statement)
+			if !ok {
+				continue
+			}
+			if err := addMissingColumns(ctx, tx, table, columns); err != nil {
+				return err
+			}
+		}
+		return run(ctx, tx)
+	}
+}
+
+var createTable = regexp.MustCompile(`(?is)^CREATE TABLE IF NOT EXISTS (\w+) \((.*)\)$`)
+
+// columnDef is a column of a CREATE TABLE script, definition being the text
+// after its name.
+type columnDef struct {
+	name       string
+	definition string
+}
+
+func parseCreateTable(statement string) (string, []columnDef, bool) {
+	match := createTable.FindStringSubmatch(statement)
+	if match == nil {
+		return "", nil, false
+	}
+	columns := []columnDef{}
+	for _, item := range splitTopLevel(match[2]) {
+		fields := strings.Fields(item)
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PRIMARY", "UNIQUE", "KEY", "INDEX", "CONSTRAINT", "FOREIGN", "CHECK":
+			continue
+		}
+		columns = append(columns, columnDef{name: fields[0], definition: strings.Join(fields, " ")})
+	}
+	return match[1], columns, true
+}
+
+func splitTopLevel(body string) []string {
+	items := []string{}
+	depth, start := 0, 0
+	for i, r := range body {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, body[start:])
+}
+
+// addMissingColumns adds the columns of the script that an existing table
+// lacks. A table that does not exist yet is left to the script.
+func addMissingColumns(ctx context.Context, tx *sqlx.Tx, table string, columns []columnDef) error {
+	existing, err := tableColumns(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+	for _, column := range columns {
+		if existing[strings.ToLower(column.name)] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, column.definition)); err != nil {
+			return fmt.Errorf("failed add column %s to existing table %s: %w", column.name, table, err)
+		}
+	}
+	return nil
+}
+
+func tableColumns(ctx context.Context, tx *sqlx.Tx, table string) (map[string]bool, error) {
+	query := "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+	switch sql.DialectOf(tx.DriverName()) {
+	case sql.Postgres:
+		query = "SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?"
+	case sql.SQLite:
+		query = "SELECT name FROM pragma_table_info(?)"
+	}
+	names := []string{}
+	if err := tx.SelectContext(ctx, &names, tx.Rebind(query), table); err != nil {
+		return nil, fmt.Errorf("failed read columns of %s: %w", table, err)
+	}
+	columns := map[string]bool{}
+	for _, name := range names {
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, nil
+}
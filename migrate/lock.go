@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"bulk/db/sql"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// lock takes the migration lock of the history table, waiting up to timeout.
+// MySQL and Postgres hold an advisory lock on a dedicated connection, which
+// the server releases if the process dies. SQLite has none, a row in
+// <table>_lock stands in; a crashed run leaves it behind, see ForceUnlock.
+func (m *Migrator) lock(ctx context.Context) (unlock func() error, err error) {
+	switch m.dialect {
+	case sql.MySQL:
+		conn, err := m.db.Connx(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed get connection: %w", err)
+		}
+		acquired := 0
+		if err := conn.GetContext(ctx, &acquired, "SELECT COALESCE(GET_LOCK(?, ?), 0)", m.table, int(m.lockTimeout.Seconds())); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed get lock: %w", err)
+		}
+		if acquired != 1 {
+			conn.Close()
+			return nil, fmt.Errorf("%s: %w", m.table, ErrLocked)
+		}
+		return func() error {
+			defer conn.Close()
+			_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", m.table)
+			return err
+		}, nil
+	case sql.Postgres:
+		conn, err := m.db.Connx(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed get connection: %w", err)
+		}
+		key := lockKey(m.table)
+		if err := m.waitLock(ctx, func() (bool, error) {
+			acquired := false
+			err := conn.GetContext(ctx, &acquired, "SELECT pg_try_advisory_lock($1)", key)
+			return acquired, err
+		}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return func() error {
+			defer conn.Close()
+			_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+			return err
+		}, nil
+	}
+
+	table := m.table + "_lock"
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id INTEGER PRIMARY KEY, locked_at TIMESTAMP NOT NULL)", table)); err != nil {
+		return nil, fmt.Errorf("failed create lock table: %w", err)
+	}
+	err = m.waitLock(ctx, func() (bool, error) {
+		_, err := m.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, locked_at) VALUES (1, ?)", table), time.Now().UTC())
+		if errors.Is(sql.TranslateError(err), sql.ErrDuplicateKey) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := m.db.ExecContext(context.Background(), fmt.Sprintf("DELETE FROM %s WHERE id = 1", table))
+		return err
+	}, nil
+}
+
+// waitLock polls try until it acquires the lock or the lock timeout passes.
+func (m *Migrator) waitLock(ctx context.Context, try func() (bool, error)) error {
+	deadline := time.Now().Add(m.lockTimeout)
+	for {
+		acquired, err := try()
+		if err != nil {
+			return fmt.Errorf("failed get lock: %w", err)
+		}
+		if acquired {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s: %w", m.table, ErrLocked)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// ForceUnlock clears a SQLite migration lock left by a crashed run. Advisory
+// locks of the other dialects need no clearing.
+func (m *Migrator) ForceUnlock(ctx context.Context) error {
+	if m.dialect != sql.SQLite {
+		return nil
+	}
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s_lock", m.table)); err != nil {
+		return fmt.Errorf("failed clear lock: %w", err)
+	}
+	return nil
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
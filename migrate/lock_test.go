@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"context"
+	stdsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePostgres answers the statements the migrator sends to a postgres
+// server: advisory locks and the history table.
+type fakePostgres struct {
+	mu         sync.Mutex
+	statements []string
+	lockArgs   []driver.Value
+	heldByPeer bool
+	history    [][]driver.Value
+}
+
+func (f *fakePostgres) Connect(ctx context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakePostgres) Driver() driver.Driver                            { return fakeDriver{f} }
+
+type fakeDriver struct{ db *fakePostgres }
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{d.db}, nil }
+
+type fakeConn struct{ db *fakePostgres }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, query)
+	if strings.HasPrefix(query, "INSERT INTO schema_migrations") {
+		row := []driver.Value{}
+		for _, arg := range args {
+			row = append(row, arg.Value)
+		}
+		c.db.history = append(c.db.history, row)
+	}
+	if strings.Contains(query, "pg_advisory_unlock") {
+		c.db.lockArgs = append(c.db.lockArgs, args[0].Value)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, query)
+	switch {
+	case strings.Contains(query, "pg_try_advisory_lock"):
+		c.db.lockArgs = append(c.db.lockArgs, args[0].Value)
+		return &fakeRows{columns: []string{"pg_try_advisory_lock"}, rows: [][]driver.Value{{!c.db.heldByPeer}}}, nil
+	case strings.HasPrefix(query, "SELECT version, name, checksum, applied_at FROM"):
+		return &fakeRows{columns: []string{"version", "name", "checksum", "applied_at"}, rows: append([][]driver.Value{}, c.db.history...)}, nil
+	}
+	return nil, errors.New("unexpected query " + query)
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestPostgresLock(t *testing.T) {
+	ctx := context.Background()
+	migrations := []Migration{SQL(1, "create_a", "CREATE TABLE a (id BIGSERIAL PRIMARY KEY);", "DROP TABLE a;")}
+
+	t.Run("up holds the advisory lock", func(t *testing.T) {
+		fake := &fakePostgres{}
+		db := sqlx.NewDb(stdsql.OpenDB(fake), "postgres")
+		m, err := New(db, migrations)
+		assert.Nil(t, err)
+		done, err := m.Up(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, done, 1)
+
+		assert.Contains(t, fake.statements, "SELECT pg_try_advisory_lock($1)")
+		assert.Contains(t, fake.statements, "CREATE TABLE a (id BIGSERIAL PRIMARY KEY)")
+		assert.Contains(t, fake.statements, "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)")
+		assert.Equal(t, "SELECT pg_advisory_unlock($1)", fake.statements[len(fake.statements)-1])
+		assert.Equal(t, []driver.Value{lockKey(DefaultTable), lockKey(DefaultTable)}, fake.lockArgs)
+		assert.Len(t, fake.history, 1)
+	})
+
+	t.Run("lock held by another run", func(t *testing.T) {
+		fake := &fakePostgres{heldByPeer: true}
+		db := sqlx.NewDb(stdsql.OpenDB(fake), "postgres")
+		m, err := New(db, migrations, WithLockTimeout(100*time.Millisecond))
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.True(t, errors.Is(err, ErrLocked))
+		assert.Empty(t, fake.history)
+		assert.NotContains(t, fake.statements, "SELECT pg_advisory_unlock($1)")
+	})
+}
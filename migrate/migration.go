@@ -0,0 +1,157 @@
+package migrate
+
+import (
+	"bulk/db/sql"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Migration moves the schema one version up or down. Up and Down run in a
+// transaction; MySQL commits DDL implicitly, so a failing MySQL migration may
+// be left half applied.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, tx *sqlx.Tx) error
+	Down    func(ctx context.Context, tx *sqlx.Tx) error
+	// Checksum identifies the body of the migration, recorded when it is
+	// applied so later edits are detected. Empty skips the check.
+	Checksum string
+}
+
+// SQL builds a migration running the statements of the up and down scripts.
+// Statements are separated by a semicolon ending a line. The checksum covers
+// both scripts.
+func SQL(version int64, name string, up string, down string) Migration {
+	sum := sha256.Sum256([]byte(up + "\x00" + down))
+	return Migration{
+		Version:  version,
+		Name:     name,
+		Up:       execScript(up),
+		Down:     execScript(down),
+		Checksum: hex.EncodeToString(sum[:]),
+	}
+}
+
+// CreateTable builds a migration creating table from the db tags of model,
+// see sql.BuildCreateTable, and dropping it on the way down.
+func CreateTable(dialect sql.Dialect, version int64, table string, model any) (Migration, error) {
+	statements, err := sql.BuildCreateTable(dialect, table, model)
+	if err != nil {
+		return Migration{}, err
+	}
+	return SQL(version, "create_"+table, strings.Join(statements, ";\n")+";", fmt.Sprintf("DROP TABLE %s;", table)), nil
+}
+
+func execScript(script string) func(ctx context.Context, tx *sqlx.Tx) error {
+	return func(ctx context.Context, tx *sqlx.Tx) error {
+		for _, statement := range splitStatements(script) {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed exec %q: %w", statement, err)
+			}
+		}
+		return nil
+	}
+}
+
+func splitStatements(script string) []string {
+	statements := []string{}
+	current := []string{}
+	flush := func() {
+		statement := strings.TrimSpace(strings.Join(current, "\n"))
+		statement = strings.TrimSpace(strings.TrimSuffix(statement, ";"))
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+		current = current[:0]
+	}
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	flush()
+	return statements
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads SQL migrations from the files of dir named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. A missing down
+// script makes the migration irreversible.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	return load(fsys, dir, execScript)
+}
+
+func load(fsys fs.FS, dir string, up func(script string) func(ctx context.Context, tx *sqlx.Tx) error) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed read migrations: %w", err)
+	}
+	type scripts struct {
+		name     string
+		up, down *string
+	}
+	found := map[int64]*scripts{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed read migration %s: %w", entry.Name(), err)
+		}
+		item, ok := found[version]
+		if !ok {
+			item = &scripts{name: match[2]}
+			found[version] = item
+		}
+		if item.name != match[2] {
+			return nil, fmt.Errorf("migration %d named both %s and %s: %w", version, item.name, match[2], ErrDuplicate)
+		}
+		text := string(content)
+		if match[3] == "up" {
+			item.up = &text
+		} else {
+			item.down = &text
+		}
+	}
+	migrations := make([]Migration, 0, len(found))
+	for version, item := range found {
+		if item.up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up script", version, item.name)
+		}
+		down := ""
+		if item.down != nil {
+			down = *item.down
+		}
+		migration := SQL(version, item.name, *item.up, down)
+		migration.Up = up(*item.up)
+		if item.down == nil {
+			migration.Down = nil
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
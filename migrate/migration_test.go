@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"bulk/db/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	testCases := []struct {
+		Script   string
+		Expected []string
+	}{
+		{Script: "", Expected: []string{}},
+		{Script: "CREATE TABLE a (id INT);", Expected: []string{"CREATE TABLE a (id INT)"}},
+		{Script: "CREATE TABLE a (\n\tid INT\n);\n-- comment\nCREATE INDEX i ON a (id);\n", Expected: []string{"CREATE TABLE a (\n\tid INT\n)", "CREATE INDEX i ON a (id)"}},
+		{Script: "INSERT INTO a VALUES ('x;y');\nDROP TABLE b", Expected: []string{"INSERT INTO a VALUES ('x;y')", "DROP TABLE b"}},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			assert.Equal(t, tc.Expected, splitStatements(tc.Script))
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		Files    fstest.MapFS
+		Versions []int64
+		Down     []bool
+		HasError bool
+	}{
+		{
+			Files: fstest.MapFS{
+				"m/0002_b.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+				"m/0001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+				"m/0001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+				"m/README.md":       {Data: []byte("ignored")},
+			},
+			Versions: []int64{1, 2},
+			Down:     []bool{true, false},
+		},
+		{
+			Files:    fstest.MapFS{"m/0001_a.down.sql": {Data: []byte("DROP TABLE a;")}},
+			HasError: true,
+		},
+		{
+			Files: fstest.MapFS{
+				"m/0001_a.up.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+				"m/0001_b.up.sql": {Data: []byte("CREATE TABLE b (id INT);")},
+			},
+			HasError: true,
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			migrations, err := Load(tc.Files, "m")
+			assert.Equal(t, tc.HasError, err != nil)
+			if tc.HasError {
+				return
+			}
+			versions, down := []int64{}, []bool{}
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+				down = append(down, migration.Down != nil)
+				assert.Len(t, migration.Checksum, 64)
+			}
+			assert.Equal(t, tc.Versions, versions)
+			assert.Equal(t, tc.Down, down)
+		})
+	}
+
+	t.Run("builtin", func(t *testing.T) {
+		for _, dialect := range []sql.Dialect{sql.MySQL, sql.Postgres, sql.SQLite} {
+			migrations, err := Builtin(dialect)
+			assert.Nil(t, err)
+			assert.Len(t, migrations, 4)
+			assert.Equal(t, "create_products", migrations[0].Name)
+		}
+		_, err := Builtin("oracle")
+		assert.NotNil(t, err)
+		assert.False(t, errors.Is(err, ErrDuplicate))
+	})
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS products (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	sku VARCHAR(64) NOT NULL,
+	name VARCHAR(255),
+	price DOUBLE,
+	qty INT NOT NULL DEFAULT 0,
+	version INT NOT NULL DEFAULT 1,
+	created_at DATETIME(6),
+	updated_at DATETIME(6),
+	deleted_at DATETIME(6),
+	UNIQUE KEY uq_products_sku (sku)
+);
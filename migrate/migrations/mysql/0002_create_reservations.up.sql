@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reservations (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	sku VARCHAR(64) NOT NULL,
+	qty INT NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	expires_at DATETIME(6) NOT NULL,
+	created_at DATETIME(6),
+	updated_at DATETIME(6),
+	KEY idx_reservations_status_expires (status, expires_at)
+);
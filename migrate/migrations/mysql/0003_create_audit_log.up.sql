@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	table_name VARCHAR(64) NOT NULL,
+	row_id VARCHAR(64) NOT NULL,
+	column_name VARCHAR(64) NOT NULL,
+	old_value TEXT,
+	new_value TEXT,
+	actor VARCHAR(255),
+	changed_at DATETIME(6) NOT NULL,
+	KEY idx_audit_log_row (table_name, row_id, column_name)
+);
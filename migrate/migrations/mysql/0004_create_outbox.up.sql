@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS outbox (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	event_type VARCHAR(128) NOT NULL,
+	event_key VARCHAR(255) NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT,
+	created_at DATETIME(6) NOT NULL,
+	sent_at DATETIME(6),
+	KEY idx_outbox_sent (sent_at, id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS products (
+	id BIGSERIAL PRIMARY KEY,
+	sku VARCHAR(64) NOT NULL UNIQUE,
+	name VARCHAR(255),
+	price DOUBLE PRECISION,
+	qty INTEGER NOT NULL DEFAULT 0,
+	version INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP
+);
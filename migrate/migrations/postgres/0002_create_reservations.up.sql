@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reservations (
+	id BIGSERIAL PRIMARY KEY,
+	sku VARCHAR(64) NOT NULL,
+	qty INTEGER NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_reservations_status_expires ON reservations (status, expires_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	table_name VARCHAR(64) NOT NULL,
+	row_id VARCHAR(64) NOT NULL,
+	column_name VARCHAR(64) NOT NULL,
+	old_value TEXT,
+	new_value TEXT,
+	actor VARCHAR(255),
+	changed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_row ON audit_log (table_name, row_id, column_name);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS products (
+	id INTEGER PRIMARY KEY,
+	sku TEXT NOT NULL UNIQUE,
+	name TEXT,
+	price REAL,
+	qty INTEGER NOT NULL DEFAULT 0,
+	version INTEGER NOT NULL DEFAULT 1,
+	created_at DATETIME,
+	updated_at DATETIME,
+	deleted_at DATETIME
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reservations (
+	id INTEGER PRIMARY KEY,
+	sku TEXT NOT NULL,
+	qty INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	expires_at DATETIME NOT NULL,
+	created_at DATETIME,
+	updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_reservations_status_expires ON reservations (status, expires_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY,
+	table_name TEXT NOT NULL,
+	row_id TEXT NOT NULL,
+	column_name TEXT NOT NULL,
+	old_value TEXT,
+	new_value TEXT,
+	actor TEXT,
+	changed_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_row ON audit_log (table_name, row_id, column_name);
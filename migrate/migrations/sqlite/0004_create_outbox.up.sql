@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS outbox (
+	id INTEGER PRIMARY KEY,
+	event_type TEXT NOT NULL,
+	event_key TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	created_at DATETIME NOT NULL,
+	sent_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_sent ON outbox (sent_at, id);
@@ -0,0 +1,275 @@
+package migrate
+
+import (
+	"bulk/db/sql"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultTable = "schema_migrations"
+
+var (
+	ErrDuplicate        = errors.New("duplicate migration version")
+	ErrLocked           = errors.New("migrations locked by another run")
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	ErrUnknownVersion   = errors.New("applied migration not found")
+	ErrIrreversible     = errors.New("migration has no down")
+)
+
+type Option func(*Migrator)
+
+// WithTable sets the history table, schema_migrations by default.
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithLockTimeout sets how long a run waits for another one to finish.
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockTimeout = timeout
+	}
+}
+
+// Status is a migration known to the migrator, applied to the database, or
+// both.
+type Status struct {
+	Version   int64      `json:"version" db:"version"`
+	Name      string     `json:"name" db:"name"`
+	Applied   bool       `json:"applied" db:"applied"`
+	AppliedAt *time.Time `json:"applied_at" db:"applied_at"`
+	// Modified marks an applied migration whose checksum changed since.
+	Modified bool `json:"modified" db:"modified"`
+	// Missing marks an applied version the migrator does not know.
+	Missing bool `json:"missing" db:"missing"`
+}
+
+type applied struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Migrator applies migrations in version order and records them in its
+// history table. Runs on several instances at once are serialized by a
+// database lock.
+type Migrator struct {
+	db          *sqlx.DB
+	dialect     sql.Dialect
+	migrations  []Migration
+	table       string
+	lockTimeout time.Duration
+}
+
+func New(db *sqlx.DB, migrations []Migration, opts ...Option) (*Migrator, error) {
+	m := &Migrator{
+		db:          db,
+		dialect:     sql.DialectOf(db.DriverName()),
+		migrations:  append([]Migration{}, migrations...),
+		table:       DefaultTable,
+		lockTimeout: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if !sql.IsIdentifier(m.table) {
+		return nil, &sql.InvalidFieldError{Field: m.table, Reason: "not a table name"}
+	}
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+	for i := 1; i < len(m.migrations); i++ {
+		if m.migrations[i].Version == m.migrations[i-1].Version {
+			return nil, fmt.Errorf("version %d: %w", m.migrations[i].Version, ErrDuplicate)
+		}
+	}
+	return m, nil
+}
+
+// Up applies every pending migration, oldest first, and returns the applied
+// ones. It refuses to run when an applied migration was modified or is
+// unknown.
+func (m *Migrator) Up(ctx context.Context) (done []Migration, err error) {
+	err = m.locked(ctx, func(history map[int64]applied) error {
+		if err := m.verify(history); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := history[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, migration, true); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns the rolled back ones.
+func (m *Migrator) Down(ctx context.Context, steps int) (done []Migration, err error) {
+	err = m.locked(ctx, func(history map[int64]applied) error {
+		versions := make([]int64, 0, len(history))
+		for version := range history {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+		for _, version := range versions {
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("version %d: %w", version, ErrUnknownVersion)
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("version %d: %w", version, ErrIrreversible)
+			}
+			if err := m.apply(ctx, migration, false); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists the known and applied migrations by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	history, err := m.history(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []Status{}
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := history[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied, status.AppliedAt = true, &appliedAt
+			status.Modified = modified(migration, row)
+		}
+		result = append(result, status)
+	}
+	for version, row := range history {
+		if _, ok := m.find(version); !ok {
+			appliedAt := row.AppliedAt
+			result = append(result, Status{Version: version, Name: row.Name, Applied: true, AppliedAt: &appliedAt, Missing: true})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// locked runs fn holding the migration lock, with the history read after
+// the lock was taken.
+func (m *Migrator) locked(ctx context.Context, fn func(history map[int64]applied) error) (err error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed release lock: %w", unlockErr)
+		}
+	}()
+	history, err := m.history(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(history)
+}
+
+func (m *Migrator) apply(ctx context.Context, migration Migration, up bool) error {
+	return sql.Transaction(ctx, m.db, func(ctx context.Context) error {
+		tx, _ := sql.TxFrom(ctx)
+		if !up {
+			if err := migration.Down(ctx, tx); err != nil {
+				return fmt.Errorf("failed roll back %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			_, err := tx.ExecContext(ctx, tx.Rebind(fmt.Sprintf("DELETE FROM %s WHERE version = ?", m.table)), migration.Version)
+			if err != nil {
+				return fmt.Errorf("failed delete history: %w", err)
+			}
+			return nil
+		}
+		if migration.Up == nil {
+			return fmt.Errorf("migration %d_%s has no up", migration.Version, migration.Name)
+		}
+		if err := migration.Up(ctx, tx); err != nil {
+			return fmt.Errorf("failed apply %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		_, err := tx.ExecContext(ctx, tx.Rebind(fmt.Sprintf("INSERT INTO %s (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)", m.table)),
+			migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("failed insert history: %w", err)
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) verify(history map[int64]applied) error {
+	for version, row := range history {
+		migration, ok := m.find(version)
+		if !ok {
+			return fmt.Errorf("version %d: %w", version, ErrUnknownVersion)
+		}
+		if modified(migration, row) {
+			return fmt.Errorf("version %d_%s: %w", version, migration.Name, ErrChecksumMismatch)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`, m.table)
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed create %s: %w", m.table, err)
+	}
+	return nil
+}
+
+func (m *Migrator) history(ctx context.Context) (map[int64]applied, error) {
+	rows := []applied{}
+	query := fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s", m.table)
+	if err := m.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, fmt.Errorf("failed read %s: %w", m.table, err)
+	}
+	history := map[int64]applied{}
+	for _, row := range rows {
+		history[row.Version] = row
+	}
+	return history, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+func modified(migration Migration, row applied) bool {
+	return migration.Checksum != "" && row.Checksum != "" && migration.Checksum != row.Checksum
+}
@@ -0,0 +1,255 @@
+package migrate
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sqlx.DB, table string) bool {
+	count := 0
+	if err := db.Get(&count, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	migrations := []Migration{
+		SQL(1, "create_a", "CREATE TABLE a (id INTEGER PRIMARY KEY);", "DROP TABLE a;"),
+		SQL(2, "create_b", "CREATE TABLE b (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_b ON b (id);", "DROP TABLE b;"),
+	}
+
+	t.Run("up, status and down", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := New(db, migrations)
+		assert.Nil(t, err)
+
+		done, err := m.Up(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, done, 2)
+		assert.True(t, tableExists(t, db, "a"))
+		assert.True(t, tableExists(t, db, "b"))
+
+		done, err = m.Up(ctx)
+		assert.Nil(t, err)
+		assert.Empty(t, done)
+
+		status, err := m.Status(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, status, 2)
+		assert.True(t, status[0].Applied)
+		assert.NotNil(t, status[1].AppliedAt)
+
+		done, err = m.Down(ctx, 1)
+		assert.Nil(t, err)
+		assert.Len(t, done, 1)
+		assert.Equal(t, int64(2), done[0].Version)
+		assert.False(t, tableExists(t, db, "b"))
+		assert.True(t, tableExists(t, db, "a"))
+
+		status, err = m.Status(ctx)
+		assert.Nil(t, err)
+		assert.True(t, status[0].Applied)
+		assert.False(t, status[1].Applied)
+
+		done, err = m.Down(ctx, 5)
+		assert.Nil(t, err)
+		assert.Len(t, done, 1)
+		assert.False(t, tableExists(t, db, "a"))
+	})
+
+	t.Run("failed migration rolls back", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := New(db, append(migrations, SQL(3, "broken", "CREATE TABLE c (id INTEGER);\nINSERT INTO missing VALUES (1);", "")))
+		assert.Nil(t, err)
+		done, err := m.Up(ctx)
+		assert.NotNil(t, err)
+		assert.Len(t, done, 2)
+		assert.False(t, tableExists(t, db, "c"))
+		status, err := m.Status(ctx)
+		assert.Nil(t, err)
+		assert.False(t, status[2].Applied)
+	})
+
+	t.Run("modified and unknown migrations", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := New(db, migrations)
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.Nil(t, err)
+
+		edited, err := New(db, []Migration{migrations[0], SQL(2, "create_b", "CREATE TABLE b (id INTEGER PRIMARY KEY, name TEXT);", "DROP TABLE b;")})
+		assert.Nil(t, err)
+		_, err = edited.Up(ctx)
+		assert.True(t, errors.Is(err, ErrChecksumMismatch))
+		status, err := edited.Status(ctx)
+		assert.Nil(t, err)
+		assert.True(t, status[1].Modified)
+
+		editedDown, err := New(db, []Migration{migrations[0], SQL(2, "create_b", "CREATE TABLE b (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_b ON b (id);", "DELETE FROM b;")})
+		assert.Nil(t, err)
+		_, err = editedDown.Up(ctx)
+		assert.True(t, errors.Is(err, ErrChecksumMismatch))
+
+		dropped, err := New(db, migrations[:1])
+		assert.Nil(t, err)
+		_, err = dropped.Up(ctx)
+		assert.True(t, errors.Is(err, ErrUnknownVersion))
+		status, err = dropped.Status(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, status, 2)
+		assert.True(t, status[1].Missing)
+	})
+
+	t.Run("duplicate versions", func(t *testing.T) {
+		_, err := New(newTestDB(t), append(migrations, migrations[0]))
+		assert.True(t, errors.Is(err, ErrDuplicate))
+	})
+
+	t.Run("irreversible", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := New(db, []Migration{{Version: 1, Name: "noop", Up: migrations[0].Up}})
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.Nil(t, err)
+		_, err = m.Down(ctx, 1)
+		assert.True(t, errors.Is(err, ErrIrreversible))
+	})
+
+	t.Run("concurrent runs apply once", func(t *testing.T) {
+		db := newTestDB(t)
+		var wg sync.WaitGroup
+		applied := make([]int, 4)
+		errs := make([]error, 4)
+		for i := range applied {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				m, err := New(db, migrations, WithLockTimeout(10*time.Second))
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				done, err := m.Up(ctx)
+				applied[i], errs[i] = len(done), err
+			}(i)
+		}
+		wg.Wait()
+		total := 0
+		for i := range applied {
+			assert.Nil(t, errs[i])
+			total += applied[i]
+		}
+		assert.Equal(t, 2, total)
+	})
+
+	t.Run("lock timeout", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := New(db, migrations, WithLockTimeout(100*time.Millisecond))
+		assert.Nil(t, err)
+		assert.Nil(t, m.ensureTable(ctx))
+		unlock, err := m.lock(ctx)
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.True(t, errors.Is(err, ErrLocked))
+		assert.Nil(t, unlock())
+
+		_, err = m.lock(ctx)
+		assert.Nil(t, err)
+		assert.Nil(t, m.ForceUnlock(ctx))
+		done, err := m.Up(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, done, 2)
+	})
+
+	t.Run("builtin", func(t *testing.T) {
+		db := newTestDB(t)
+		builtin, err := Builtin(sql.SQLite)
+		assert.Nil(t, err)
+		m, err := New(db, builtin)
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.Nil(t, err)
+
+		products := repo.NewProductSQLRepo(db)
+		sku, qty := "A", 1
+		product, err := products.Create(ctx, repo.ProductPayload{SKU: &sku, Qty: &qty})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, *product.Version)
+
+		done, err := m.Down(ctx, len(builtin))
+		assert.Nil(t, err)
+		assert.Len(t, done, len(builtin))
+		assert.False(t, tableExists(t, db, "products"))
+	})
+
+	t.Run("builtin over an existing schema", func(t *testing.T) {
+		db := newTestDB(t)
+		builtin, err := Builtin(sql.SQLite)
+		assert.Nil(t, err)
+		m, err := New(db, builtin)
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.Nil(t, err)
+
+		baseline, err := New(db, builtin, WithTable("baseline_migrations"))
+		assert.Nil(t, err)
+		done, err := baseline.Up(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, done, len(builtin))
+	})
+
+	t.Run("builtin adds columns to a legacy table", func(t *testing.T) {
+		db := newTestDB(t)
+		db.MustExec("CREATE TABLE products (id INTEGER PRIMARY KEY, sku TEXT NOT NULL UNIQUE, name TEXT, price REAL, qty INTEGER NOT NULL DEFAULT 0)")
+		db.MustExec("INSERT INTO products (sku, qty) VALUES ('A', 3)")
+		builtin, err := Builtin(sql.SQLite)
+		assert.Nil(t, err)
+		m, err := New(db, builtin)
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.Nil(t, err)
+
+		products := repo.NewProductSQLRepo(db)
+		sku := "A"
+		product, err := products.FindOne(ctx, repo.ProductCondition{SKU: &sku})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, *product.Qty)
+		assert.Equal(t, 1, *product.Version)
+	})
+
+	t.Run("create table from model", func(t *testing.T) {
+		db := newTestDB(t)
+		products, err := CreateTable(sql.SQLite, 1, repo.ProductTable, repo.ProductModel{})
+		assert.Nil(t, err)
+		assert.Equal(t, "create_products", products.Name)
+		m, err := New(db, []Migration{products})
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.Nil(t, err)
+		assert.True(t, tableExists(t, db, "products"))
+		_, err = m.Down(ctx, 1)
+		assert.Nil(t, err)
+		assert.False(t, tableExists(t, db, "products"))
+	})
+}
@@ -0,0 +1,148 @@
+package outbox
+
+import (
+	"bulk/db/sql"
+	"bulk/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const Table = "outbox"
+
+const unsent = "sent_at IS NULL"
+
+// EventModel is a row of the outbox table. sent_at stays NULL until a relay
+// delivers the event.
+type EventModel struct {
+	ID        *int64     `db:"id,pk,autoincrement,index=idx_outbox_sent:2"`
+	Type      *string    `db:"event_type,type=VARCHAR(128),notnull"`
+	Key       *string    `db:"event_key,type=VARCHAR(255),notnull"`
+	Payload   *string    `db:"payload,type=TEXT,notnull"`
+	Attempts  *int       `db:"attempts,type=INTEGER,notnull,default=0"`
+	LastError *string    `db:"last_error,type=TEXT"`
+	CreatedAt *time.Time `db:"created_at,notnull"`
+	SentAt    *time.Time `db:"sent_at,index=idx_outbox_sent:1"`
+}
+
+type EventPayload struct {
+	Type      *string    `db:"event_type"`
+	Key       *string    `db:"event_key"`
+	Payload   *string    `db:"payload"`
+	LastError *string    `db:"last_error"`
+	CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+}
+
+type EventCondition struct {
+	ID  *int64  `db:"id"`
+	Key *string `db:"event_key"`
+}
+
+// Message is an event as handed to a Publisher.
+type Message struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Outbox is a sql.EventRecorder keeping events in the outbox table until a
+// Relay delivers them.
+type Outbox struct {
+	repo *sql.Repository[EventModel, EventPayload, EventCondition]
+}
+
+func New(db *sqlx.DB, opts ...sql.RepositoryOption) *Outbox {
+	return &Outbox{repo: sql.NewRepository[EventModel, EventPayload, EventCondition](db, Table, opts...)}
+}
+
+func (o *Outbox) Append(ctx context.Context, db sql.Executor, events []sql.Event) error {
+	if tx, ok := db.(*sqlx.Tx); ok {
+		ctx = sql.WithTx(ctx, tx)
+	}
+	rows := make([]EventPayload, 0, len(events))
+	for _, event := range events {
+		event := event
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return fmt.Errorf("failed marshal %s event: %w", event.Type, err)
+		}
+		text := string(payload)
+		rows = append(rows, EventPayload{Type: &event.Type, Key: &event.Key, Payload: &text})
+	}
+	if _, err := o.repo.CreateBulk(ctx, rows); err != nil {
+		return fmt.Errorf("failed insert events: %w", err)
+	}
+	return nil
+}
+
+// Pending returns up to limit unsent events, oldest first.
+func (o *Outbox) Pending(ctx context.Context, limit int) ([]Message, error) {
+	paginate := &utils.Paginate{Page: 1, Limit: limit, Sort: []utils.Sort{{Field: "id"}}}
+	query, bind, err := sql.BuildSelectQuery(Table, o.repo.Columns(), &EventCondition{}, paginate, sql.Where(unsent))
+	if err != nil {
+		return nil, fmt.Errorf("failed build query: %w", err)
+	}
+	query, args, err := sql.BindNamedQuery(query, bind)
+	if err != nil {
+		return nil, fmt.Errorf("failed bind named query: %w", err)
+	}
+	db := sql.ExecutorFrom(ctx, o.repo.DB())
+	rows := []EventModel{}
+	if err := sqlx.SelectContext(ctx, db, &rows, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed select events: %w", err)
+	}
+	messages := make([]Message, 0, len(rows))
+	for _, row := range rows {
+		message := Message{ID: *row.ID, Type: *row.Type, Key: *row.Key}
+		if row.Payload != nil {
+			message.Payload = json.RawMessage(*row.Payload)
+		}
+		if row.Attempts != nil {
+			message.Attempts = *row.Attempts
+		}
+		if row.CreatedAt != nil {
+			message.CreatedAt = *row.CreatedAt
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Claim marks the event with id sent and runs publish in the same
+// transaction, so the mark only commits once publish succeeded. It returns
+// false without publishing when the event was already sent; a concurrent
+// claim of the event waits on the row until the first one ends.
+func (o *Outbox) Claim(ctx context.Context, id int64, publish func(ctx context.Context) error) (claimed bool, err error) {
+	err = sql.Transaction(ctx, o.repo.DB(), func(ctx context.Context) error {
+		err := o.repo.Update(ctx, EventPayload{}, EventCondition{ID: &id}, sql.Set("sent_at", time.Now()), sql.Guard(unsent))
+		if errors.Is(err, sql.ErrGuardFailed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed mark event %d sent: %w", id, err)
+		}
+		claimed = true
+		return publish(ctx)
+	})
+	if err != nil {
+		return false, err
+	}
+	return claimed, nil
+}
+
+// MarkFailed counts a failed delivery of the event with id.
+func (o *Outbox) MarkFailed(ctx context.Context, id int64, cause error) error {
+	message := cause.Error()
+	err := o.repo.Update(ctx, EventPayload{LastError: &message}, EventCondition{ID: &id}, sql.Set("attempts", sql.Incr(1)))
+	if err != nil {
+		return fmt.Errorf("failed mark event %d failed: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func connect(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for table, model := range map[string]any{repo.ProductTable: repo.ProductModel{}, Table: EventModel{}} {
+		statements, err := sql.BuildCreateTable(sql.SQLite, table, model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, statement := range statements {
+			db.MustExec(statement)
+		}
+	}
+	return db
+}
+
+func TestOutbox(t *testing.T) {
+	db := connect(t)
+	outbox := New(db)
+	products := repo.NewProductSQLRepo(db, sql.WithOutbox(outbox))
+	ctx := context.Background()
+
+	a, b, c := "A", "B", "C"
+	price, qty := 10.0, 5
+	_, err := products.Create(ctx, repo.ProductPayload{SKU: &a, Price: &price, Qty: &qty})
+	assert.Nil(t, err)
+	_, err = products.CreateBulk(ctx, []repo.ProductPayload{{SKU: &b, Qty: &qty}, {SKU: &c, Qty: &qty}})
+	assert.Nil(t, err)
+	raised := 12.5
+	assert.Nil(t, products.Update(ctx, repo.ProductPayload{Price: &raised}, repo.ProductCondition{SKUs: &[]string{a, b}}))
+	_, err = products.UpdateBulk(ctx, []sql.Update[repo.ProductPayload, repo.ProductCondition]{
+		{Condition: repo.ProductCondition{SKU: &c}, Options: []sql.QueryOption{sql.Set("qty", sql.Decr(1))}},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, products.Delete(ctx, repo.ProductCondition{SKU: &b}))
+	assert.Nil(t, products.Restore(ctx, repo.ProductCondition{SKU: &b}))
+	assert.Nil(t, products.HardDelete(ctx, repo.ProductCondition{SKU: &c}))
+
+	t.Run("failed writes record nothing", func(t *testing.T) {
+		err := products.Update(ctx, repo.ProductPayload{}, repo.ProductCondition{SKU: &a}, sql.Set("qty", sql.Decr(10)), sql.Guard("qty >= ?", 10))
+		assert.True(t, errors.Is(err, sql.ErrGuardFailed))
+		_, err = products.Create(ctx, repo.ProductPayload{SKU: &a})
+		assert.NotNil(t, err)
+	})
+
+	messages, err := outbox.Pending(ctx, 100)
+	assert.Nil(t, err)
+	expected := []struct {
+		Type string
+		Key  string
+		Qty  any
+	}{
+		{Type: "products.created", Key: "1", Qty: 5.0},
+		{Type: "products.created", Key: "2", Qty: 5.0},
+		{Type: "products.created", Key: "3", Qty: 5.0},
+		{Type: "products.updated", Key: "1", Qty: 5.0},
+		{Type: "products.updated", Key: "2", Qty: 5.0},
+		{Type: "products.updated", Key: "3", Qty: 4.0},
+		{Type: "products.deleted", Key: "2", Qty: nil},
+		{Type: "products.restored", Key: "2", Qty: 5.0},
+		{Type: "products.deleted", Key: "3", Qty: nil},
+	}
+	assert.Len(t, messages, len(expected))
+	for i, e := range expected {
+		if i >= len(messages) {
+			break
+		}
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			assert.Equal(t, e.Type, messages[i].Type)
+			assert.Equal(t, e.Key, messages[i].Key)
+			payload := map[string]any{}
+			assert.Nil(t, json.Unmarshal(messages[i].Payload, &payload))
+			assert.Equal(t, e.Qty, payload["qty"])
+			assert.NotNil(t, payload["id"])
+		})
+	}
+
+	t.Run("joins the caller transaction", func(t *testing.T) {
+		tx, err := db.Beginx()
+		assert.Nil(t, err)
+		assert.Nil(t, products.Update(sql.WithTx(ctx, tx), repo.ProductPayload{Price: &price}, repo.ProductCondition{SKU: &a}))
+		assert.Nil(t, tx.Rollback())
+		after, err := outbox.Pending(ctx, 100)
+		assert.Nil(t, err)
+		assert.Len(t, after, len(expected))
+	})
+}
@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// Publisher delivers a message downstream. A nil error means the message is
+// accepted; it may be delivered again after a crash, so consumers have to
+// tolerate duplicates, e.g. by Message.ID.
+type Publisher interface {
+	Publish(ctx context.Context, message Message) error
+}
+
+// MemoryPublisher keeps the published messages in memory, for tests and local
+// runs. Fail, when set, rejects the messages it returns an error for.
+type MemoryPublisher struct {
+	Fail func(Message) error
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, message Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Fail != nil {
+		if err := p.Fail(message); err != nil {
+			return err
+		}
+	}
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+// Messages returns the published messages in delivery order.
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message{}, p.messages...)
+}
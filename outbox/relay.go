@@ -0,0 +1,107 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const DefaultBatchSize = 100
+
+type RelayOption func(*Relay)
+
+// WithBatchSize sets how many events one RunOnce reads.
+func WithBatchSize(size int) RelayOption {
+	return func(r *Relay) {
+		r.batch = size
+	}
+}
+
+// Relay moves events from the outbox to a publisher. Events are delivered at
+// least once and in order per key: an event is marked sent only after it is
+// published, and a failed event holds back the later events of its key until
+// it goes through. Relays running side by side claim each event in turn, see
+// Outbox.Claim, so an event is published by one of them.
+type Relay struct {
+	outbox    *Outbox
+	publisher Publisher
+	batch     int
+}
+
+func NewRelay(outbox *Outbox, publisher Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{outbox: outbox, publisher: publisher, batch: DefaultBatchSize}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// RunOnce delivers one batch of pending events and returns how many were
+// sent. Failed deliveries are counted on the event and reported together
+// once the batch is done.
+func (r *Relay) RunOnce(ctx context.Context) (sent int, err error) {
+	messages, err := r.outbox.Pending(ctx, r.batch)
+	if err != nil {
+		return 0, err
+	}
+	blocked := map[string]bool{}
+	failed := 0
+	var cause error
+	for _, message := range messages {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		if blocked[message.Key] {
+			continue
+		}
+		var publishErr error
+		claimed, err := r.outbox.Claim(ctx, message.ID, func(ctx context.Context) error {
+			publishErr = r.publisher.Publish(ctx, message)
+			return publishErr
+		})
+		if publishErr != nil {
+			blocked[message.Key] = true
+			failed++
+			if cause == nil {
+				cause = publishErr
+			}
+			if err := r.outbox.MarkFailed(ctx, message.ID, publishErr); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if err != nil {
+			return sent, err
+		}
+		if claimed {
+			sent++
+		}
+	}
+	if cause != nil {
+		return sent, fmt.Errorf("failed publish %d events: %w", failed, cause)
+	}
+	return sent, nil
+}
+
+// Run delivers pending events every interval until ctx is done. Errors go to
+// onError when set and do not stop the relay.
+func (r *Relay) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				sent, err := r.RunOnce(ctx)
+				if err != nil && onError != nil && ctx.Err() == nil {
+					onError(err)
+				}
+				if err != nil || sent < r.batch {
+					break
+				}
+			}
+		}
+	}
+}
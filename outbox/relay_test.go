@@ -0,0 +1,136 @@
+package outbox
+
+import (
+	"bulk/db/sql"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelay(t *testing.T) {
+	db := connect(t)
+	outbox := New(db)
+	ctx := context.Background()
+	events := []sql.Event{
+		{Type: "products.created", Key: "1", Payload: map[string]any{"id": 1}},
+		{Type: "products.created", Key: "2", Payload: map[string]any{"id": 2}},
+		{Type: "products.updated", Key: "1", Payload: map[string]any{"id": 1}},
+		{Type: "products.updated", Key: "2", Payload: map[string]any{"id": 2}},
+	}
+	assert.Nil(t, outbox.Append(ctx, db, events))
+
+	down := true
+	publisher := NewMemoryPublisher()
+	publisher.Fail = func(message Message) error {
+		if down && message.Key == "1" {
+			return errors.New("broker down")
+		}
+		return nil
+	}
+	relay := NewRelay(outbox, publisher, WithBatchSize(10))
+
+	t.Run("failed key holds back its later events", func(t *testing.T) {
+		sent, err := relay.RunOnce(ctx)
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, sent)
+		messages := publisher.Messages()
+		assert.Len(t, messages, 2)
+		assert.Equal(t, "2", messages[0].Key)
+		assert.Equal(t, "products.created", messages[0].Type)
+		assert.Equal(t, "products.updated", messages[1].Type)
+
+		pending, err := outbox.Pending(ctx, 10)
+		assert.Nil(t, err)
+		assert.Len(t, pending, 2)
+		assert.Equal(t, 1, pending[0].Attempts)
+		assert.Equal(t, 0, pending[1].Attempts)
+	})
+
+	t.Run("delivers in order once recovered", func(t *testing.T) {
+		down = false
+		sent, err := relay.RunOnce(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, sent)
+		messages := publisher.Messages()
+		assert.Len(t, messages, 4)
+		assert.Equal(t, "1", messages[2].Key)
+		assert.Equal(t, "products.created", messages[2].Type)
+		assert.Equal(t, "products.updated", messages[3].Type)
+		assert.Equal(t, 1, messages[2].Attempts)
+
+		pending, err := outbox.Pending(ctx, 10)
+		assert.Nil(t, err)
+		assert.Empty(t, pending)
+		sent, err = relay.RunOnce(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, sent)
+	})
+
+	t.Run("run drains in batches", func(t *testing.T) {
+		more := []sql.Event{}
+		for i := 0; i < 25; i++ {
+			more = append(more, sql.Event{Type: "products.updated", Key: "3", Payload: map[string]any{"seq": i}})
+		}
+		assert.Nil(t, outbox.Append(ctx, db, more))
+		relay := NewRelay(outbox, publisher, WithBatchSize(10))
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			relay.Run(ctx, 10*time.Millisecond, func(err error) { t.Error(err) })
+			close(done)
+		}()
+		for ctx.Err() == nil && len(publisher.Messages()) < 29 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		<-done
+		messages := publisher.Messages()
+		assert.Len(t, messages, 29)
+		for i, message := range messages[4:] {
+			payload := struct{ Seq int }{}
+			assert.Nil(t, json.Unmarshal(message.Payload, &payload))
+			assert.Equal(t, i, payload.Seq)
+		}
+	})
+
+	t.Run("concurrent relays publish each event once", func(t *testing.T) {
+		more := []sql.Event{}
+		for i := 0; i < 20; i++ {
+			more = append(more, sql.Event{Type: "products.updated", Key: fmt.Sprint(i % 4), Payload: map[string]any{"seq": i}})
+		}
+		assert.Nil(t, outbox.Append(ctx, db, more))
+		publisher := NewMemoryPublisher()
+		// Slow deliveries keep both relays busy on the same batch.
+		publisher.Fail = func(Message) error {
+			time.Sleep(time.Millisecond)
+			return nil
+		}
+		wg := sync.WaitGroup{}
+		total := int64(0)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sent, err := NewRelay(outbox, publisher, WithBatchSize(50)).RunOnce(ctx)
+				assert.Nil(t, err)
+				atomic.AddInt64(&total, int64(sent))
+			}()
+		}
+		wg.Wait()
+		ids := map[int64]bool{}
+		for _, message := range publisher.Messages() {
+			assert.False(t, ids[message.ID], message.ID)
+			ids[message.ID] = true
+		}
+		assert.Len(t, ids, 20)
+		assert.Equal(t, int64(20), total)
+	})
+}
@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"bulk/cache"
+	"bulk/db/sql"
+	"bulk/utils"
+	"context"
+)
+
+type cachedRepo struct {
+	ProductRepo
+	cache *cache.Cache
+}
+
+// NewCachedProductRepo serves Select and GetByID of products through c and
+// invalidates it on every write. Reads inside a transaction bypass the
+// cache, as do GetByID calls that see deleted rows. Cached results are
+// shared between callers and must not be modified.
+func NewCachedProductRepo(products ProductRepo, c *cache.Cache) ProductRepo {
+	return &cachedRepo{ProductRepo: products, cache: c}
+}
+
+func (r *cachedRepo) Select(ctx context.Context, fields []string, condition *ProductCondition, paginate *utils.Paginate) (utils.Result[ProductModel], error) {
+	if _, ok := sql.TxFrom(ctx); ok {
+		return r.ProductRepo.Select(ctx, fields, condition, paginate)
+	}
+	query, bind, err := sql.BuildSelectQuery(r.Table(), fields, condition, paginate, sql.DeletedScopeOptions("deleted_at", sql.DeletedScopeFrom(ctx))...)
+	if err != nil {
+		return r.ProductRepo.Select(ctx, fields, condition, paginate)
+	}
+	query, args, err := sql.BindNamedQuery(query, bind)
+	if err != nil {
+		return r.ProductRepo.Select(ctx, fields, condition, paginate)
+	}
+	key, err := cache.QueryKey(query, args)
+	if err != nil {
+		return r.ProductRepo.Select(ctx, fields, condition, paginate)
+	}
+	value, err := r.cache.Query(ctx, r.Table(), key, func() (any, error) {
+		return r.ProductRepo.Select(ctx, fields, condition, paginate)
+	})
+	if err != nil {
+		return utils.Result[ProductModel]{Data: []ProductModel{}}, err
+	}
+	result := value.(utils.Result[ProductModel])
+	result.Data = append([]ProductModel{}, result.Data...)
+	return result, nil
+}
+
+func (r *cachedRepo) GetByID(ctx context.Context, id any) (ProductModel, error) {
+	if _, ok := sql.TxFrom(ctx); ok || sql.DeletedScopeFrom(ctx) != sql.ScopeLive {
+		return r.ProductRepo.GetByID(ctx, id)
+	}
+	value, err := r.cache.Row(ctx, r.Table(), id, func() (any, error) {
+		return r.ProductRepo.GetByID(ctx, id)
+	})
+	if err != nil {
+		return ProductModel{}, err
+	}
+	return value.(ProductModel), nil
+}
+
+func (r *cachedRepo) Create(ctx context.Context, payload ProductPayload) (ProductModel, error) {
+	created, err := r.ProductRepo.Create(ctx, payload)
+	if err == nil && created.ID != nil {
+		r.invalidate(ctx, *created.ID)
+	}
+	return created, err
+}
+
+func (r *cachedRepo) CreateBulk(ctx context.Context, payload []ProductPayload) ([]int64, error) {
+	ids, err := r.ProductRepo.CreateBulk(ctx, payload)
+	if err == nil {
+		keys := make([]any, 0, len(ids))
+		for _, id := range ids {
+			keys = append(keys, id)
+		}
+		r.invalidate(ctx, keys...)
+	}
+	return ids, err
+}
+
+func (r *cachedRepo) Update(ctx context.Context, payload ProductPayload, condition ProductCondition, opts ...sql.QueryOption) error {
+	defer r.invalidate(ctx, keys(condition)...)
+	return r.ProductRepo.Update(ctx, payload, condition, opts...)
+}
+
+func (r *cachedRepo) UpdateBulk(ctx context.Context, payload []sql.Update[ProductPayload, ProductCondition]) ([]sql.Update[ProductPayload, ProductCondition], error) {
+	conditions := make([]ProductCondition, 0, len(payload))
+	for _, item := range payload {
+		conditions = append(conditions, item.Condition)
+	}
+	defer r.invalidate(ctx, keys(conditions...)...)
+	return r.ProductRepo.UpdateBulk(ctx, payload)
+}
+
+func (r *cachedRepo) Delete(ctx context.Context, condition ProductCondition) error {
+	defer r.invalidate(ctx, keys(condition)...)
+	return r.ProductRepo.Delete(ctx, condition)
+}
+
+func (r *cachedRepo) HardDelete(ctx context.Context, condition ProductCondition) error {
+	defer r.invalidate(ctx, keys(condition)...)
+	return r.ProductRepo.HardDelete(ctx, condition)
+}
+
+func (r *cachedRepo) Restore(ctx context.Context, condition ProductCondition) error {
+	defer r.invalidate(ctx, keys(condition)...)
+	return r.ProductRepo.Restore(ctx, condition)
+}
+
+// invalidate drops the cached queries and the rows with the given primary
+// keys, every row when no key is given. Inside a transaction it drops them
+// again once it commits: a miss in between reads, and may cache, the rows as
+// they were before the write.
+func (r *cachedRepo) invalidate(ctx context.Context, ids ...any) {
+	r.cache.Invalidate(r.Table(), ids...)
+	if _, ok := sql.TxFrom(ctx); ok {
+		sql.AfterCommit(ctx, func() {
+			r.cache.Invalidate(r.Table(), ids...)
+		})
+	}
+}
+
+// keys lists the primary keys the conditions restrict the rows to, none when
+// one of them does not.
+func keys(conditions ...ProductCondition) []any {
+	ids := []any{}
+	for _, condition := range conditions {
+		if condition.ID == nil && condition.IDs == nil {
+			return nil
+		}
+		if condition.ID != nil {
+			ids = append(ids, *condition.ID)
+		}
+		if condition.IDs != nil {
+			for _, id := range *condition.IDs {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"bulk/cache"
+	"bulk/db/sql"
+	"bulk/utils"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingRepo struct {
+	ProductRepo
+	selects int
+	gets    int
+}
+
+func (r *countingRepo) Select(ctx context.Context, fields []string, condition *ProductCondition, paginate *utils.Paginate) (utils.Result[ProductModel], error) {
+	r.selects++
+	return r.ProductRepo.Select(ctx, fields, condition, paginate)
+}
+
+func (r *countingRepo) GetByID(ctx context.Context, id any) (ProductModel, error) {
+	r.gets++
+	return r.ProductRepo.GetByID(ctx, id)
+}
+
+func TestCachedProductRepo(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	statements, err := sql.BuildCreateTable(sql.SQLite, ProductTable, ProductModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range statements {
+		db.MustExec(statement)
+	}
+
+	inner := &countingRepo{ProductRepo: NewProductSQLRepo(db)}
+	products := NewCachedProductRepo(inner, cache.New(cache.NewLRU(100, time.Minute)))
+	ctx := context.Background()
+	a, b := "A", "B"
+	price, qty := 10.0, 5
+	_, err = products.CreateBulk(ctx, []ProductPayload{{SKU: &a, Price: &price, Qty: &qty}, {SKU: &b, Price: &price, Qty: &qty}})
+	assert.Nil(t, err)
+	paginate := &utils.Paginate{Page: 1, Limit: 10, Sort: []utils.Sort{{Field: "id"}}}
+
+	t.Run("select reads through", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			result, err := products.Select(ctx, []string{"id", "sku"}, &ProductCondition{}, paginate)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, result.Total)
+		}
+		_, err := products.Select(ctx, []string{"id", "sku"}, &ProductCondition{SKU: &a}, paginate)
+		assert.Nil(t, err)
+		_, err = products.Select(sql.WithDeleted(ctx), []string{"id", "sku"}, &ProductCondition{}, paginate)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, inner.selects)
+	})
+
+	t.Run("get by id reads through", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			product, err := products.GetByID(ctx, 1)
+			assert.Nil(t, err)
+			assert.Equal(t, "A", *product.SKU)
+		}
+		assert.Equal(t, 1, inner.gets)
+	})
+
+	t.Run("update invalidates the row and lists", func(t *testing.T) {
+		inner.selects, inner.gets = 0, 0
+		raised := 12.5
+		id := 1
+		assert.Nil(t, products.Update(ctx, ProductPayload{Price: &raised}, ProductCondition{ID: &id}))
+		product, err := products.GetByID(ctx, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 12.5, *product.Price)
+		_, err = products.GetByID(ctx, 2)
+		assert.Nil(t, err)
+		_, err = products.GetByID(ctx, 2)
+		assert.Nil(t, err)
+		_, err = products.Select(ctx, []string{"id", "sku"}, &ProductCondition{}, paginate)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, inner.gets)
+		assert.Equal(t, 1, inner.selects)
+	})
+
+	t.Run("delete by other columns invalidates every row", func(t *testing.T) {
+		inner.selects, inner.gets = 0, 0
+		assert.Nil(t, products.Delete(ctx, ProductCondition{SKU: &b}))
+		_, err := products.GetByID(ctx, 2)
+		assert.ErrorIs(t, err, sql.ErrNotFound)
+		result, err := products.Select(ctx, []string{"id", "sku"}, &ProductCondition{}, paginate)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, 1, inner.gets)
+		assert.Equal(t, 1, inner.selects)
+	})
+
+	t.Run("transactions bypass the cache", func(t *testing.T) {
+		inner.selects, inner.gets = 0, 0
+		err := sql.Transaction(ctx, db, func(ctx context.Context) error {
+			if _, err := products.GetByID(ctx, 1); err != nil {
+				return err
+			}
+			_, err := products.Select(ctx, []string{"id", "sku"}, &ProductCondition{}, paginate)
+			return err
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, inner.gets)
+		assert.Equal(t, 1, inner.selects)
+	})
+	t.Run("writes in a transaction invalidate again on commit", func(t *testing.T) {
+		id, raised := 1, 20.0
+		err := sql.Transaction(ctx, db, func(txCtx context.Context) error {
+			if err := products.Update(txCtx, ProductPayload{Price: &raised}, ProductCondition{ID: &id}); err != nil {
+				return err
+			}
+			// A concurrent reader misses before the commit and caches the old row.
+			product, err := products.GetByID(ctx, 1)
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, 12.5, *product.Price)
+			_, err = products.Select(ctx, []string{"id", "price"}, &ProductCondition{ID: &id}, paginate)
+			return err
+		})
+		assert.Nil(t, err)
+		product, err := products.GetByID(ctx, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 20.0, *product.Price)
+		result, err := products.Select(ctx, []string{"id", "price"}, &ProductCondition{ID: &id}, paginate)
+		assert.Nil(t, err)
+		assert.Equal(t, 20.0, *result.Data[0].Price)
+	})
+}
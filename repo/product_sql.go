@@ -3,8 +3,9 @@ package repo
 import (
 	"bulk/db/sql"
 	"bulk/utils"
-	"errors"
-	"fmt"
+	"context"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -12,156 +13,104 @@ import (
 const ProductTable = "products"
 
 type ProductModel struct {
-	ID    *int     `db:"id"`
-	SKU   *string  `db:"sku"`
-	Name  *string  `db:"name"`
-	Price *float64 `db:"price"`
-	Qty   *int     `db:"qty"`
+	ID        *int       `db:"id,pk,autoincrement"`
+	SKU       *string    `db:"sku,type=VARCHAR(64),notnull,unique"`
+	Name      *string    `db:"name,type=VARCHAR(255)"`
+	Price     *float64   `db:"price"`
+	Qty       *int       `db:"qty,type=INTEGER,notnull,default=0"`
+	Version   *int       `db:"version,version,type=INTEGER,notnull,default=1"`
+	CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+	UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
+	DeletedAt *time.Time `db:"deleted_at,softdelete"`
 }
 
-type ProductPayload struct {
-	ID    *int     `db:"id"`
-	SKU   *string  `db:"sku"`
-	Name  *string  `db:"name"`
-	Price *float64 `db:"price"`
-	Qty   *int     `db:"qty"`
+// ProductColumns are the typed column handles of ProductModel, see
+// sql.ColumnsOf.
+type ProductColumns struct {
+	sql.ColumnSet
+	ID, SKU, Name, Price, Qty, Version, CreatedAt, UpdatedAt, DeletedAt sql.Column
 }
 
-type ProductCondition struct {
-	ID   *int      `db:"id"`
-	IDs  *[]int    `db:"id"`
-	SKU  *string   `db:"sku"`
-	SKUs *[]string `db:"sku"`
-}
+var ProductCols = sql.MustColumnsOf[ProductColumns, ProductModel]()
 
-type ProductRepo interface {
-	Table() string
-	Select(fields []string, condition *ProductCondition, paginate *utils.Paginate) (utils.Result[ProductModel], error)
-	Create(payload ProductPayload) error
-	CreateBulk(payload []ProductPayload) (fails []ProductPayload, err error)
-	Update(payload ProductPayload, condition ProductCondition) error
-	UpdateBulk(payload []sql.Update[ProductPayload, ProductCondition]) (fails []sql.Update[ProductPayload, ProductCondition], err error)
-	Delete(condition ProductCondition) error
+type ProductPayload struct {
+	ID        *int       `db:"id"`
+	SKU       *string    `db:"sku" validate:"required,max=64"`
+	Name      *string    `db:"name" validate:"max=255"`
+	Price     *float64   `db:"price" validate:"min=0"`
+	Qty       *int       `db:"qty" validate:"min=0"`
+	Version   *int       `db:"version,version"`
+	CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+	UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
 }
 
-type repo struct {
-	db *sqlx.DB
+func NormalizeSKU(SKU string) string {
+	return strings.ToUpper(strings.TrimSpace(SKU))
 }
 
-func NewProductSQLRepo(db *sqlx.DB) ProductRepo {
-	return &repo{db: db}
+func (p *ProductPayload) normalize() {
+	if p.SKU != nil {
+		SKU := NormalizeSKU(*p.SKU)
+		p.SKU = &SKU
+	}
 }
 
-func (r *repo) Table() string {
-	return ProductTable
+func (p *ProductPayload) BeforeCreate(ctx context.Context, db sql.Executor) error {
+	p.normalize()
+	return nil
 }
 
-func (r *repo) Select(fields []string, condition *ProductCondition, paginate *utils.Paginate) (result utils.Result[ProductModel], err error) {
-	empty := utils.Result[ProductModel]{Data: []ProductModel{}}
-
-	// Result data
-	query, param, err := sql.BuildSelectQuery(r.Table(), fields, condition, paginate)
-	if err != nil {
-		return empty, fmt.Errorf("failed build query: %w", err)
-	}
-	query, args, err := sql.BindNamedQuery(query, param)
-	if err != nil {
-		return empty, fmt.Errorf("failed bind named query: %w", err)
-	}
-	data := []ProductModel{}
-	if err := r.db.Select(&data, query, args...); err != nil {
-		return empty, fmt.Errorf("failed select db: %w", err)
-	}
-
-	// Total
-	query, param, err = sql.BuildCountQuery(r.Table(), condition)
-	if err != nil {
-		return empty, fmt.Errorf("failed build count query: %w", err)
-	}
-	query, args, err = sql.BindNamedQuery(query, param)
-	if err != nil {
-		return empty, fmt.Errorf("failed bind count named query: %w", err)
-	}
-	total := 0
-	if err := r.db.Get(&total, query, args...); err != nil {
-		return empty, fmt.Errorf("failed count data: %w", err)
-	}
-
-	return utils.Pagination(data, total, paginate), nil
+func (p *ProductPayload) BeforeUpdate(ctx context.Context, db sql.Executor) error {
+	p.normalize()
+	return nil
 }
 
-func (r *repo) Create(payload ProductPayload) error {
-	query, param, err := sql.BuildCreateQuery(r.Table(), payload)
-	if err != nil {
-		return fmt.Errorf("failed build query: %w", err)
-	}
-	query, args, err := sql.BindNamedQuery(query, param)
-	if err != nil {
-		return fmt.Errorf("failed bind named query: %w", err)
-	}
-	if _, err := r.db.Exec(query, args...); err != nil {
-		return fmt.Errorf("failed insert db: %w", err)
-	}
-	return nil
+type ProductCondition struct {
+	ID       *int      `db:"id"`
+	IDs      *[]int    `db:"id"`
+	SKU      *string   `db:"sku"`
+	SKUs     *[]string `db:"sku"`
+	PriceGte *float64  `db:"price,gte"`
+	PriceLte *float64  `db:"price,lte"`
 }
 
-func (r *repo) CreateBulk(payload []ProductPayload) (fails []ProductPayload, err error) {
-	empty := []ProductPayload{}
-	if len(payload) <= 0 {
-		return empty, errors.New("payload is required")
-	}
-	query, _, err := sql.BuildCreateQuery(r.Table(), payload[0])
-	if err != nil {
-		return empty, fmt.Errorf("failed build query: %w", err)
+// Normalize matches SKUs the way ProductPayload stores them.
+func (c *ProductCondition) Normalize() {
+	if c.SKU != nil {
+		SKU := NormalizeSKU(*c.SKU)
+		c.SKU = &SKU
 	}
-	if _, err := r.db.NamedExec(query, payload); err != nil {
-		return empty, fmt.Errorf("failed insert db: %w", err)
-	}
-	if len(fails) > 0 {
-		return empty, errors.New("input fails")
+	if c.SKUs != nil {
+		SKUs := make([]string, 0, len(*c.SKUs))
+		for _, SKU := range *c.SKUs {
+			SKUs = append(SKUs, NormalizeSKU(SKU))
+		}
+		c.SKUs = &SKUs
 	}
-	return fails, nil
 }
 
-func (r *repo) UpdateBulk(payload []sql.Update[ProductPayload, ProductCondition]) (fails []sql.Update[ProductPayload, ProductCondition], err error) {
-	fails = []sql.Update[ProductPayload, ProductCondition]{}
-	for _, v := range payload {
-		if err := r.Update(v.Payload, v.Condition); err != nil {
-			fails = append(fails, v)
-		}
-	}
-	if len(fails) > 0 {
-		return fails, errors.New("update bulk fail")
-	}
-	return nil, nil
+type ProductRepo interface {
+	Table() string
+	Select(ctx context.Context, fields []string, condition *ProductCondition, paginate *utils.Paginate) (utils.Result[ProductModel], error)
+	Stream(ctx context.Context, fields []string, condition *ProductCondition, paginate *utils.Paginate, fn func(ProductModel) error) error
+	GetByID(ctx context.Context, id any) (ProductModel, error)
+	FindOne(ctx context.Context, condition ProductCondition) (ProductModel, error)
+	Exists(ctx context.Context, condition ProductCondition) (bool, error)
+	Create(ctx context.Context, payload ProductPayload) (ProductModel, error)
+	CreateBulk(ctx context.Context, payload []ProductPayload) (ids []int64, err error)
+	Update(ctx context.Context, payload ProductPayload, condition ProductCondition, opts ...sql.QueryOption) error
+	UpdateBulk(ctx context.Context, payload []sql.Update[ProductPayload, ProductCondition]) (fails []sql.Update[ProductPayload, ProductCondition], err error)
+	Delete(ctx context.Context, condition ProductCondition) error
+	HardDelete(ctx context.Context, condition ProductCondition) error
+	Restore(ctx context.Context, condition ProductCondition) error
 }
 
-func (r *repo) Update(payload ProductPayload, condition ProductCondition) error {
-	query, param, err := sql.BuildUpdateQuery(r.Table(), payload, condition, "")
-	if err != nil {
-		return fmt.Errorf("failed build query: %w", err)
-	}
-	query, args, err := sql.BindNamedQuery(query, param)
-	if err != nil {
-		return fmt.Errorf("failed bind named query: %w", err)
-	}
-	if _, err := r.db.Exec(query, args...); err != nil {
-		return fmt.Errorf("failed update db: %w", err)
-	}
-	return nil
+type repo struct {
+	*sql.Repository[ProductModel, ProductPayload, ProductCondition]
 }
 
-func (r *repo) Delete(condition ProductCondition) error {
-	query, param, err := sql.BuildDeleteQuery(r.Table(), condition)
-	if err != nil {
-		return fmt.Errorf("failed build query: %w", err)
-	}
-	query, args, err := sql.BindNamedQuery(query, param)
-	if err != nil {
-		return fmt.Errorf("failed bind named query: %w", err)
+func NewProductSQLRepo(db *sqlx.DB, opts ...sql.RepositoryOption) ProductRepo {
+	return &repo{
+		Repository: sql.NewRepository[ProductModel, ProductPayload, ProductCondition](db, ProductTable, opts...),
 	}
-	if _, err := r.db.Exec(query, args...); err != nil {
-		return fmt.Errorf("failed delete db: %w", err)
-	}
-	return nil
 }
@@ -2,11 +2,17 @@ package repo
 
 import (
 	"bulk/db/sql"
+	"bulk/migrate"
+	"bulk/utils"
+	"context"
 	"fmt"
+	"math"
+	"path/filepath"
 	"testing"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,10 +22,21 @@ type productSQLSuite struct {
 }
 
 func NewProductSQLSuite() *productSQLSuite {
-	db, err := sqlx.Connect("mysql", "root:root@tcp(localhost:3307)/tmp?multiStatements=true")
+	db, err := sqlx.Connect("mysql", "root:root@tcp(localhost:3307)/tmp?multiStatements=true&parseTime=true")
 	if err != nil {
 		panic(err)
 	}
+	migrations, err := migrate.Builtin(sql.MySQL)
+	if err != nil {
+		panic(err)
+	}
+	migrator, err := migrate.New(db, migrations)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		panic(err)
+	}
 	return &productSQLSuite{
 		db:   db,
 		repo: NewProductSQLRepo(db),
@@ -51,11 +68,11 @@ func BenchmarkProductSQLRepo(b *testing.B) {
 	}
 
 	b.Run("create bulk", func(b *testing.B) {
-		test.repo.CreateBulk(inputs)
+		test.repo.CreateBulk(context.Background(), inputs)
 	})
 
 	b.Run("update bulk", func(b *testing.B) {
-		test.repo.UpdateBulk(updates)
+		test.repo.UpdateBulk(context.Background(), updates)
 	})
 }
 
@@ -82,33 +99,93 @@ func TestProductSQLRepo(t *testing.T) {
 	}
 
 	t.Run("create", func(t *testing.T) {
-		err := test.repo.Create(inputs[0])
+		item, err := test.repo.Create(context.Background(), inputs[0])
 		assert.Empty(t, err)
+		assert.NotNil(t, item.ID)
+		assert.Equal(t, *inputs[0].SKU, *item.SKU)
 	})
 
 	t.Run("create bulk", func(t *testing.T) {
-		fails, err := test.repo.CreateBulk(inputs[1:])
+		ids, err := test.repo.CreateBulk(context.Background(), inputs[1:])
 		assert.Nil(t, err)
-		assert.Empty(t, fails)
+		assert.Len(t, ids, len(inputs)-1)
 	})
 
 	t.Run("select", func(t *testing.T) {
 
 		t.Run("all", func(t *testing.T) {
-			data, err := test.repo.Select([]string{"id"}, nil, nil)
+			data, err := test.repo.Select(context.Background(), []string{"id"}, nil, nil)
 			assert.Nil(t, err)
 			assert.Equal(t, length, data.Total)
 		})
 	})
 
 	t.Run("update bulk", func(t *testing.T) {
-		fails, err := test.repo.UpdateBulk(updates[:5])
+		fails, err := test.repo.UpdateBulk(context.Background(), updates[:5])
 		assert.Nil(t, err)
 		assert.Empty(t, fails)
 	})
 
 	t.Run("delete", func(t *testing.T) {
-		err := test.repo.Delete(ProductCondition{SKUs: &SKUs})
+		err := test.repo.Delete(context.Background(), ProductCondition{SKUs: &SKUs})
 		assert.Nil(t, err)
 	})
 }
+
+func TestProductPayloadHooks(t *testing.T) {
+	SKU := "  ab-1 "
+	qty := 2
+	payload := ProductPayload{SKU: &SKU, Qty: &qty}
+	assert.Nil(t, payload.BeforeCreate(context.Background(), nil))
+	assert.Equal(t, "AB-1", *payload.SKU)
+	assert.Equal(t, "  ab-1 ", SKU)
+}
+
+func TestProductConditionSKU(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	migrations, err := migrate.Builtin(sql.SQLite)
+	assert.Nil(t, err)
+	migrator, err := migrate.New(db, migrations)
+	assert.Nil(t, err)
+	_, err = migrator.Up(context.Background())
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	products := NewProductSQLRepo(db)
+	SKU, qty := "sku_1", 1
+	_, err = products.Create(ctx, ProductPayload{SKU: &SKU, Qty: &qty})
+	assert.Nil(t, err)
+
+	updated := 7
+	assert.Nil(t, products.Update(ctx, ProductPayload{Qty: &updated}, ProductCondition{SKU: &SKU}))
+	SKUs := []string{" sku_1 "}
+	product, err := products.FindOne(ctx, ProductCondition{SKUs: &SKUs})
+	assert.Nil(t, err)
+	assert.Equal(t, "SKU_1", *product.SKU)
+	assert.Equal(t, 7, *product.Qty)
+	assert.Equal(t, "sku_1", SKU)
+	assert.Equal(t, []string{" sku_1 "}, SKUs)
+}
+
+func TestProductPayloadValidate(t *testing.T) {
+	negative := -1
+	price := math.NaN()
+	err := utils.Validate(ProductPayload{Qty: &negative, Price: &price}, sql.Tag)
+	assert.Equal(t, utils.ValidationErrors{
+		{Field: "sku", Message: "is required"},
+		{Field: "price", Message: "must be a finite number"},
+		{Field: "qty", Message: "must be at least 0"},
+	}, err)
+	assert.Nil(t, utils.ValidatePartial(ProductPayload{}, sql.Tag))
+}
+
+func TestProductColumns(t *testing.T) {
+	assert.Equal(t, NewProductSQLRepo(nil).(*repo).Columns(), ProductCols.All())
+	assert.Equal(t, []string{"id", "sku", "qty"}, sql.Names(ProductCols.ID, ProductCols.SKU, ProductCols.Qty))
+	assert.Equal(t, NewReservationSQLRepo(nil).(*reservationRepo).Columns(), ReservationCols.All())
+	assert.Equal(t, sql.Column("expires_at"), ReservationCols.ExpiresAt)
+}
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"bulk/db/sql"
+	"bulk/utils"
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const ReservationTable = "reservations"
+
+const (
+	ReservationPending   = "pending"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+type ReservationModel struct {
+	ID        *int       `db:"id,pk,autoincrement"`
+	SKU       *string    `db:"sku,type=VARCHAR(64),notnull"`
+	Qty       *int       `db:"qty,type=INTEGER,notnull"`
+	Status    *string    `db:"status,type=VARCHAR(16),notnull,index=idx_reservations_status_expires"`
+	ExpiresAt *time.Time `db:"expires_at,notnull,index=idx_reservations_status_expires"`
+	CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+	UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
+}
+
+// ReservationColumns are the typed column handles of ReservationModel, see
+// sql.ColumnsOf.
+type ReservationColumns struct {
+	sql.ColumnSet
+	ID, SKU, Qty, Status, ExpiresAt, CreatedAt, UpdatedAt sql.Column
+}
+
+var ReservationCols = sql.MustColumnsOf[ReservationColumns, ReservationModel]()
+
+type ReservationPayload struct {
+	SKU       *string    `db:"sku" validate:"required,max=64"`
+	Qty       *int       `db:"qty" validate:"required,min=1"`
+	Status    *string    `db:"status" validate:"required"`
+	ExpiresAt *time.Time `db:"expires_at" validate:"required"`
+	CreatedAt *time.Time `db:"created_at,autoCreateTime"`
+	UpdatedAt *time.Time `db:"updated_at,autoUpdateTime"`
+}
+
+type ReservationCondition struct {
+	ID            *int       `db:"id"`
+	SKU           *string    `db:"sku"`
+	Status        *string    `db:"status"`
+	ExpiresBefore *time.Time `db:"expires_at,lte"`
+}
+
+type ReservationRepo interface {
+	Table() string
+	Select(ctx context.Context, fields []string, condition *ReservationCondition, paginate *utils.Paginate) (utils.Result[ReservationModel], error)
+	GetByID(ctx context.Context, id any) (ReservationModel, error)
+	Create(ctx context.Context, payload ReservationPayload) (ReservationModel, error)
+	Update(ctx context.Context, payload ReservationPayload, condition ReservationCondition, opts ...sql.QueryOption) error
+}
+
+type reservationRepo struct {
+	*sql.Repository[ReservationModel, ReservationPayload, ReservationCondition]
+}
+
+func NewReservationSQLRepo(db *sqlx.DB, opts ...sql.RepositoryOption) ReservationRepo {
+	return &reservationRepo{
+		Repository: sql.NewRepository[ReservationModel, ReservationPayload, ReservationCondition](db, ReservationTable, opts...),
+	}
+}
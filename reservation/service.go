@@ -0,0 +1,192 @@
+package reservation
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"bulk/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrClosed            = errors.New("reservation is not pending")
+	ErrExpired           = errors.New("reservation expired")
+)
+
+const sweepBatch = 100
+
+type Option func(*Service)
+
+// WithClock sets the time source of expiry checks.
+func WithClock(clock func() time.Time) Option {
+	return func(s *Service) {
+		s.clock = clock
+	}
+}
+
+// WithRetryPolicy sets how the reservation transactions retry deadlocks.
+func WithRetryPolicy(policy sql.RetryPolicy) Option {
+	return func(s *Service) {
+		s.retry = policy
+	}
+}
+
+// Service holds stock for a while before it is sold. Reserve takes the
+// quantity off products.qty right away, Commit keeps it taken and Release or
+// expiry puts it back, each in one transaction.
+type Service struct {
+	db           *sqlx.DB
+	products     repo.ProductRepo
+	reservations repo.ReservationRepo
+	clock        func() time.Time
+	retry        sql.RetryPolicy
+}
+
+func NewService(db *sqlx.DB, products repo.ProductRepo, reservations repo.ReservationRepo, opts ...Option) *Service {
+	s := &Service{
+		db:           db,
+		products:     products,
+		reservations: reservations,
+		clock:        time.Now,
+		retry:        sql.DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reserve takes qty of sku off the stock until ttl passes. It fails with
+// ErrInsufficientStock when less than qty is left and with sql.ErrNotFound
+// when there is no such product.
+func (s *Service) Reserve(ctx context.Context, sku string, qty int, ttl time.Duration) (repo.ReservationModel, error) {
+	var reservation repo.ReservationModel
+	if qty <= 0 {
+		return reservation, utils.ValidationErrors{{Field: "qty", Message: "must be at least 1"}}
+	}
+	sku = repo.NormalizeSKU(sku)
+	err := sql.RetryTransaction(ctx, s.db, s.retry, func(ctx context.Context) error {
+		err := s.products.Update(ctx, repo.ProductPayload{}, repo.ProductCondition{SKU: &sku}, sql.Set("qty", sql.Decr(qty)), sql.Guard("qty >= ?", qty))
+		if errors.Is(err, sql.ErrGuardFailed) {
+			exists, err := s.products.Exists(ctx, repo.ProductCondition{SKU: &sku})
+			if err != nil {
+				return fmt.Errorf("failed check product: %w", err)
+			}
+			if !exists {
+				return fmt.Errorf("reserve %s: %w", sku, &sql.NotFoundError{Table: s.products.Table()})
+			}
+			return fmt.Errorf("reserve %d of %s: %w", qty, sku, ErrInsufficientStock)
+		}
+		if err != nil {
+			return fmt.Errorf("failed take stock: %w", err)
+		}
+		status := repo.ReservationPending
+		expiresAt := s.clock().Add(ttl)
+		reservation, err = s.reservations.Create(ctx, repo.ReservationPayload{SKU: &sku, Qty: &qty, Status: &status, ExpiresAt: &expiresAt})
+		if err != nil {
+			return fmt.Errorf("failed create reservation: %w", err)
+		}
+		return nil
+	})
+	return reservation, err
+}
+
+// Commit turns a pending reservation into a sale. The stock stays taken.
+func (s *Service) Commit(ctx context.Context, id int) error {
+	return sql.RetryTransaction(ctx, s.db, s.retry, func(ctx context.Context) error {
+		now := s.clock()
+		status := repo.ReservationCommitted
+		err := s.reservations.Update(ctx, repo.ReservationPayload{Status: &status}, repo.ReservationCondition{ID: &id},
+			sql.Guard("status = ?", repo.ReservationPending), sql.Guard("expires_at > ?", now))
+		if !errors.Is(err, sql.ErrGuardFailed) {
+			return err
+		}
+		reservation, err := s.reservations.GetByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed get reservation: %w", err)
+		}
+		if *reservation.Status == repo.ReservationPending {
+			return fmt.Errorf("commit reservation %d: %w", id, ErrExpired)
+		}
+		return fmt.Errorf("commit reservation %d, %s: %w", id, *reservation.Status, ErrClosed)
+	})
+}
+
+// Release cancels a pending reservation and returns its stock.
+func (s *Service) Release(ctx context.Context, id int) error {
+	return sql.RetryTransaction(ctx, s.db, s.retry, func(ctx context.Context) error {
+		return s.close(ctx, id, repo.ReservationReleased)
+	})
+}
+
+// Sweep expires the pending reservations past their expiry and returns their
+// stock, reporting how many were expired.
+func (s *Service) Sweep(ctx context.Context) (int, error) {
+	swept := 0
+	for {
+		now := s.clock()
+		pending := repo.ReservationPending
+		result, err := s.reservations.Select(ctx, sql.Names(repo.ReservationCols.ID), &repo.ReservationCondition{Status: &pending, ExpiresBefore: &now},
+			&utils.Paginate{Page: 1, Limit: sweepBatch, Sort: []utils.Sort{repo.ReservationCols.ID.Asc()}})
+		if err != nil {
+			return swept, fmt.Errorf("failed find expired reservations: %w", err)
+		}
+		for _, reservation := range result.Data {
+			err := sql.RetryTransaction(ctx, s.db, s.retry, func(ctx context.Context) error {
+				return s.close(ctx, *reservation.ID, repo.ReservationExpired)
+			})
+			if errors.Is(err, ErrClosed) {
+				continue
+			}
+			if err != nil {
+				return swept, err
+			}
+			swept++
+		}
+		if len(result.Data) < sweepBatch {
+			return swept, nil
+		}
+	}
+}
+
+// RunSweeper sweeps every interval until ctx is done. Sweep errors go to
+// onError when set and do not stop the sweeper.
+func (s *Service) RunSweeper(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Sweep(ctx); err != nil && onError != nil && ctx.Err() == nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// close moves a pending reservation to status and puts its stock back.
+func (s *Service) close(ctx context.Context, id int, status string) error {
+	reservation, err := s.reservations.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed get reservation: %w", err)
+	}
+	err = s.reservations.Update(ctx, repo.ReservationPayload{Status: &status}, repo.ReservationCondition{ID: &id}, sql.Guard("status = ?", repo.ReservationPending))
+	if errors.Is(err, sql.ErrGuardFailed) {
+		return fmt.Errorf("close reservation %d as %s, already %s: %w", id, status, *reservation.Status, ErrClosed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed update reservation: %w", err)
+	}
+	err = s.products.Update(ctx, repo.ProductPayload{}, repo.ProductCondition{SKU: reservation.SKU}, sql.Set("qty", sql.Incr(*reservation.Qty)))
+	if err != nil {
+		return fmt.Errorf("failed return stock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package reservation
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+var schema = []string{
+	`CREATE TABLE products (id INTEGER PRIMARY KEY, sku TEXT NOT NULL UNIQUE, name TEXT, price REAL, qty INTEGER NOT NULL DEFAULT 0,
+		version INTEGER NOT NULL DEFAULT 1, created_at DATETIME, updated_at DATETIME, deleted_at DATETIME)`,
+	`CREATE TABLE reservations (id INTEGER PRIMARY KEY, sku TEXT NOT NULL, qty INTEGER NOT NULL, status TEXT NOT NULL,
+		expires_at DATETIME NOT NULL, created_at DATETIME, updated_at DATETIME)`,
+}
+
+type fixture struct {
+	service  *Service
+	products repo.ProductRepo
+	now      time.Time
+	mu       sync.Mutex
+}
+
+func (f *fixture) clock() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fixture) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func (f *fixture) qty(t *testing.T, sku string) int {
+	item, err := f.products.FindOne(context.Background(), repo.ProductCondition{SKU: &sku})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *item.Qty
+}
+
+func newFixture(t *testing.T, stock int) *fixture {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=10000&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, statement := range schema {
+		db.MustExec(statement)
+	}
+	f := &fixture{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	f.products = repo.NewProductSQLRepo(db)
+	sku, qty := "A", stock
+	if _, err := f.products.Create(context.Background(), repo.ProductPayload{SKU: &sku, Qty: &qty}); err != nil {
+		t.Fatal(err)
+	}
+	f.service = NewService(db, f.products, repo.NewReservationSQLRepo(db), WithClock(f.clock))
+	return f
+}
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, 5)
+
+	reservation, err := f.service.Reserve(ctx, " a ", 3, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "A", *reservation.SKU)
+	assert.Equal(t, repo.ReservationPending, *reservation.Status)
+	assert.Equal(t, 2, f.qty(t, "A"))
+
+	_, err = f.service.Reserve(ctx, "A", 3, time.Minute)
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+	_, err = f.service.Reserve(ctx, "B", 1, time.Minute)
+	assert.ErrorIs(t, err, sql.ErrNotFound)
+	assert.False(t, errors.Is(err, ErrInsufficientStock))
+	_, err = f.service.Reserve(ctx, "A", 0, time.Minute)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, f.qty(t, "A"))
+}
+
+func TestCommitAndRelease(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, 5)
+
+	committed, _ := f.service.Reserve(ctx, "A", 2, time.Minute)
+	released, _ := f.service.Reserve(ctx, "A", 2, time.Minute)
+	assert.Nil(t, f.service.Commit(ctx, *committed.ID))
+	assert.Nil(t, f.service.Release(ctx, *released.ID))
+	assert.Equal(t, 3, f.qty(t, "A"))
+
+	assert.ErrorIs(t, f.service.Commit(ctx, *released.ID), ErrClosed)
+	assert.ErrorIs(t, f.service.Release(ctx, *committed.ID), ErrClosed)
+	assert.ErrorIs(t, f.service.Release(ctx, *released.ID), ErrClosed)
+	assert.Equal(t, 3, f.qty(t, "A"))
+
+	expired, _ := f.service.Reserve(ctx, "A", 1, time.Minute)
+	f.advance(2 * time.Minute)
+	assert.ErrorIs(t, f.service.Commit(ctx, *expired.ID), ErrExpired)
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, 10)
+
+	short, _ := f.service.Reserve(ctx, "A", 3, time.Minute)
+	_, _ = f.service.Reserve(ctx, "A", 4, time.Hour)
+	assert.Equal(t, 3, f.qty(t, "A"))
+
+	swept, err := f.service.Sweep(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, swept)
+
+	f.advance(2 * time.Minute)
+	swept, err = f.service.Sweep(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, swept)
+	assert.Equal(t, 6, f.qty(t, "A"))
+	assert.ErrorIs(t, f.service.Release(ctx, *short.ID), ErrClosed)
+
+	t.Run("run sweeper", func(t *testing.T) {
+		f.advance(2 * time.Hour)
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			f.service.RunSweeper(ctx, time.Millisecond, func(err error) { t.Error(err) })
+			close(done)
+		}()
+		assert.Eventually(t, func() bool { return f.qty(t, "A") == 10 }, 5*time.Second, 5*time.Millisecond)
+		cancel()
+		<-done
+	})
+}
+
+func TestReserveConcurrent(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, 10)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved, short := 0, 0
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.service.Reserve(ctx, "A", 1, time.Minute)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, ErrInsufficientStock):
+				short++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, reserved)
+	assert.Equal(t, 20, short)
+	assert.Equal(t, 0, f.qty(t, "A"))
+}
+
+func TestCloseConcurrent(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, 5)
+	reservation, err := f.service.Reserve(ctx, "A", 5, time.Minute)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				errs <- f.service.Release(ctx, *reservation.ID)
+				return
+			}
+			errs <- f.service.Commit(ctx, *reservation.ID)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrClosed)
+	}
+	assert.Equal(t, 1, succeeded)
+	assert.Contains(t, []int{0, 5}, f.qty(t, "A"))
+}
@@ -0,0 +1,258 @@
+package schema
+
+import (
+	"bulk/db/sql"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrDrift = errors.New("schema drift")
+
+type Kind string
+
+const (
+	MissingTable   Kind = "missing_table"
+	MissingColumn  Kind = "missing_column"
+	ExtraColumn    Kind = "extra_column"
+	TypeMismatch   Kind = "type_mismatch"
+	NullMismatch   Kind = "null_mismatch"
+	PrimaryKeyDiff Kind = "primary_key"
+	MissingIndex   Kind = "missing_index"
+	ExtraIndex     Kind = "extra_index"
+	IndexMismatch  Kind = "index_mismatch"
+)
+
+// Drift is one difference between a model and its live table. Name is the
+// column or index concerned, empty for the whole table.
+type Drift struct {
+	Table    string `db:"table" json:"table"`
+	Kind     Kind   `db:"kind" json:"kind"`
+	Name     string `db:"name" json:"name,omitempty"`
+	Expected string `db:"expected" json:"expected,omitempty"`
+	Actual   string `db:"actual" json:"actual,omitempty"`
+}
+
+func (d Drift) String() string {
+	target := d.Table
+	if d.Name != "" {
+		target += "." + d.Name
+	}
+	if d.Expected == "" && d.Actual == "" {
+		return fmt.Sprintf("%s: %s", target, d.Kind)
+	}
+	return fmt.Sprintf("%s: %s, expected %q, actual %q", target, d.Kind, d.Expected, d.Actual)
+}
+
+type DriftError struct {
+	Drifts []Drift
+}
+
+func (e *DriftError) Error() string {
+	lines := make([]string, 0, len(e.Drifts))
+	for _, drift := range e.Drifts {
+		lines = append(lines, drift.String())
+	}
+	return fmt.Sprintf("%v: %s", ErrDrift, strings.Join(lines, "; "))
+}
+
+func (e *DriftError) Is(target error) bool {
+	return target == ErrDrift
+}
+
+// Model pairs a table with the tagged struct its repository reads into.
+type Model struct {
+	Table string
+	Model any
+}
+
+// Check compares every model against its live table, see Compare.
+func Check(ctx context.Context, db *sqlx.DB, models ...Model) ([]Drift, error) {
+	dialect := sql.DialectOf(db.DriverName())
+	drifts := []Drift{}
+	for _, model := range models {
+		expected, err := sql.DescribeTable(dialect, model.Table, model.Model)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := Inspect(ctx, db, model.Table)
+		if errors.Is(err, ErrTableNotFound) {
+			drifts = append(drifts, Drift{Table: model.Table, Kind: MissingTable})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, Compare(dialect, expected, actual)...)
+	}
+	return drifts, nil
+}
+
+// Verify is Check as a startup guard: it fails with a *DriftError wrapping
+// ErrDrift when any model disagrees with the database.
+func Verify(ctx context.Context, db *sqlx.DB, models ...Model) error {
+	drifts, err := Check(ctx, db, models...)
+	if err != nil {
+		return err
+	}
+	if len(drifts) > 0 {
+		return &DriftError{Drifts: drifts}
+	}
+	return nil
+}
+
+// Compare lists the differences between the expected and actual definition of
+// a table: missing and extra columns, column types compared after
+// normalizing dialect aliases, nullability, the primary key and the indexes.
+// Named indexes are matched by name, unnamed unique columns by any single
+// column unique index. Defaults are not compared, databases rewrite them.
+func Compare(dialect sql.Dialect, expected sql.TableDef, actual sql.TableDef) []Drift {
+	drifts := []Drift{}
+	add := func(kind Kind, name string, want string, got string) {
+		drifts = append(drifts, Drift{Table: expected.Name, Kind: kind, Name: name, Expected: want, Actual: got})
+	}
+
+	for _, column := range expected.Columns {
+		live, ok := actual.Column(column.Name)
+		if !ok {
+			add(MissingColumn, column.Name, column.Type, "")
+			continue
+		}
+		if normalizeType(dialect, column.Type) != normalizeType(dialect, live.Type) {
+			add(TypeMismatch, column.Name, column.Type, live.Type)
+		}
+		// Primary keys are implicitly not null, SQLite still reports them nullable.
+		if column.NotNull != live.NotNull && !column.PrimaryKey {
+			add(NullMismatch, column.Name, nullability(column.NotNull), nullability(live.NotNull))
+		}
+	}
+	for _, column := range actual.Columns {
+		if _, ok := expected.Column(column.Name); !ok {
+			add(ExtraColumn, column.Name, "", column.Type)
+		}
+	}
+	if strings.Join(expected.PrimaryKey, ", ") != strings.Join(actual.PrimaryKey, ", ") {
+		add(PrimaryKeyDiff, "", strings.Join(expected.PrimaryKey, ", "), strings.Join(actual.PrimaryKey, ", "))
+	}
+
+	matched := map[string]bool{}
+	for _, index := range expected.Indexes {
+		found := false
+		for _, live := range actual.Indexes {
+			if live.Name != index.Name {
+				continue
+			}
+			found, matched[live.Name] = true, true
+			if describeIndex(index) != describeIndex(live) {
+				add(IndexMismatch, index.Name, describeIndex(index), describeIndex(live))
+			}
+		}
+		if !found {
+			add(MissingIndex, index.Name, describeIndex(index), "")
+		}
+	}
+	for _, column := range expected.Columns {
+		if !column.Unique {
+			continue
+		}
+		want := sql.IndexDef{Unique: true, Columns: []string{column.Name}}
+		found := false
+		for _, live := range actual.Indexes {
+			if !found && !matched[live.Name] && describeIndex(live) == describeIndex(want) {
+				found, matched[live.Name] = true, true
+			}
+		}
+		if !found {
+			add(MissingIndex, column.Name, describeIndex(want), "")
+		}
+	}
+	for _, live := range actual.Indexes {
+		if !matched[live.Name] {
+			add(ExtraIndex, live.Name, "", describeIndex(live))
+		}
+	}
+	return drifts
+}
+
+func nullability(notNull bool) string {
+	if notNull {
+		return "NOT NULL"
+	}
+	return "NULL"
+}
+
+func describeIndex(index sql.IndexDef) string {
+	kind := "INDEX"
+	if index.Unique {
+		kind = "UNIQUE INDEX"
+	}
+	return fmt.Sprintf("%s (%s)", kind, strings.Join(index.Columns, ", "))
+}
+
+var (
+	spaces       = regexp.MustCompile(`\s+`)
+	displayWidth = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)( unsigned)?$`)
+	typeAliases  = map[string]string{
+		"bool":                        "boolean",
+		"integer":                     "int",
+		"int4":                        "int",
+		"int8":                        "bigint",
+		"int2":                        "smallint",
+		"bigserial":                   "bigint",
+		"serial":                      "int",
+		"character varying":           "varchar",
+		"character":                   "char",
+		"numeric":                     "decimal",
+		"double precision":            "double",
+		"float8":                      "double",
+		"float4":                      "real",
+		"timestamp without time zone": "timestamp",
+	}
+)
+
+// normalizeType maps the spellings of one type to a single form: lower case,
+// aliases resolved and MySQL integer display widths dropped. SQLite only
+// keeps a type affinity, so its types compare by affinity.
+func normalizeType(dialect sql.Dialect, typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(spaces.ReplaceAllString(typ, " ")))
+	typ = strings.ReplaceAll(typ, ", ", ",")
+	if dialect == sql.SQLite {
+		return affinity(typ)
+	}
+	if typ == "tinyint(1)" {
+		return "boolean"
+	}
+	if match := displayWidth.FindStringSubmatch(typ); match != nil {
+		typ = match[1] + match[2]
+	}
+	base, args := typ, ""
+	if i := strings.Index(typ, "("); i >= 0 {
+		base, args = strings.TrimSpace(typ[:i]), typ[i:]
+	}
+	if alias, ok := typeAliases[base]; ok {
+		base = alias
+	}
+	if dialect == sql.MySQL && base == "real" {
+		base = "double"
+	}
+	return base + args
+}
+
+// affinity applies the SQLite rules deciding the affinity of a declared type.
+func affinity(typ string) string {
+	switch {
+	case strings.Contains(typ, "int"):
+		return "integer"
+	case strings.Contains(typ, "char"), strings.Contains(typ, "clob"), strings.Contains(typ, "text"):
+		return "text"
+	case typ == "" || strings.Contains(typ, "blob"):
+		return "blob"
+	case strings.Contains(typ, "real"), strings.Contains(typ, "floa"), strings.Contains(typ, "doub"):
+		return "real"
+	}
+	return "numeric"
+}
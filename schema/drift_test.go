@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"bulk/db/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeType(t *testing.T) {
+	testCases := []struct {
+		Dialect  sql.Dialect
+		Expected string
+		Actual   string
+		Equal    bool
+	}{
+		{Dialect: sql.MySQL, Expected: "INTEGER", Actual: "int(11)", Equal: true},
+		{Dialect: sql.MySQL, Expected: "BIGINT", Actual: "bigint", Equal: true},
+		{Dialect: sql.MySQL, Expected: "BOOLEAN", Actual: "tinyint(1)", Equal: true},
+		{Dialect: sql.MySQL, Expected: "DECIMAL(12, 2)", Actual: "decimal(12,2)", Equal: true},
+		{Dialect: sql.MySQL, Expected: "REAL", Actual: "double", Equal: true},
+		{Dialect: sql.MySQL, Expected: "VARCHAR(64)", Actual: "varchar(255)", Equal: false},
+		{Dialect: sql.MySQL, Expected: "DATETIME(6)", Actual: "datetime", Equal: false},
+		{Dialect: sql.Postgres, Expected: "VARCHAR(64)", Actual: "character varying(64)", Equal: true},
+		{Dialect: sql.Postgres, Expected: "DOUBLE PRECISION", Actual: "double precision", Equal: true},
+		{Dialect: sql.Postgres, Expected: "TIMESTAMP", Actual: "timestamp without time zone", Equal: true},
+		{Dialect: sql.Postgres, Expected: "DECIMAL(12,2)", Actual: "numeric(12,2)", Equal: true},
+		{Dialect: sql.Postgres, Expected: "TEXT", Actual: "character varying(64)", Equal: false},
+		{Dialect: sql.SQLite, Expected: "VARCHAR(64)", Actual: "TEXT", Equal: true},
+		{Dialect: sql.SQLite, Expected: "BIGINT", Actual: "INTEGER", Equal: true},
+		{Dialect: sql.SQLite, Expected: "DATETIME", Actual: "TIMESTAMP", Equal: true},
+		{Dialect: sql.SQLite, Expected: "REAL", Actual: "TEXT", Equal: false},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			assert.Equal(t, tc.Equal, normalizeType(tc.Dialect, tc.Expected) == normalizeType(tc.Dialect, tc.Actual))
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	expected := sql.TableDef{
+		Name: "items",
+		Columns: []sql.ColumnDef{
+			{Name: "id", Type: "BIGINT", PrimaryKey: true, AutoIncrement: true},
+			{Name: "sku", Type: "VARCHAR(64)", NotNull: true, Unique: true},
+			{Name: "qty", Type: "INTEGER", NotNull: true},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes:    []sql.IndexDef{{Name: "idx_qty", Columns: []string{"qty"}}},
+	}
+	testCases := []struct {
+		Actual   sql.TableDef
+		Expected []Drift
+	}{
+		{
+			Actual: sql.TableDef{
+				Name: "items",
+				Columns: []sql.ColumnDef{
+					{Name: "id", Type: "bigint(20)", NotNull: true, PrimaryKey: true},
+					{Name: "sku", Type: "varchar(64)", NotNull: true},
+					{Name: "qty", Type: "int", NotNull: true},
+				},
+				PrimaryKey: []string{"id"},
+				Indexes: []sql.IndexDef{
+					{Name: "idx_qty", Columns: []string{"qty"}},
+					{Name: "uq_items_sku", Unique: true, Columns: []string{"sku"}},
+				},
+			},
+			Expected: []Drift{},
+		},
+		{
+			Actual: sql.TableDef{
+				Name: "items",
+				Columns: []sql.ColumnDef{
+					{Name: "id", Type: "int", NotNull: true, PrimaryKey: true},
+					{Name: "qty", Type: "int"},
+					{Name: "note", Type: "text"},
+				},
+				PrimaryKey: []string{"id"},
+				Indexes: []sql.IndexDef{
+					{Name: "idx_qty", Columns: []string{"qty", "id"}},
+					{Name: "idx_note", Columns: []string{"note"}},
+				},
+			},
+			Expected: []Drift{
+				{Table: "items", Kind: TypeMismatch, Name: "id", Expected: "BIGINT", Actual: "int"},
+				{Table: "items", Kind: MissingColumn, Name: "sku", Expected: "VARCHAR(64)"},
+				{Table: "items", Kind: NullMismatch, Name: "qty", Expected: "NOT NULL", Actual: "NULL"},
+				{Table: "items", Kind: ExtraColumn, Name: "note", Actual: "text"},
+				{Table: "items", Kind: IndexMismatch, Name: "idx_qty", Expected: "INDEX (qty)", Actual: "INDEX (qty, id)"},
+				{Table: "items", Kind: MissingIndex, Name: "sku", Expected: "UNIQUE INDEX (sku)"},
+				{Table: "items", Kind: ExtraIndex, Name: "idx_note", Actual: "INDEX (note)"},
+			},
+		},
+		{
+			Actual: sql.TableDef{
+				Name: "items",
+				Columns: []sql.ColumnDef{
+					{Name: "id", Type: "bigint", NotNull: true},
+					{Name: "sku", Type: "varchar(64)", NotNull: true, PrimaryKey: true},
+					{Name: "qty", Type: "int", NotNull: true},
+				},
+				PrimaryKey: []string{"sku"},
+				Indexes:    []sql.IndexDef{{Name: "uq_sku", Unique: true, Columns: []string{"sku"}}},
+			},
+			Expected: []Drift{
+				{Table: "items", Kind: PrimaryKeyDiff, Expected: "id", Actual: "sku"},
+				{Table: "items", Kind: MissingIndex, Name: "idx_qty", Expected: "INDEX (qty)"},
+			},
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			assert.Equal(t, tc.Expected, Compare(sql.MySQL, expected, tc.Actual))
+		})
+	}
+}
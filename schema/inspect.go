@@ -0,0 +1,138 @@
+package schema
+
+import (
+	"bulk/db/sql"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrTableNotFound = errors.New("table not found")
+
+type columnRow struct {
+	Name      string  `db:"name"`
+	Type      string  `db:"type"`
+	Length    *int64  `db:"length"`
+	Precision *int64  `db:"precision"`
+	Scale     *int64  `db:"scale"`
+	Nullable  bool    `db:"nullable"`
+	Primary   bool    `db:"pk"`
+	Default   *string `db:"dflt"`
+}
+
+type indexRow struct {
+	Name    string `db:"name"`
+	Unique  bool   `db:"is_unique"`
+	Primary bool   `db:"is_primary"`
+	Column  string `db:"column_name"`
+}
+
+const (
+	mysqlColumns = `SELECT COLUMN_NAME AS name, COLUMN_TYPE AS type, IS_NULLABLE = 'YES' AS nullable,
+	COLUMN_KEY = 'PRI' AS pk, COLUMN_DEFAULT AS dflt
+FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+ORDER BY ORDINAL_POSITION`
+	mysqlIndexes = `SELECT INDEX_NAME AS name, NON_UNIQUE = 0 AS is_unique, INDEX_NAME = 'PRIMARY' AS is_primary,
+	COLUMN_NAME AS column_name
+FROM information_schema.STATISTICS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+ORDER BY INDEX_NAME, SEQ_IN_INDEX`
+	postgresColumns = `SELECT column_name AS name, data_type AS type, character_maximum_length AS length,
+	numeric_precision AS precision, numeric_scale AS scale, is_nullable = 'YES' AS nullable,
+	false AS pk, column_default AS dflt
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = ?
+ORDER BY ordinal_position`
+	postgresIndexes = `SELECT i.relname AS name, x.indisunique AS is_unique, x.indisprimary AS is_primary,
+	a.attname AS column_name
+FROM pg_index x
+JOIN pg_class t ON t.oid = x.indrelid
+JOIN pg_class i ON i.oid = x.indexrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN LATERAL unnest(x.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE n.nspname = current_schema() AND t.relname = ?
+ORDER BY i.relname, k.ord`
+	sqliteColumns = `SELECT name, type, "notnull" = 0 AS nullable, pk > 0 AS pk, dflt_value AS dflt
+FROM pragma_table_info(?)
+ORDER BY cid`
+	sqliteIndexes = `SELECT l.name AS name, l."unique" AS is_unique, l.origin = 'pk' AS is_primary, i.name AS column_name
+FROM pragma_index_list(?) l
+JOIN pragma_index_info(l.name) i
+ORDER BY l.name, i.seqno`
+)
+
+// Inspect reads the definition of table from the live database: columns from
+// information_schema on MySQL and Postgres and from PRAGMA table_info on
+// SQLite, indexes from the dialect's catalog. Column types are reported as
+// the database spells them. It fails with ErrTableNotFound when the table
+// does not exist.
+func Inspect(ctx context.Context, db *sqlx.DB, table string) (sql.TableDef, error) {
+	columnsQuery, indexesQuery := mysqlColumns, mysqlIndexes
+	switch sql.DialectOf(db.DriverName()) {
+	case sql.Postgres:
+		columnsQuery, indexesQuery = postgresColumns, postgresIndexes
+	case sql.SQLite:
+		columnsQuery, indexesQuery = sqliteColumns, sqliteIndexes
+	}
+
+	columns := []columnRow{}
+	if err := db.SelectContext(ctx, &columns, db.Rebind(columnsQuery), table); err != nil {
+		return sql.TableDef{}, fmt.Errorf("failed inspect columns of %s: %w", table, err)
+	}
+	if len(columns) == 0 {
+		return sql.TableDef{}, fmt.Errorf("%s: %w", table, ErrTableNotFound)
+	}
+	indexes := []indexRow{}
+	if err := db.SelectContext(ctx, &indexes, db.Rebind(indexesQuery), table); err != nil {
+		return sql.TableDef{}, fmt.Errorf("failed inspect indexes of %s: %w", table, err)
+	}
+
+	def := sql.TableDef{Name: table}
+	for _, row := range columns {
+		column := sql.ColumnDef{Name: row.Name, Type: row.Type, NotNull: !row.Nullable, PrimaryKey: row.Primary, Default: row.Default}
+		if row.Length != nil {
+			column.Type += "(" + strconv.FormatInt(*row.Length, 10) + ")"
+		} else if row.Precision != nil && row.Scale != nil && (row.Type == "numeric" || row.Type == "decimal") {
+			column.Type += fmt.Sprintf("(%d,%d)", *row.Precision, *row.Scale)
+		}
+		def.Columns = append(def.Columns, column)
+		if row.Primary {
+			def.PrimaryKey = append(def.PrimaryKey, row.Name)
+		}
+	}
+	for _, row := range indexes {
+		if row.Primary {
+			// Postgres reports the primary key only as an index.
+			if _, ok := def.Column(row.Column); ok && !contains(def.PrimaryKey, row.Column) {
+				def.PrimaryKey = append(def.PrimaryKey, row.Column)
+				for i := range def.Columns {
+					if def.Columns[i].Name == row.Column {
+						def.Columns[i].PrimaryKey = true
+					}
+				}
+			}
+			continue
+		}
+		last := len(def.Indexes) - 1
+		if last < 0 || def.Indexes[last].Name != row.Name {
+			def.Indexes = append(def.Indexes, sql.IndexDef{Name: row.Name, Unique: row.Unique})
+			last++
+		}
+		def.Indexes[last].Columns = append(def.Indexes[last].Columns, row.Column)
+	}
+	return def, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
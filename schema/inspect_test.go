@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"bulk/audit"
+	"bulk/db/sql"
+	"bulk/migrate"
+	"bulk/outbox"
+	"bulk/repo"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Connect("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	db.MustExec("CREATE TABLE items (id INTEGER PRIMARY KEY, sku VARCHAR(64) NOT NULL UNIQUE, qty INTEGER DEFAULT 0)")
+	db.MustExec("CREATE INDEX idx_items_qty ON items (qty, sku)")
+
+	def, err := Inspect(ctx, db, "items")
+	assert.Nil(t, err)
+	zero := "0"
+	assert.Equal(t, sql.TableDef{
+		Name: "items",
+		Columns: []sql.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "sku", Type: "VARCHAR(64)", NotNull: true},
+			{Name: "qty", Type: "INTEGER", Default: &zero},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes: []sql.IndexDef{
+			{Name: "idx_items_qty", Columns: []string{"qty", "sku"}},
+			{Name: "sqlite_autoindex_items_1", Unique: true, Columns: []string{"sku"}},
+		},
+	}, def)
+
+	_, err = Inspect(ctx, db, "missing")
+	assert.True(t, errors.Is(err, ErrTableNotFound))
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	models := []Model{
+		{Table: repo.ProductTable, Model: repo.ProductModel{}},
+		{Table: repo.ReservationTable, Model: repo.ReservationModel{}},
+		{Table: outbox.Table, Model: outbox.EventModel{}},
+		{Table: audit.HistoryTable, Model: audit.HistoryModel{}},
+	}
+
+	t.Run("builtin migrations match the models", func(t *testing.T) {
+		db := newTestDB(t)
+		migrations, err := migrate.Builtin(sql.SQLite)
+		assert.Nil(t, err)
+		m, err := migrate.New(db, migrations)
+		assert.Nil(t, err)
+		_, err = m.Up(ctx)
+		assert.Nil(t, err)
+		drifts, err := Check(ctx, db, models...)
+		assert.Nil(t, err)
+		assert.Empty(t, drifts)
+		assert.Nil(t, Verify(ctx, db, models...))
+	})
+
+	t.Run("drift fails verify", func(t *testing.T) {
+		db := newTestDB(t)
+		db.MustExec("CREATE TABLE products (id INTEGER PRIMARY KEY, sku TEXT NOT NULL UNIQUE, name TEXT, qty INTEGER, version INTEGER NOT NULL, created_at DATETIME, updated_at DATETIME, deleted_at DATETIME)")
+		drifts, err := Check(ctx, db, models[0], models[2])
+		assert.Nil(t, err)
+		assert.Equal(t, []Drift{
+			{Table: "products", Kind: MissingColumn, Name: "price", Expected: "REAL"},
+			{Table: "products", Kind: NullMismatch, Name: "qty", Expected: "NOT NULL", Actual: "NULL"},
+			{Table: "outbox", Kind: MissingTable},
+		}, drifts)
+		err = Verify(ctx, db, models...)
+		assert.True(t, errors.Is(err, ErrDrift))
+		assert.Contains(t, err.Error(), "products.price: missing_column")
+	})
+}
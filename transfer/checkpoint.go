@@ -0,0 +1,62 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Checkpoint interface {
+	Load() (line int, err error)
+	Save(line int) error
+}
+
+// FileCheckpoint keeps the last imported line in a file, replaced atomically
+// on every save so a crash never leaves a partial value behind.
+type FileCheckpoint string
+
+func (f FileCheckpoint) Load() (int, error) {
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	line, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint %s: %w", string(f), err)
+	}
+	return line, nil
+}
+
+func (f FileCheckpoint) Save(line int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(string(f)), filepath.Base(string(f))+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strconv.Itoa(line)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), string(f))
+}
+
+func (f FileCheckpoint) Clear() error {
+	err := os.Remove(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package transfer
+
+import (
+	"bulk/db/sql"
+	"bulk/utils"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// RowWriter streams records of fixed columns to w in one of the export
+// formats. NULL is an empty CSV cell or a JSON null, floats are always written
+// in plain decimal notation.
+type RowWriter struct {
+	format  Format
+	columns []string
+	w       io.Writer
+	csv     *csv.Writer
+	rows    int
+}
+
+func NewRowWriter(w io.Writer, format Format, columns []string) (*RowWriter, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("columns required")
+	}
+	rw := &RowWriter{format: format, columns: columns, w: w}
+	switch format {
+	case FormatCSV:
+		rw.csv = csv.NewWriter(w)
+		if err := rw.csv.Write(columns); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return nil, err
+		}
+	case FormatNDJSON:
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return rw, nil
+}
+
+func (rw *RowWriter) Rows() int {
+	return rw.rows
+}
+
+// WriteStruct writes the columns of a db-tagged struct; nil pointers are NULL.
+func (rw *RowWriter) WriteStruct(row any) error {
+	values, err := utils.StructToMap(row, sql.Tag)
+	if err != nil {
+		return fmt.Errorf("failed map row: %w", err)
+	}
+	return rw.Write(values)
+}
+
+func (rw *RowWriter) Write(values map[string]any) error {
+	var err error
+	if rw.format == FormatCSV {
+		err = rw.writeCSV(values)
+	} else {
+		err = rw.writeJSON(values)
+	}
+	if err != nil {
+		return fmt.Errorf("failed write row %d: %w", rw.rows+1, err)
+	}
+	rw.rows++
+	return nil
+}
+
+func (rw *RowWriter) writeCSV(values map[string]any) error {
+	row := make([]string, 0, len(rw.columns))
+	for _, column := range rw.columns {
+		cell, err := formatText(values[column])
+		if err != nil {
+			return fmt.Errorf("%s: %w", column, err)
+		}
+		row = append(row, cell)
+	}
+	return rw.csv.Write(row)
+}
+
+func (rw *RowWriter) writeJSON(values map[string]any) error {
+	buf := bytes.Buffer{}
+	if rw.format == FormatJSON {
+		if rw.rows > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+	}
+	buf.WriteString("{")
+	for i, column := range rw.columns {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(column)
+		buf.Write(key)
+		buf.WriteString(":")
+		value, err := formatJSON(values[column])
+		if err != nil {
+			return fmt.Errorf("%s: %w", column, err)
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	if rw.format == FormatNDJSON {
+		buf.WriteString("\n")
+	}
+	_, err := rw.w.Write(buf.Bytes())
+	return err
+}
+
+// Close finishes the document; it does not close the underlying writer.
+func (rw *RowWriter) Close() error {
+	switch rw.format {
+	case FormatCSV:
+		rw.csv.Flush()
+		return rw.csv.Error()
+	case FormatJSON:
+		end := "]\n"
+		if rw.rows > 0 {
+			end = "\n]\n"
+		}
+		_, err := io.WriteString(rw.w, end)
+		return err
+	}
+	return nil
+}
+
+func formatFloat(v float64) (string, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", fmt.Errorf("cannot export %v", v)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64), nil
+}
+
+func formatText(v any) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case float64:
+		return formatFloat(val)
+	case float32:
+		return formatFloat(float64(val))
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	case []byte:
+		return string(val), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func formatJSON(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case float64:
+		str, err := formatFloat(val)
+		return []byte(str), err
+	case float32:
+		str, err := formatFloat(float64(val))
+		return []byte(str), err
+	case []byte:
+		return json.Marshal(string(val))
+	}
+	return json.Marshal(v)
+}
+
+// Export writes every row produced by stream, which calls its callback once
+// per row, and returns the number of rows written.
+func Export[Model any](w io.Writer, format Format, columns []string, stream func(fn func(Model) error) error) (int, error) {
+	rw, err := NewRowWriter(w, format, columns)
+	if err != nil {
+		return 0, err
+	}
+	if err := stream(func(row Model) error { return rw.WriteStruct(row) }); err != nil {
+		return rw.Rows(), err
+	}
+	if err := rw.Close(); err != nil {
+		return rw.Rows(), err
+	}
+	return rw.Rows(), nil
+}
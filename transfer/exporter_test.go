@@ -0,0 +1,85 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type exportRow struct {
+	ID    *int     `db:"id"`
+	SKU   *string  `db:"sku"`
+	Price *float64 `db:"price"`
+}
+
+func TestExport(t *testing.T) {
+	id := 1
+	sku := "a,b"
+	price := 1e21
+	rows := []exportRow{{ID: &id, SKU: &sku, Price: &price}, {ID: &id}}
+	stream := func(rows []exportRow) func(fn func(exportRow) error) error {
+		return func(fn func(exportRow) error) error {
+			for _, row := range rows {
+				if err := fn(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		testCases := []struct {
+			Format   Format
+			Rows     []exportRow
+			Expected string
+		}{
+			{
+				Format:   FormatCSV,
+				Rows:     rows,
+				Expected: "sku,price\n\"a,b\",1000000000000000000000\n,\n",
+			},
+			{
+				Format:   FormatNDJSON,
+				Rows:     rows,
+				Expected: "{\"sku\":\"a,b\",\"price\":1000000000000000000000}\n{\"sku\":null,\"price\":null}\n",
+			},
+			{
+				Format:   FormatJSON,
+				Rows:     rows,
+				Expected: "[\n  {\"sku\":\"a,b\",\"price\":1000000000000000000000},\n  {\"sku\":null,\"price\":null}\n]\n",
+			},
+			{
+				Format:   FormatJSON,
+				Expected: "[]\n",
+			},
+		}
+
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				buf := bytes.Buffer{}
+				count, err := Export(&buf, tc.Format, []string{"sku", "price"}, stream(tc.Rows))
+				assert.Nil(t, err)
+				assert.Equal(t, len(tc.Rows), count)
+				assert.Equal(t, tc.Expected, buf.String())
+			})
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		nan := math.NaN()
+		_, err := Export(&bytes.Buffer{}, FormatCSV, []string{"price"}, stream([]exportRow{{Price: &nan}}))
+		assert.NotNil(t, err)
+
+		_, err = Export(&bytes.Buffer{}, "xml", []string{"price"}, stream(rows))
+		assert.NotNil(t, err)
+
+		streamErr := errors.New("stream failed")
+		_, err = Export(&bytes.Buffer{}, FormatCSV, []string{"id"}, func(fn func(exportRow) error) error { return streamErr })
+		assert.ErrorIs(t, err, streamErr)
+	})
+}
@@ -0,0 +1,192 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatJSON   Format = "json"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case FormatCSV, FormatNDJSON, FormatJSON:
+		return format, nil
+	case "jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("unknown format %q", value)
+}
+
+// DetectFormat returns format when given, otherwise guesses it from the file
+// extension.
+func DetectFormat(format, file string) (Format, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+	return ParseFormat(format)
+}
+
+// record is one input row keyed by column; a nil value means NULL.
+type record struct {
+	line   int
+	values map[string]*string
+}
+
+type recordReader interface {
+	next() (record, error)
+}
+
+func newRecordReader(format Format, r io.Reader) (recordReader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.ReuseRecord = true
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed read header: %w", err)
+		}
+		columns := make([]string, 0, len(header))
+		for _, column := range header {
+			columns = append(columns, strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		}
+		return &csvReader{reader: reader, header: columns, line: 1}, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		return &ndjsonReader{scanner: scanner}, nil
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed read json: %w", err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, errors.New("json input must be an array")
+		}
+		return &jsonReader{decoder: decoder}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+	line   int
+}
+
+func (r *csvReader) next() (record, error) {
+	row, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		r.line = parseErr.StartLine
+		return record{line: r.line}, &rowError{reason: fmt.Sprintf("invalid csv: %v", parseErr.Err)}
+	}
+	if err != nil {
+		return record{}, err
+	}
+	r.line, _ = r.reader.FieldPos(0)
+	rec := record{line: r.line, values: map[string]*string{}}
+	if len(row) > len(r.header) {
+		return rec, &rowError{reason: fmt.Sprintf("%d fields, header has %d", len(row), len(r.header))}
+	}
+	for i, column := range r.header {
+		if i >= len(row) || row[i] == "" {
+			rec.values[column] = nil
+			continue
+		}
+		value := row[i]
+		rec.values[column] = &value
+	}
+	return rec, nil
+}
+
+func (r *csvReader) columns() []string {
+	return r.header
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) next() (record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		raw := bytes.TrimSpace(r.scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		values, err := decodeObject(raw)
+		return record{line: r.line, values: values}, err
+	}
+	if err := r.scanner.Err(); err != nil {
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
+
+type jsonReader struct {
+	decoder *json.Decoder
+	index   int
+}
+
+func (r *jsonReader) next() (record, error) {
+	if !r.decoder.More() {
+		return record{}, io.EOF
+	}
+	r.index++
+	raw := json.RawMessage{}
+	if err := r.decoder.Decode(&raw); err != nil {
+		return record{}, fmt.Errorf("failed read item %d: %w", r.index, err)
+	}
+	values, err := decodeObject(raw)
+	return record{line: r.index, values: values}, err
+}
+
+// decodeObject flattens a JSON object to column strings, so both text formats
+// go through the same conversion as CSV cells.
+func decodeObject(raw []byte) (map[string]*string, error) {
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, &rowError{reason: fmt.Sprintf("invalid json object: %v", err)}
+	}
+	values := map[string]*string{}
+	for key, value := range obj {
+		value = bytes.TrimSpace(value)
+		if bytes.Equal(value, []byte("null")) {
+			values[key] = nil
+			continue
+		}
+		str := string(value)
+		if len(value) > 0 && value[0] == '"' {
+			if err := json.Unmarshal(value, &str); err != nil {
+				return nil, &rowError{reason: fmt.Sprintf("%s: invalid string", key)}
+			}
+		}
+		values[key] = &str
+	}
+	return values, nil
+}
+
+// rowError rejects a single row without stopping the import.
+type rowError struct {
+	reason string
+}
+
+func (e *rowError) Error() string {
+	return e.reason
+}
@@ -0,0 +1,260 @@
+package transfer
+
+import (
+	"bulk/db/sql"
+	"bulk/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+type Mode string
+
+const (
+	ModeInsert Mode = "insert"
+	ModeUpsert Mode = "upsert"
+)
+
+const DefaultBatchSize = 1000
+
+// Sink receives validated rows in batches.
+type Sink[Payload any] interface {
+	Insert(ctx context.Context, rows []Payload) error
+	Upsert(ctx context.Context, rows []Payload) error
+}
+
+type Rejection struct {
+	Line   int    `json:"line" db:"line"`
+	Reason string `json:"reason" db:"reason"`
+}
+
+type Report struct {
+	Rows     int         `json:"rows"`
+	Imported int         `json:"imported"`
+	Skipped  int         `json:"skipped"`
+	Rejected []Rejection `json:"rejected"`
+}
+
+type ImportOptions[Payload any] struct {
+	Format    Format
+	Mode      Mode
+	BatchSize int
+
+	// Checkpoint, when set, records the last line of every flushed batch so
+	// a rerun skips rows that were already imported.
+	Checkpoint Checkpoint
+
+	// Validate checks a converted row; an error rejects the row.
+	Validate func(Payload) error
+
+	// OnReject is called for each rejected row as soon as it is known.
+	OnReject func(Rejection)
+}
+
+type Importer[Payload any] struct {
+	sink    Sink[Payload]
+	options ImportOptions[Payload]
+	fields  map[string]int
+}
+
+func NewImporter[Payload any](sink Sink[Payload], options ImportOptions[Payload]) *Importer[Payload] {
+	if options.Format == "" {
+		options.Format = FormatCSV
+	}
+	if options.Mode == "" {
+		options.Mode = ModeInsert
+	}
+	if options.BatchSize < 1 {
+		options.BatchSize = DefaultBatchSize
+	}
+	fields := map[string]int{}
+	rt := reflect.TypeOf((*Payload)(nil)).Elem()
+	for i := 0; i < rt.NumField(); i++ {
+		name, _ := utils.ParseTag(rt.Field(i).Tag.Get(sql.Tag))
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = i
+	}
+	return &Importer[Payload]{sink: sink, options: options, fields: fields}
+}
+
+type pending[Payload any] struct {
+	line    int
+	payload Payload
+}
+
+func (i *Importer[Payload]) Import(ctx context.Context, r io.Reader) (report Report, err error) {
+	report = Report{Rejected: []Rejection{}}
+	if i.options.Mode != ModeInsert && i.options.Mode != ModeUpsert {
+		return report, fmt.Errorf("unknown mode %q", i.options.Mode)
+	}
+	reader, err := newRecordReader(i.options.Format, r)
+	if err != nil {
+		return report, err
+	}
+	if csv, ok := reader.(*csvReader); ok {
+		for _, column := range csv.columns() {
+			if _, ok := i.fields[column]; !ok {
+				return report, fmt.Errorf("unknown column %q", column)
+			}
+		}
+	}
+
+	checkpoint := 0
+	if i.options.Checkpoint != nil {
+		checkpoint, err = i.options.Checkpoint.Load()
+		if err != nil {
+			return report, fmt.Errorf("failed load checkpoint: %w", err)
+		}
+	}
+
+	batch := []pending[Payload]{}
+	lastLine := checkpoint
+	flush := func() error {
+		if err := i.flush(ctx, batch, &report); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if i.options.Checkpoint != nil && lastLine > checkpoint {
+			if err := i.options.Checkpoint.Save(lastLine); err != nil {
+				return fmt.Errorf("failed save checkpoint: %w", err)
+			}
+			checkpoint = lastLine
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		rec, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *rowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return report, fmt.Errorf("failed read input: %w", err)
+		}
+		report.Rows++
+		if rec.line <= checkpoint {
+			report.Skipped++
+			continue
+		}
+		lastLine = rec.line
+		if rowErr != nil {
+			i.reject(&report, rec.line, rowErr.reason)
+			continue
+		}
+
+		payload, err := i.convert(rec)
+		if err == nil && i.options.Validate != nil {
+			err = i.options.Validate(payload)
+		}
+		if err != nil {
+			i.reject(&report, rec.line, err.Error())
+			continue
+		}
+		batch = append(batch, pending[Payload]{line: rec.line, payload: payload})
+		if len(batch) >= i.options.BatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// flush writes a batch; when the batch fails on the data of a row, rows are
+// retried one by one so only the offending lines are rejected. Other errors,
+// e.g. a lost connection or a cancelled ctx, fail the import before the
+// checkpoint moves past the batch.
+func (i *Importer[Payload]) flush(ctx context.Context, batch []pending[Payload], report *Report) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	rows := make([]Payload, 0, len(batch))
+	for _, item := range batch {
+		rows = append(rows, item.payload)
+	}
+	err := i.write(ctx, rows)
+	if err == nil {
+		report.Imported += len(rows)
+		return nil
+	}
+	if !isRowError(ctx, err) {
+		return fmt.Errorf("failed write batch: %w", err)
+	}
+	for _, item := range batch {
+		if err := i.write(ctx, []Payload{item.payload}); err != nil {
+			if !isRowError(ctx, err) {
+				return fmt.Errorf("failed write line %d: %w", item.line, err)
+			}
+			i.reject(report, item.line, err.Error())
+			continue
+		}
+		report.Imported++
+	}
+	return nil
+}
+
+// isRowError reports whether err rejects the data of a row rather than the
+// write itself: invalid rows, failing row hooks and constraint violations.
+func isRowError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rowErr *sql.RowError
+	var validationErrs utils.ValidationErrors
+	return errors.As(err, &rowErr) || errors.As(err, &validationErrs) || sql.IsConstraintError(err)
+}
+
+func (i *Importer[Payload]) write(ctx context.Context, rows []Payload) error {
+	if i.options.Mode == ModeUpsert {
+		return i.sink.Upsert(ctx, rows)
+	}
+	return i.sink.Insert(ctx, rows)
+}
+
+func (i *Importer[Payload]) reject(report *Report, line int, reason string) {
+	rejection := Rejection{Line: line, Reason: reason}
+	report.Rejected = append(report.Rejected, rejection)
+	if i.options.OnReject != nil {
+		i.options.OnReject(rejection)
+	}
+}
+
+func (i *Importer[Payload]) convert(rec record) (payload Payload, err error) {
+	rv := reflect.ValueOf(&payload).Elem()
+	fieldErrs := utils.ValidationErrors{}
+	columns := make([]string, 0, len(rec.values))
+	for column := range rec.values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		value := rec.values[column]
+		index, ok := i.fields[column]
+		if !ok {
+			fieldErrs = append(fieldErrs, utils.FieldError{Field: column, Message: "unknown column"})
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		if err := utils.SetFromString(rv.Field(index), *value); err != nil {
+			fieldErrs = append(fieldErrs, utils.FieldError{Field: column, Message: err.Error()})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return payload, fieldErrs
+	}
+	return payload, nil
+}
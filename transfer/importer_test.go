@@ -0,0 +1,189 @@
+package transfer
+
+import (
+	"bulk/db/sql"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type row struct {
+	SKU   *string  `db:"sku"`
+	Price *float64 `db:"price"`
+	Qty   *int     `db:"qty"`
+}
+
+type fakeSink struct {
+	batches  [][]row
+	upserted []row
+	failSKU  string
+	err      error
+}
+
+func (s *fakeSink) Insert(ctx context.Context, rows []row) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, r := range rows {
+		if r.SKU != nil && *r.SKU == s.failSKU {
+			return &sql.DuplicateKeyError{Key: "sku", Value: s.failSKU, Err: errors.New("duplicate sku")}
+		}
+	}
+	s.batches = append(s.batches, append([]row{}, rows...))
+	return nil
+}
+
+func (s *fakeSink) Upsert(ctx context.Context, rows []row) error {
+	s.upserted = append(s.upserted, rows...)
+	return nil
+}
+
+func (s *fakeSink) rows() []string {
+	result := []string{}
+	for _, batch := range s.batches {
+		for _, r := range batch {
+			result = append(result, *r.SKU)
+		}
+	}
+	return result
+}
+
+func TestImporter(t *testing.T) {
+	csvInput := "sku,price,qty\na,1.5,1\nb,x,2\nc,,3\nd,4,-1\ne,5,5\n"
+	validate := func(r row) error {
+		if r.Qty != nil && *r.Qty < 0 {
+			return errors.New("qty must not be negative")
+		}
+		return nil
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		sink := &fakeSink{failSKU: "e"}
+		importer := NewImporter[row](sink, ImportOptions[row]{Format: FormatCSV, BatchSize: 2, Validate: validate})
+		report, err := importer.Import(context.Background(), strings.NewReader(csvInput))
+		assert.Nil(t, err)
+		assert.Equal(t, 5, report.Rows)
+		assert.Equal(t, 2, report.Imported)
+		assert.Equal(t, []string{"a", "c"}, sink.rows())
+		assert.Equal(t, []Rejection{
+			{Line: 3, Reason: "validation failed: price: must be a number"},
+			{Line: 5, Reason: "qty must not be negative"},
+			{Line: 6, Reason: `duplicate key "sku" for "e": duplicate sku`},
+		}, report.Rejected)
+		assert.Nil(t, sink.batches[0][1].Price)
+	})
+
+	t.Run("csv ragged rows", func(t *testing.T) {
+		sink := &fakeSink{}
+		importer := NewImporter[row](sink, ImportOptions[row]{Format: FormatCSV})
+		report, err := importer.Import(context.Background(), strings.NewReader("sku,price,qty\na,1,1\nb,2\nc,3,3,9\nd,\"4\"x,4\ne,5,5\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, 5, report.Rows)
+		assert.Equal(t, 3, report.Imported)
+		assert.Equal(t, []string{"a", "b", "e"}, sink.rows())
+		assert.Equal(t, []Rejection{
+			{Line: 4, Reason: "4 fields, header has 3"},
+			{Line: 5, Reason: `invalid csv: extraneous or missing " in quoted-field`},
+		}, report.Rejected)
+		assert.Nil(t, sink.batches[0][1].Qty)
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		importer := NewImporter[row](&fakeSink{}, ImportOptions[row]{})
+		_, err := importer.Import(context.Background(), strings.NewReader("sku,color\na,red\n"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ndjson upsert", func(t *testing.T) {
+		input := `{"sku":"a","price":1.5}
+
+{"sku":"b","qty":"x"}
+not json
+{"sku":"c","qty":null}
+`
+		sink := &fakeSink{}
+		importer := NewImporter[row](sink, ImportOptions[row]{Format: FormatNDJSON, Mode: ModeUpsert})
+		report, err := importer.Import(context.Background(), strings.NewReader(input))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, report.Imported)
+		assert.Len(t, sink.upserted, 2)
+		assert.Equal(t, 1.5, *sink.upserted[0].Price)
+		assert.Nil(t, sink.upserted[1].Qty)
+		assert.Equal(t, []int{3, 4}, []int{report.Rejected[0].Line, report.Rejected[1].Line})
+	})
+
+	t.Run("json array", func(t *testing.T) {
+		sink := &fakeSink{}
+		importer := NewImporter[row](sink, ImportOptions[row]{Format: FormatJSON})
+		report, err := importer.Import(context.Background(), strings.NewReader(`[{"sku":"a"},{"sku":"b","qty":2}]`))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, report.Imported)
+		assert.Equal(t, []string{"a", "b"}, sink.rows())
+	})
+
+	t.Run("resume from checkpoint", func(t *testing.T) {
+		checkpoint := FileCheckpoint(filepath.Join(t.TempDir(), "import.checkpoint"))
+		input := "sku\na\nb\nc\nd\n"
+
+		// Stop right after the first batch is written
+		ctx, cancel := context.WithCancel(context.Background())
+		stopping := &stoppingSink{fakeSink: &fakeSink{}, stop: cancel}
+		importer := NewImporter[row](stopping, ImportOptions[row]{BatchSize: 2, Checkpoint: checkpoint})
+		_, err := importer.Import(ctx, strings.NewReader(input))
+		assert.ErrorIs(t, err, context.Canceled)
+		line, err := checkpoint.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, line)
+
+		sink := &fakeSink{}
+		importer = NewImporter[row](sink, ImportOptions[row]{BatchSize: 2, Checkpoint: checkpoint})
+		report, err := importer.Import(context.Background(), strings.NewReader(input))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, report.Skipped)
+		assert.Equal(t, []string{"c", "d"}, sink.rows())
+		line, _ = checkpoint.Load()
+		assert.Equal(t, 5, line)
+	})
+
+	t.Run("failed write keeps the checkpoint", func(t *testing.T) {
+		checkpoint := FileCheckpoint(filepath.Join(t.TempDir(), "import.checkpoint"))
+		lost := errors.New("connection lost")
+		importer := NewImporter[row](&fakeSink{err: lost}, ImportOptions[row]{BatchSize: 2, Checkpoint: checkpoint})
+		report, err := importer.Import(context.Background(), strings.NewReader("sku\na\nb\nc\n"))
+		assert.ErrorIs(t, err, lost)
+		assert.Empty(t, report.Rejected)
+		assert.Equal(t, 0, report.Imported)
+		line, err := checkpoint.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, line)
+	})
+
+	t.Run("resume skips rejected lines", func(t *testing.T) {
+		checkpoint := FileCheckpoint(filepath.Join(t.TempDir(), "import.checkpoint"))
+		input := "{\"sku\":\"a\"}\nnot json\n{\"sku\":\"b\"}\n"
+		importer := NewImporter[row](&fakeSink{}, ImportOptions[row]{Format: FormatNDJSON, BatchSize: 2, Checkpoint: checkpoint})
+		report, err := importer.Import(context.Background(), strings.NewReader(input))
+		assert.Nil(t, err)
+		assert.Len(t, report.Rejected, 1)
+
+		report, err = importer.Import(context.Background(), strings.NewReader(input+"{\"sku\":\"c\"}\n"))
+		assert.Nil(t, err)
+		assert.Empty(t, report.Rejected)
+		assert.Equal(t, 3, report.Skipped)
+		assert.Equal(t, 1, report.Imported)
+	})
+}
+
+type stoppingSink struct {
+	*fakeSink
+	stop func()
+}
+
+func (s *stoppingSink) Insert(ctx context.Context, rows []row) error {
+	defer s.stop()
+	return s.fakeSink.Insert(ctx, rows)
+}
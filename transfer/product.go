@@ -0,0 +1,82 @@
+package transfer
+
+import (
+	"bulk/db/sql"
+	"bulk/repo"
+	"bulk/utils"
+	"context"
+	"fmt"
+	"io"
+)
+
+type productSink struct {
+	repo repo.ProductRepo
+}
+
+// ProductSink writes imported rows through ProductRepo. Upsert matches rows
+// on SKU: existing SKUs go through UpdateBulk, the rest through CreateBulk.
+func ProductSink(r repo.ProductRepo) Sink[repo.ProductPayload] {
+	return &productSink{repo: r}
+}
+
+func (s *productSink) Insert(ctx context.Context, rows []repo.ProductPayload) error {
+	if _, err := s.repo.CreateBulk(ctx, rows); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *productSink) Upsert(ctx context.Context, rows []repo.ProductPayload) error {
+	rows = append([]repo.ProductPayload{}, rows...)
+	SKUs := []string{}
+	for idx, row := range rows {
+		if row.SKU == nil {
+			return &sql.RowError{Index: idx, Err: utils.ValidationErrors{{Field: "sku", Message: "required for upsert"}}}
+		}
+		SKU := repo.NormalizeSKU(*row.SKU)
+		rows[idx].SKU = &SKU
+		SKUs = append(SKUs, SKU)
+	}
+	result, err := s.repo.Select(ctx, sql.Names(repo.ProductCols.SKU), &repo.ProductCondition{SKUs: &SKUs}, nil)
+	if err != nil {
+		return fmt.Errorf("failed find existing skus: %w", err)
+	}
+	existing := map[string]bool{}
+	for _, item := range result.Data {
+		existing[*item.SKU] = true
+	}
+
+	creates := []repo.ProductPayload{}
+	updates := []sql.Update[repo.ProductPayload, repo.ProductCondition]{}
+	for _, row := range rows {
+		if !existing[*row.SKU] {
+			creates = append(creates, row)
+			continue
+		}
+		SKU := *row.SKU
+		updates = append(updates, sql.Update[repo.ProductPayload, repo.ProductCondition]{
+			Payload:   row,
+			Condition: repo.ProductCondition{SKU: &SKU},
+		})
+	}
+	if len(updates) > 0 {
+		if _, err := s.repo.UpdateBulk(ctx, updates); err != nil {
+			return err
+		}
+	}
+	if len(creates) > 0 {
+		if _, err := s.repo.CreateBulk(ctx, creates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportProducts streams the products matching condition, ordered by id, as
+// the given columns.
+func ExportProducts(ctx context.Context, r repo.ProductRepo, w io.Writer, format Format, columns []string, condition repo.ProductCondition) (int, error) {
+	paginate := &utils.Paginate{Sort: []utils.Sort{repo.ProductCols.ID.Asc()}}
+	return Export(w, format, columns, func(fn func(repo.ProductModel) error) error {
+		return r.Stream(ctx, append([]string{}, columns...), &condition, paginate, fn)
+	})
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+func SetFromString(target reflect.Value, raw string) error {
+	if target.Kind() == reflect.Ptr {
+		ptr := reflect.New(target.Type().Elem())
+		if err := SetFromString(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		target.Set(ptr)
+		return nil
+	}
+	if target.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return errors.New("must be a RFC3339 time")
+		}
+		target.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		target.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, target.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		target.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, target.Type().Bits())
+		if err != nil {
+			return errors.New("must be a non-negative integer")
+		}
+		target.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, target.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		target.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", target.Type())
+	}
+	return nil
+}
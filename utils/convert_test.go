@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetFromString(t *testing.T) {
+	type target struct {
+		Int   *int
+		Float float64
+		Bool  bool
+		Time  time.Time
+		Uint  uint8
+	}
+
+	t.Run("success", func(t *testing.T) {
+		v := target{}
+		rv := reflect.ValueOf(&v).Elem()
+		assert.Nil(t, SetFromString(rv.Field(0), "12"))
+		assert.Nil(t, SetFromString(rv.Field(1), "1.5"))
+		assert.Nil(t, SetFromString(rv.Field(2), "true"))
+		assert.Nil(t, SetFromString(rv.Field(3), "2023-01-02T03:04:05Z"))
+		assert.Nil(t, SetFromString(rv.Field(4), "255"))
+		assert.Equal(t, 12, *v.Int)
+		assert.Equal(t, 1.5, v.Float)
+		assert.True(t, v.Bool)
+		assert.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), v.Time)
+		assert.Equal(t, uint8(255), v.Uint)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		testCases := []struct {
+			Field int
+			Raw   string
+		}{
+			{Field: 0, Raw: "1.5"},
+			{Field: 1, Raw: "abc"},
+			{Field: 2, Raw: "yes"},
+			{Field: 3, Raw: "2023-01-02"},
+			{Field: 4, Raw: "256"},
+		}
+		for i, tc := range testCases {
+			t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+				v := target{}
+				err := SetFromString(reflect.ValueOf(&v).Elem().Field(tc.Field), tc.Raw)
+				assert.NotNil(t, err)
+			})
+		}
+	})
+}
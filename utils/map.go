@@ -4,10 +4,60 @@ import (
 	"errors"
 	"reflect"
 	"sort"
+	"strings"
 )
 
-func StructToMap(payload any, tag string) (map[string]any, error) {
-	result := map[string]any{}
+type StructField struct {
+	Name    string
+	Options map[string]string
+	Field   reflect.StructField
+	Value   any
+}
+
+func (f StructField) HasOption(option string) bool {
+	_, ok := f.Options[option]
+	return ok
+}
+
+// ParseTag splits a tag into its name and key=value options. Commas inside
+// parentheses or single quotes do not separate options, e.g.
+// type=DECIMAL(12,2) or default='a,b'.
+func ParseTag(tag string) (name string, options map[string]string) {
+	options = map[string]string{}
+	parts := splitTag(tag)
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(part, "=")
+		options[key] = val
+	}
+	return strings.TrimSpace(parts[0]), options
+}
+
+func splitTag(tag string) []string {
+	parts := []string{}
+	depth, quoted, start := 0, false, 0
+	for i, r := range tag {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, tag[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, tag[start:])
+}
+
+func StructFields(payload any, tag string) ([]StructField, error) {
+	result := []StructField{}
 	v := reflect.ValueOf(payload)
 	if tag == "" {
 		return result, errors.New("tag is required")
@@ -19,18 +69,35 @@ func StructToMap(payload any, tag string) (map[string]any, error) {
 		valueField := v.Field(i)
 		typeField := v.Type().Field(i)
 
-		fieldName := typeField.Tag.Get(tag)
-		if fieldName == "" {
+		fieldName, options := ParseTag(typeField.Tag.Get(tag))
+		if fieldName == "" || fieldName == "-" {
 			continue
 		}
 
-		if valueField.Kind() == reflect.Ptr {
+		if valueField.Kind() == reflect.Ptr || valueField.Kind() == reflect.Interface {
 			if valueField.IsNil() {
 				continue
 			}
 			valueField = valueField.Elem()
 		}
-		result[fieldName] = valueField.Interface()
+		result = append(result, StructField{
+			Name:    fieldName,
+			Options: options,
+			Field:   typeField,
+			Value:   valueField.Interface(),
+		})
+	}
+	return result, nil
+}
+
+func StructToMap(payload any, tag string) (map[string]any, error) {
+	result := map[string]any{}
+	fields, err := StructFields(payload, tag)
+	if err != nil {
+		return result, err
+	}
+	for _, field := range fields {
+		result[field.Name] = field.Value
 	}
 	return result, nil
 }
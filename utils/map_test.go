@@ -63,3 +63,47 @@ func TestSortMapKeys(t *testing.T) {
 	actual := SortMapKeys(data)
 	assert.Equal(t, expected, actual)
 }
+
+func TestParseTag(t *testing.T) {
+	testCases := []struct {
+		Tag     string
+		Name    string
+		Options map[string]string
+	}{
+		{Tag: "id", Name: "id", Options: map[string]string{}},
+		{Tag: "price,gte", Name: "price", Options: map[string]string{"gte": ""}},
+		{Tag: "sku, unique ,index=idx_sku", Name: "sku", Options: map[string]string{"unique": "", "index": "idx_sku"}},
+		{Tag: "price,type=DECIMAL(12,2),default=0", Name: "price", Options: map[string]string{"type": "DECIMAL(12,2)", "default": "0"}},
+		{Tag: "note,default='a,(b'", Name: "note", Options: map[string]string{"default": "'a,(b'"}},
+		{Tag: "", Name: "", Options: map[string]string{}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			name, options := ParseTag(tc.Tag)
+			assert.Equal(t, tc.Name, name)
+			assert.Equal(t, tc.Options, options)
+		})
+	}
+}
+
+func TestStructFields(t *testing.T) {
+	type tagged struct {
+		Field1 *int    `db:"f1,gte"`
+		Field2 *string `db:"f2"`
+		Field3 string  `db:"-"`
+		Field4 any     `db:"f4"`
+	}
+	v := 1
+	actual, err := StructFields(tagged{Field1: &v, Field3: "skip"}, "db")
+	assert.Nil(t, err)
+	assert.Len(t, actual, 1)
+	assert.Equal(t, "f1", actual[0].Name)
+	assert.True(t, actual[0].HasOption("gte"))
+	assert.Equal(t, 1, actual[0].Value)
+
+	actual, err = StructFields(tagged{Field4: "any"}, "db")
+	assert.Nil(t, err)
+	assert.Len(t, actual, 1)
+	assert.Equal(t, "any", actual[0].Value)
+}
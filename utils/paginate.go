@@ -1,13 +1,36 @@
 package utils
 
+import "strings"
+
 const (
 	DefaultPage  = 1
 	DefaultLimit = 10
 )
 
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+func ParseSort(value string) Sort {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "-") {
+		return Sort{Field: strings.TrimPrefix(value, "-"), Desc: true}
+	}
+	return Sort{Field: strings.TrimPrefix(value, "+")}
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
 type Paginate struct {
 	Page  int
 	Limit int
+	Sort  []Sort
 }
 
 func (p *Paginate) GetOffset() int {
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,3 +40,22 @@ func TestPaginate(t *testing.T) {
 		}
 	})
 }
+
+func TestParseSort(t *testing.T) {
+	testCases := []struct {
+		Value    string
+		Expected Sort
+	}{
+		{Value: "price", Expected: Sort{Field: "price"}},
+		{Value: "-price", Expected: Sort{Field: "price", Desc: true}},
+		{Value: " +sku ", Expected: Sort{Field: "sku"}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			actual := ParseSort(tc.Value)
+			assert.Equal(t, tc.Expected, actual)
+			assert.Equal(t, strings.TrimPrefix(strings.TrimSpace(tc.Value), "+"), actual.String())
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const ValidateTag = "validate"
+
+var patterns sync.Map
+
+// Validate checks the fields of v against their validate tags:
+//
+//	required   set, non-empty for strings and lists
+//	min=n      number at least n, or length at least n for strings and lists
+//	max=n      number at most n, or length at most n for strings and lists
+//	len=n      length exactly n
+//	pattern=re string matching re, must come last as re may contain commas
+//
+// Set float fields must also be finite. Fields are named after nameTag, or the
+// Go field name without one. All failing fields are returned together as
+// ValidationErrors; malformed tags are returned as a plain error.
+func Validate(v any, nameTag string) error {
+	return validate(v, nameTag, false)
+}
+
+// ValidatePartial validates like Validate but lets nil pointers pass
+// required, for payloads that only carry the fields to change.
+func ValidatePartial(v any, nameTag string) error {
+	return validate(v, nameTag, true)
+}
+
+func validate(v any, nameTag string, partial bool) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate need struct, got %s", rv.Kind())
+	}
+	errs := ValidationErrors{}
+	for i := 0; i < rv.NumField(); i++ {
+		typeField := rv.Type().Field(i)
+		tag, ok := typeField.Tag.Lookup(ValidateTag)
+		if !ok || tag == "-" {
+			continue
+		}
+		name := typeField.Name
+		if tagName, _ := ParseTag(typeField.Tag.Get(nameTag)); tagName != "" && tagName != "-" {
+			name = tagName
+		}
+		messages, err := validateField(rv.Field(i), tag, partial)
+		if err != nil {
+			return fmt.Errorf("invalid validate tag on %s: %w", typeField.Name, err)
+		}
+		for _, message := range messages {
+			errs = append(errs, FieldError{Field: name, Message: message})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateField(value reflect.Value, tag string, partial bool) ([]string, error) {
+	rules := parseRules(tag)
+	for key := range rules {
+		switch key {
+		case "required", "min", "max", "len", "pattern":
+		default:
+			return nil, fmt.Errorf("unknown rule %q", key)
+		}
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			if _, ok := rules["required"]; ok && !partial {
+				return []string{"is required"}, nil
+			}
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	messages := []string{}
+	isNumber, number := numberOf(value)
+	if value.Kind() == reflect.Float32 || value.Kind() == reflect.Float64 {
+		if math.IsNaN(number) || math.IsInf(number, 0) {
+			return []string{"must be a finite number"}, nil
+		}
+	}
+	length := -1
+	switch value.Kind() {
+	case reflect.String:
+		length = utf8.RuneCountInString(value.String())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		length = value.Len()
+	}
+
+	if _, ok := rules["required"]; ok && (length == 0 || (length < 0 && value.IsZero() && !isNumber)) {
+		return []string{"is required"}, nil
+	}
+	for _, key := range []string{"min", "max", "len"} {
+		raw, ok := rules[key]
+		if !ok {
+			continue
+		}
+		limit, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s=%s is not a number", key, raw)
+		}
+		if message := checkLimit(key, limit, raw, isNumber, number, length); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	if raw, ok := rules["pattern"]; ok {
+		if value.Kind() != reflect.String {
+			return nil, fmt.Errorf("pattern on %s", value.Kind())
+		}
+		re, err := compilePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !re.MatchString(value.String()) {
+			messages = append(messages, fmt.Sprintf("must match pattern %s", raw))
+		}
+	}
+	return messages, nil
+}
+
+func checkLimit(key string, limit float64, raw string, isNumber bool, number float64, length int) string {
+	if key != "len" && isNumber {
+		if key == "min" && number < limit {
+			return fmt.Sprintf("must be at least %s", raw)
+		}
+		if key == "max" && number > limit {
+			return fmt.Sprintf("must be at most %s", raw)
+		}
+		return ""
+	}
+	if length < 0 {
+		return ""
+	}
+	switch {
+	case key == "min" && float64(length) < limit:
+		return fmt.Sprintf("length must be at least %s", raw)
+	case key == "max" && float64(length) > limit:
+		return fmt.Sprintf("length must be at most %s", raw)
+	case key == "len" && float64(length) != limit:
+		return fmt.Sprintf("length must be %s", raw)
+	}
+	return ""
+}
+
+func numberOf(value reflect.Value) (bool, float64) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true, float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true, float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return true, value.Float()
+	}
+	return false, 0
+}
+
+// parseRules splits a validate tag into rules. Everything after pattern= is
+// the pattern.
+func parseRules(tag string) map[string]string {
+	rules := map[string]string{}
+	for tag = strings.TrimSpace(tag); tag != ""; tag = strings.TrimSpace(tag) {
+		part := tag
+		if strings.HasPrefix(tag, "pattern=") {
+			tag = ""
+		} else if idx := strings.Index(tag, ","); idx >= 0 {
+			part, tag = tag[:idx], tag[idx+1:]
+		} else {
+			tag = ""
+		}
+		key, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if key != "" {
+			rules[key] = val
+		}
+	}
+	return rules
+}
+
+func compilePattern(raw string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(raw); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(raw)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", raw, err)
+	}
+	patterns.Store(raw, re)
+	return re, nil
+}
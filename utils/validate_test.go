@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validated struct {
+	SKU   *string  `db:"sku" validate:"required,min=2,max=8,pattern=^[A-Z]{1,3}-[0-9]+$"`
+	Code  string   `validate:"len=3"`
+	Price *float64 `db:"price" validate:"min=0"`
+	Qty   int      `db:"qty" validate:"min=0,max=100"`
+	Tags  []string `db:"tags" validate:"max=2"`
+	Note  *string  `db:"note"`
+}
+
+func TestValidate(t *testing.T) {
+	sku := "AB-1"
+	bad := "ab"
+	price := 1.5
+	negative := -1.0
+	nan := math.NaN()
+
+	testCases := []struct {
+		Input    validated
+		Partial  bool
+		Expected ValidationErrors
+	}{
+		{
+			Input: validated{SKU: &sku, Code: "abc", Price: &price, Qty: 5},
+		},
+		{
+			Input:    validated{Code: "abc"},
+			Expected: ValidationErrors{{Field: "sku", Message: "is required"}},
+		},
+		{
+			Input:   validated{Code: "abc"},
+			Partial: true,
+		},
+		{
+			Input: validated{SKU: &bad, Code: "ab", Price: &negative, Qty: 101, Tags: []string{"a", "b", "c"}},
+			Expected: ValidationErrors{
+				{Field: "sku", Message: "must match pattern ^[A-Z]{1,3}-[0-9]+$"},
+				{Field: "Code", Message: "length must be 3"},
+				{Field: "price", Message: "must be at least 0"},
+				{Field: "qty", Message: "must be at most 100"},
+				{Field: "tags", Message: "length must be at most 2"},
+			},
+		},
+		{
+			Input:    validated{SKU: &sku, Code: "abc", Price: &nan},
+			Expected: ValidationErrors{{Field: "price", Message: "must be a finite number"}},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			var err error
+			if tc.Partial {
+				err = ValidatePartial(&tc.Input, "db")
+			} else {
+				err = Validate(tc.Input, "db")
+			}
+			if tc.Expected == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.Equal(t, tc.Expected, err)
+		})
+	}
+
+	t.Run("empty required string", func(t *testing.T) {
+		empty := ""
+		err := Validate(validated{SKU: &empty, Code: "abc"}, "db")
+		assert.Equal(t, ValidationErrors{{Field: "sku", Message: "is required"}}, err)
+	})
+
+	t.Run("invalid tag", func(t *testing.T) {
+		type invalid struct {
+			Qty int `validate:"min=zero"`
+		}
+		type unknown struct {
+			Qty int `validate:"positive"`
+		}
+		err := Validate(invalid{}, "db")
+		var validation ValidationErrors
+		assert.NotNil(t, err)
+		assert.False(t, errors.As(err, &validation))
+		assert.ErrorContains(t, Validate(unknown{}, "db"), `unknown rule "positive"`)
+	})
+}